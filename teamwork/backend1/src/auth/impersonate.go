@@ -0,0 +1,46 @@
+package auth
+
+import (
+    "log"
+
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+// impersonator returns the admin behind an impersonation token while they are still an
+// admin and have not logged out everywhere since it was issued.
+func impersonator(repo repository.Repo, tokens repository.TokenRepo, cl *Claims) *domain.User {
+    a := repo.GetUser(cl.Actor)
+    if a == nil || a.Role != domain.RoleAdmin { return nil }
+    if issuedBy(cl.IssuedAt, tokens.GetUserTokenCutoff(a.ID)) { return nil }
+    return a
+}
+
+// CurrentImpersonator returns the admin viewing the site as the current user, or nil.
+func CurrentImpersonator(c *gin.Context) *domain.User {
+    v, ok := c.Get("impersonator"); if !ok { return nil }
+    u, _ := v.(*domain.User); return u
+}
+
+// impersonationWrites are the only non-GET requests an impersonation token may make.
+var impersonationWrites = map[string]bool{"/api/auth/logout": true}
+
+// ImpersonationGuard blocks writes made while impersonating and records every
+// impersonated request in the audit log. It must run after Middleware.
+func ImpersonationGuard(audit repository.AuditRepo) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        admin := CurrentImpersonator(c)
+        if admin == nil { c.Next(); return }
+        read := c.Request.Method == "GET" || c.Request.Method == "HEAD" || c.Request.Method == "OPTIONS"
+        if !read && !impersonationWrites[c.FullPath()] {
+            c.AbortWithStatusJSON(403, gin.H{"error":"模拟用户期间禁止写操作"})
+        } else {
+            c.Next()
+        }
+        cl := CurrentClaims(c)
+        entry := &domain.AuditLog{ActorID: admin.ID, SubjectID: cl.UserID, Action: "impersonate.request", Method: c.Request.Method,
+            Path: c.Request.URL.Path, Status: c.Writer.Status(), IP: c.ClientIP(), TokenID: cl.ID}
+        if err := audit.AddAuditLog(entry); err != nil { log.Printf("audit: %v", err) }
+    }
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+type memAudit struct {
+    repository.AuditRepo
+    logs []*domain.AuditLog
+}
+
+func (m *memAudit) AddAuditLog(l *domain.AuditLog) error { m.logs = append(m.logs, l); return nil }
+
+var (
+    impStudent = &domain.User{ID: 1, Role: domain.RoleStudent}
+    impAdmin   = &domain.User{ID: 2, Role: domain.RoleAdmin}
+)
+
+func impersonationRouter(repo repository.Repo, tokens repository.TokenRepo, audit repository.AuditRepo) *gin.Engine {
+    gin.SetMode(gin.TestMode)
+    r := gin.New()
+    r.Use(Middleware(repo, tokens, nil), ImpersonationGuard(audit))
+    ok := func(c *gin.Context) {
+        if _, found := c.Get("user"); !found { c.Status(401); return }
+        c.Status(200)
+    }
+    r.GET("/api/applications", ok)
+    r.POST("/api/applications", ok)
+    r.POST("/api/auth/logout", ok)
+    return r
+}
+
+func serve(r *gin.Engine, method, path, token string) int {
+    w := httptest.NewRecorder()
+    req := httptest.NewRequest(method, path, nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+    r.ServeHTTP(w, req)
+    return w.Code
+}
+
+func TestImpersonationIsReadOnlyAndAudited(t *testing.T) {
+    useTestKeys(t)
+    audit := &memAudit{}
+    r := impersonationRouter(newUserMap(impStudent, impAdmin), &cutoffTokens{}, audit)
+    tok, cl, err := IssueImpersonationToken(impStudent, impAdmin)
+    if err != nil { t.Fatal(err) }
+    if c := serve(r, "GET", "/api/applications", tok); c != 200 { t.Errorf("read as the user: %d", c) }
+    if c := serve(r, "POST", "/api/applications", tok); c != 403 { t.Errorf("write while impersonating: %d, want 403", c) }
+    if c := serve(r, "POST", "/api/auth/logout", tok); c != 200 { t.Errorf("logout while impersonating: %d", c) }
+    if len(audit.logs) != 3 { t.Fatalf("%d audit entries, want one per request", len(audit.logs)) }
+    blocked := audit.logs[1]
+    if blocked.ActorID != impAdmin.ID || blocked.SubjectID != impStudent.ID || blocked.Status != 403 || blocked.TokenID != cl.ID {
+        t.Errorf("audit entry %+v", blocked)
+    }
+}
+
+func TestImpersonationEndsWithAdminRights(t *testing.T) {
+    useTestKeys(t)
+    tok, _, _ := IssueImpersonationToken(impStudent, impAdmin)
+    demoted := &domain.User{ID: impAdmin.ID, Role: domain.RoleTeacher}
+    r := impersonationRouter(newUserMap(impStudent, demoted), &cutoffTokens{}, &memAudit{})
+    if c := serve(r, "GET", "/api/applications", tok); c != 401 { t.Errorf("demoted admin still impersonates: %d", c) }
+
+    // the admin logging out everywhere ends the impersonation too, not the student's sessions
+    tokens := &userCutoffs{cutoff: map[int64]time.Time{impAdmin.ID: time.Now()}}
+    r = impersonationRouter(newUserMap(impStudent, impAdmin), tokens, &memAudit{})
+    if c := serve(r, "GET", "/api/applications", tok); c != 401 { t.Errorf("impersonation survived the admin's cutoff: %d", c) }
+}
+
+// userCutoffs has a cutoff per user.
+type userCutoffs struct {
+    repository.TokenRepo
+    cutoff map[int64]time.Time
+}
+
+func (u *userCutoffs) IsAccessTokenRevoked(string) bool { return false }
+
+func (u *userCutoffs) GetUserTokenCutoff(id int64) time.Time { return u.cutoff[id] }
@@ -1,7 +1,10 @@
 package auth
 
 import (
-    "os"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "strings"
     "time"
     "github.com/gin-gonic/gin"
     "github.com/golang-jwt/jwt/v5"
@@ -9,33 +12,116 @@ import (
     "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
 )
 
+// AccessTokenTTL is kept short; clients renew through /api/auth/refresh.
+const AccessTokenTTL = 15 * time.Minute
+
+// Token purposes other than a regular access token. Middleware never authenticates
+// a user from a token that carries a purpose.
+const (
+    PurposeMFA       = "mfa"        // password accepted, TOTP code pending
+    PurposeMFAEnroll = "mfa_enroll" // password accepted, 2FA required but not set up yet
+)
+
+// ChallengeTokenTTL bounds how long the second login step may take.
+const ChallengeTokenTTL = 5 * time.Minute
+
+// ImpersonationTTL bounds an admin's "view as user" session.
+const ImpersonationTTL = 30 * time.Minute
+
 type Claims struct {
-    UserID int64      `json:"uid"`
-    Role   domain.Role `json:"role"`
+    UserID  int64       `json:"uid"`
+    Role    domain.Role `json:"role"`
+    Purpose string      `json:"pur,omitempty"`
+    // Actor is the admin behind an impersonation token; UserID is the user being viewed.
+    Actor   int64       `json:"act,omitempty"`
     jwt.RegisteredClaims
 }
 
-func secret() string {
-    if s := os.Getenv("SC_JWT_SECRET"); s != "" { return s }
-    return "dev"
+// NewTokenID returns a random hex identifier used for jti and refresh token families.
+func NewTokenID() string {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil { panic(err) }
+    return hex.EncodeToString(b)
 }
 
 func GenerateToken(u *domain.User) (string, error) {
-    claims := &Claims{UserID: u.ID, Role: u.Role, RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(24*time.Hour))}}
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    return token.SignedString([]byte(secret()))
+    t, _, err := IssueAccessToken(u)
+    return t, err
+}
+
+// IssueAccessToken signs a short-lived access token and returns its claims so the
+// caller can report the expiry or revoke the jti later.
+func IssueAccessToken(u *domain.User) (string, *Claims, error) {
+    return issue(u, "", AccessTokenTTL)
 }
 
-func Middleware(repo repository.Repo) gin.HandlerFunc {
+// IssueChallengeToken signs a short-lived token for the second login step.
+func IssueChallengeToken(u *domain.User, purpose string) (string, *Claims, error) {
+    return issue(u, purpose, ChallengeTokenTTL)
+}
+
+// IssueImpersonationToken lets admin act as u until ImpersonationTTL passes. There is
+// no refresh token; the admin starts a new impersonation instead.
+func IssueImpersonationToken(u *domain.User, admin *domain.User) (string, *Claims, error) {
+    return sign(&Claims{UserID: u.ID, Role: u.Role, Actor: admin.ID}, ImpersonationTTL)
+}
+
+func issue(u *domain.User, purpose string, ttl time.Duration) (string, *Claims, error) {
+    return sign(&Claims{UserID: u.ID, Role: u.Role, Purpose: purpose}, ttl)
+}
+
+func sign(claims *Claims, ttl time.Duration) (string, *Claims, error) {
+    now := time.Now()
+    claims.RegisteredClaims = jwt.RegisteredClaims{
+        ID:        NewTokenID(),
+        IssuedAt:  jwt.NewNumericDate(now),
+        ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+    }
+    if keys == nil { return "", nil, errors.New("签名密钥未初始化") }
+    s, err := keys.Sign(claims)
+    if err != nil { return "", nil, err }
+    return s, claims, nil
+}
+
+func ParseToken(t string) (*Claims, error) {
+    if keys == nil { return nil, errors.New("签名密钥未初始化") }
+    tok, err := keys.Parse(t, &Claims{})
+    if err != nil { return nil, err }
+    cl, ok := tok.Claims.(*Claims)
+    if !ok || !tok.Valid { return nil, jwt.ErrTokenInvalidClaims }
+    return cl, nil
+}
+
+// CurrentClaims returns the claims of the access token that authenticated the request.
+func CurrentClaims(c *gin.Context) *Claims {
+    v, ok := c.Get("claims"); if !ok { return nil }
+    cl, _ := v.(*Claims); return cl
+}
+
+// CurrentChallenge returns the claims of a 2FA challenge token presented instead of an access token.
+func CurrentChallenge(c *gin.Context) *Claims {
+    v, ok := c.Get("challenge"); if !ok { return nil }
+    cl, _ := v.(*Claims); return cl
+}
+
+func Middleware(repo repository.Repo, tokens repository.TokenRepo, pats repository.PATRepo) gin.HandlerFunc {
     return func(c *gin.Context) {
         h := c.GetHeader("Authorization")
         if len(h) > 7 && (h[:7] == "Bearer " || h[:7] == "bearer ") {
             t := h[7:]
-            tok, err := jwt.ParseWithClaims(t, &Claims{}, func(token *jwt.Token) (any, error) { return []byte(secret()), nil })
-            if err == nil && tok.Valid {
-                if cl, ok := tok.Claims.(*Claims); ok {
+            if strings.HasPrefix(t, PATPrefix) {
+                if u, pt := personalToken(repo, tokens, pats, t); u != nil { c.Set("user", u); c.Set("pat", pt) }
+            } else if cl, err := ParseToken(t); err == nil && !revoked(tokens, cl) {
+                if cl.Purpose == PurposeMFA || cl.Purpose == PurposeMFAEnroll {
+                    c.Set("challenge", cl)
+                } else if cl.Purpose == "" {
                     u := repo.GetUser(cl.UserID)
-                    if u != nil { c.Set("user", u) }
+                    var actor *domain.User
+                    if cl.Actor != 0 { actor = impersonator(repo, tokens, cl) }
+                    if u != nil && (cl.Actor == 0 || actor != nil) {
+                        c.Set("user", u); c.Set("claims", cl)
+                        if actor != nil { c.Set("impersonator", actor) }
+                    }
                 }
             }
         }
@@ -43,12 +129,29 @@ func Middleware(repo repository.Repo) gin.HandlerFunc {
     }
 }
 
+// revoked rejects tokens whose jti was logged out and tokens issued up to the user's
+// cutoff (set on role change or "log out everywhere").
+func revoked(tokens repository.TokenRepo, cl *Claims) bool {
+    if cl.ID == "" || cl.IssuedAt == nil { return true }
+    if tokens.IsAccessTokenRevoked(cl.ID) { return true }
+    return issuedBy(cl.IssuedAt, tokens.GetUserTokenCutoff(cl.UserID))
+}
+
+// issuedBy reports whether a token was issued no later than cutoff. iat has whole
+// seconds, so a cutoff covers every token issued in its second; a login in that same
+// second has to be repeated.
+func issuedBy(iat *jwt.NumericDate, cutoff time.Time) bool {
+    return !cutoff.IsZero() && !iat.Time.After(cutoff.Truncate(time.Second))
+}
+
 func RequireRole(roles ...domain.Role) gin.HandlerFunc {
     return func(c *gin.Context) {
         v, ok := c.Get("user")
         if !ok { c.AbortWithStatusJSON(401, gin.H{"error":"未认证"}); return }
         u := v.(*domain.User)
+        // a personal access token only reaches routes whose group granted it a scope
+        if CurrentToken(c) != nil && !c.GetBool("scope_ok") { c.AbortWithStatusJSON(403, gin.H{"error":"令牌权限不足"}); return }
         for _, r := range roles { if u.Role == r { c.Next(); return } }
         c.AbortWithStatusJSON(403, gin.H{"error":"无权限"})
     }
-}
\ No newline at end of file
+}
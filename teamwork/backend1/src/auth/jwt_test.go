@@ -0,0 +1,71 @@
+package auth
+
+import (
+    "testing"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+func useTestKeys(t *testing.T) {
+    t.Helper()
+    ks, err := NewKeySet(&memKeys{}, AlgEdDSA, 0, 0)
+    if err != nil { t.Fatal(err) }
+    old := keys
+    UseKeySet(ks)
+    t.Cleanup(func() { UseKeySet(old) })
+}
+
+// cutoffTokens answers only the lookups revoked makes.
+type cutoffTokens struct {
+    repository.TokenRepo
+    revoked map[string]bool
+    cutoff  time.Time
+}
+
+func (c *cutoffTokens) IsAccessTokenRevoked(jti string) bool { return c.revoked[jti] }
+
+func (c *cutoffTokens) GetUserTokenCutoff(int64) time.Time { return c.cutoff }
+
+func issueParsed(t *testing.T, u *domain.User) *Claims {
+    t.Helper()
+    tok, _, err := IssueAccessToken(u)
+    if err != nil { t.Fatal(err) }
+    cl, err := ParseToken(tok)
+    if err != nil { t.Fatal(err) }
+    return cl
+}
+
+func TestRevokedCutoffCoversItsSecond(t *testing.T) {
+    cutoff := time.Date(2026, 3, 1, 10, 0, 5, 500e6, time.UTC)
+    tokens := &cutoffTokens{cutoff: cutoff}
+    at := func(d time.Duration) *Claims {
+        return &Claims{UserID: 1, RegisteredClaims: jwt.RegisteredClaims{ID: "j", IssuedAt: jwt.NewNumericDate(cutoff.Add(d))}}
+    }
+    if !revoked(tokens, at(-time.Second)) { t.Error("token issued a second before the cutoff is still valid") }
+    if !revoked(tokens, at(-100*time.Millisecond)) { t.Error("token issued earlier in the cutoff's second is still valid") }
+    if revoked(tokens, at(time.Second)) { t.Error("token issued after the cutoff's second is revoked") }
+}
+
+func TestClaimsKeepWholeSeconds(t *testing.T) {
+    useTestKeys(t)
+    if jwt.TimePrecision != time.Second { t.Fatalf("jwt.TimePrecision = %v", jwt.TimePrecision) }
+    cl := issueParsed(t, &domain.User{ID: 1, Role: domain.RoleStudent})
+    if cl.IssuedAt.Nanosecond() != 0 || cl.ExpiresAt.Nanosecond() != 0 { t.Errorf("iat %v, exp %v carry fractions", cl.IssuedAt.Time, cl.ExpiresAt.Time) }
+}
+
+func TestRevokedByJTI(t *testing.T) {
+    useTestKeys(t)
+    cl := issueParsed(t, &domain.User{ID: 1, Role: domain.RoleStudent})
+    tokens := &cutoffTokens{revoked: map[string]bool{}}
+    if revoked(tokens, cl) { t.Fatal("fresh token is revoked") }
+    tokens.revoked[cl.ID] = true
+    if !revoked(tokens, cl) { t.Error("logged out token is still valid") }
+}
+
+func TestRevokedWithoutIssuedAt(t *testing.T) {
+    if !revoked(&cutoffTokens{}, &Claims{UserID: 1}) { t.Error("token without jti and iat accepted") }
+}
@@ -0,0 +1,257 @@
+package auth
+
+import (
+    "context"
+    "crypto"
+    "crypto/ed25519"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/pem"
+    "errors"
+    "fmt"
+    "math/big"
+    "sync"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+const (
+    AlgRS256 = "RS256"
+    AlgEdDSA = "EdDSA"
+)
+
+type loadedKey struct {
+    kid       string
+    alg       string
+    status    string
+    priv      crypto.Signer
+    pub       crypto.PublicKey
+    createdAt time.Time
+    rotatedAt *time.Time
+}
+
+// KeySet holds the signing keys shared through repository.KeyRepo. The active key
+// signs new tokens; previous keys keep verifying until they are retired.
+type KeySet struct {
+    mu          sync.RWMutex
+    repo        repository.KeyRepo
+    alg         string
+    rotateEvery time.Duration
+    retireAfter time.Duration
+    active      *loadedKey
+    keys        map[string]*loadedKey
+    loadedAt    time.Time
+}
+
+var keys *KeySet
+
+// UseKeySet installs the key set used by GenerateToken, ParseToken and Middleware.
+func UseKeySet(ks *KeySet) { keys = ks }
+
+// NewKeySet loads the persisted keys and creates a first active key if there is none.
+// rotateEvery is the lifetime of an active key, retireAfter how long a superseded key
+// keeps verifying; it must exceed AccessTokenTTL.
+func NewKeySet(repo repository.KeyRepo, alg string, rotateEvery, retireAfter time.Duration) (*KeySet, error) {
+    if alg == "" { alg = AlgRS256 }
+    if alg != AlgRS256 && alg != AlgEdDSA { return nil, fmt.Errorf("unsupported jwt algorithm %q", alg) }
+    if rotateEvery <= 0 { rotateEvery = 30 * 24 * time.Hour }
+    if retireAfter < AccessTokenTTL { retireAfter = 24 * time.Hour }
+    ks := &KeySet{repo: repo, alg: alg, rotateEvery: rotateEvery, retireAfter: retireAfter}
+    if err := ks.Reload(); err != nil { return nil, err }
+    ks.mu.RLock(); empty := ks.active == nil; ks.mu.RUnlock()
+    if empty {
+        if err := ks.Rotate(); err != nil { return nil, err }
+    }
+    return ks, nil
+}
+
+// Reload reads the keys from the repository and retires superseded keys past their grace
+// period. Should two instances have created a first key at once, the older active keys
+// are demoted so that every instance signs with the same one.
+func (ks *KeySet) Reload() error {
+    now := time.Now()
+    m := map[string]*loadedKey{}
+    var active *loadedKey
+    var extra []string
+    for _, k := range ks.repo.ListSigningKeys() {
+        if k.Status == domain.KeyStatusRetired { continue }
+        if k.Status == domain.KeyStatusPrevious && k.RotatedAt != nil && now.Sub(*k.RotatedAt) > ks.retireAfter {
+            if err := ks.repo.UpdateSigningKeyStatus(k.KID, domain.KeyStatusRetired, now); err != nil { return err }
+            continue
+        }
+        lk, err := decodeKey(k)
+        if err != nil { return err }
+        m[k.KID] = lk
+        if k.Status != domain.KeyStatusActive { continue }
+        if active == nil || lk.createdAt.After(active.createdAt) {
+            if active != nil { extra = append(extra, active.kid) }
+            active = lk
+        } else {
+            extra = append(extra, lk.kid)
+        }
+    }
+    for _, kid := range extra {
+        if err := ks.repo.UpdateSigningKeyStatus(kid, domain.KeyStatusPrevious, now); err != nil { return err }
+        m[kid].status = domain.KeyStatusPrevious
+    }
+    ks.mu.Lock()
+    ks.keys, ks.active, ks.loadedAt = m, active, now
+    ks.mu.Unlock()
+    return nil
+}
+
+// Rotate generates a new active key and demotes the current one to previous. If another
+// instance rotated first, its key is kept and simply loaded.
+func (ks *KeySet) Rotate() error {
+    k, err := generateKey(ks.alg)
+    if err != nil { return err }
+    old := ""
+    ks.mu.RLock(); if ks.active != nil { old = ks.active.kid }; ks.mu.RUnlock()
+    if _, err := ks.repo.RotateSigningKey(old, k, time.Now()); err != nil { return err }
+    return ks.Reload()
+}
+
+// Retire immediately stops accepting a key, e.g. after it leaked. Retiring the active
+// key rotates first so signing continues.
+func (ks *KeySet) Retire(kid string) error {
+    ks.mu.RLock(); k := ks.keys[kid]; isActive := ks.active != nil && ks.active.kid == kid; ks.mu.RUnlock()
+    if k == nil { return errors.New("密钥不存在或已停用") }
+    if isActive {
+        if err := ks.Rotate(); err != nil { return err }
+    }
+    if err := ks.repo.UpdateSigningKeyStatus(kid, domain.KeyStatusRetired, time.Now()); err != nil { return err }
+    return ks.Reload()
+}
+
+// RotateLoop reloads keys written by other instances and rotates the active key when it
+// expires, until ctx is done.
+func (ks *KeySet) RotateLoop(ctx context.Context, interval time.Duration) {
+    tick := time.NewTicker(interval)
+    defer tick.Stop()
+    for {
+        select {
+        case <-ctx.Done(): return
+        case <-tick.C:
+        }
+        if err := ks.Reload(); err != nil { continue }
+        ks.mu.RLock(); due := ks.active == nil || time.Since(ks.active.createdAt) > ks.rotateEvery; ks.mu.RUnlock()
+        if due { _ = ks.Rotate() }
+    }
+}
+
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+    ks.mu.RLock(); k := ks.active; ks.mu.RUnlock()
+    if k == nil { return "", errors.New("没有可用的签名密钥") }
+    token := jwt.NewWithClaims(jwt.GetSigningMethod(k.alg), claims)
+    token.Header["kid"] = k.kid
+    return token.SignedString(k.priv)
+}
+
+func (ks *KeySet) Parse(t string, claims jwt.Claims) (*jwt.Token, error) {
+    return jwt.ParseWithClaims(t, claims, ks.keyFunc, jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}))
+}
+
+func (ks *KeySet) keyFunc(token *jwt.Token) (any, error) {
+    kid, _ := token.Header["kid"].(string)
+    k := ks.lookup(kid)
+    if k == nil {
+        // another instance may have rotated since our last reload
+        ks.mu.RLock(); stale := time.Since(ks.loadedAt) > 10*time.Second; ks.mu.RUnlock()
+        if stale && ks.Reload() == nil { k = ks.lookup(kid) }
+    }
+    if k == nil { return nil, errors.New("unknown kid") }
+    if token.Method.Alg() != k.alg { return nil, errors.New("alg mismatch") }
+    return k.pub, nil
+}
+
+func (ks *KeySet) lookup(kid string) *loadedKey {
+    ks.mu.RLock(); defer ks.mu.RUnlock()
+    return ks.keys[kid]
+}
+
+// JWK is the public part of a signing key as published in /.well-known/jwks.json.
+type JWK struct {
+    Kty string `json:"kty"`
+    Kid string `json:"kid"`
+    Use string `json:"use"`
+    Alg string `json:"alg"`
+    N   string `json:"n,omitempty"`
+    E   string `json:"e,omitempty"`
+    Crv string `json:"crv,omitempty"`
+    X   string `json:"x,omitempty"`
+    Y   string `json:"y,omitempty"`
+}
+
+func (ks *KeySet) JWKS() map[string][]JWK {
+    ks.mu.RLock(); defer ks.mu.RUnlock()
+    out := []JWK{}
+    for _, k := range ks.keys {
+        j := JWK{Kid: k.kid, Use: "sig", Alg: k.alg}
+        switch pub := k.pub.(type) {
+        case *rsa.PublicKey:
+            j.Kty = "RSA"
+            j.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+            j.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+        case ed25519.PublicKey:
+            j.Kty, j.Crv = "OKP", "Ed25519"
+            j.X = base64.RawURLEncoding.EncodeToString(pub)
+        }
+        out = append(out, j)
+    }
+    return map[string][]JWK{"keys": out}
+}
+
+type KeyInfo struct {
+    KID       string     `json:"kid"`
+    Alg       string     `json:"alg"`
+    Status    string     `json:"status"`
+    CreatedAt time.Time  `json:"created_at"`
+    RotatedAt *time.Time `json:"rotated_at"`
+}
+
+// Keys lists every non-retired key for the admin API.
+func (ks *KeySet) Keys() []KeyInfo {
+    ks.mu.RLock(); defer ks.mu.RUnlock()
+    out := []KeyInfo{}
+    for _, k := range ks.keys { out = append(out, KeyInfo{KID: k.kid, Alg: k.alg, Status: k.status, CreatedAt: k.createdAt, RotatedAt: k.rotatedAt}) }
+    return out
+}
+
+func generateKey(alg string) (*domain.SigningKey, error) {
+    var priv crypto.Signer
+    var err error
+    switch alg {
+    case AlgEdDSA:
+        _, priv, err = ed25519.GenerateKey(rand.Reader)
+    default:
+        priv, err = rsa.GenerateKey(rand.Reader, 2048)
+    }
+    if err != nil { return nil, err }
+    privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+    if err != nil { return nil, err }
+    pubDER, err := x509.MarshalPKIXPublicKey(priv.Public())
+    if err != nil { return nil, err }
+    return &domain.SigningKey{
+        KID:        NewTokenID(),
+        Alg:        alg,
+        PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})),
+        PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})),
+        Status:     domain.KeyStatusActive,
+        CreatedAt:  time.Now(),
+    }, nil
+}
+
+func decodeKey(k *domain.SigningKey) (*loadedKey, error) {
+    block, _ := pem.Decode([]byte(k.PrivateKey))
+    if block == nil { return nil, fmt.Errorf("signing key %s: invalid pem", k.KID) }
+    parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+    if err != nil { return nil, fmt.Errorf("signing key %s: %w", k.KID, err) }
+    priv, ok := parsed.(crypto.Signer)
+    if !ok { return nil, fmt.Errorf("signing key %s: unsupported key type", k.KID) }
+    return &loadedKey{kid: k.KID, alg: k.Alg, status: k.Status, priv: priv, pub: priv.Public(), createdAt: k.CreatedAt, rotatedAt: k.RotatedAt}, nil
+}
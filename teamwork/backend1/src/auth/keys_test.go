@@ -0,0 +1,101 @@
+package auth
+
+import (
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+// memKeys keeps signing keys in memory; RotateSigningKey is atomic like the gorm one.
+type memKeys struct {
+    mu   sync.Mutex
+    keys []*domain.SigningKey
+}
+
+func (m *memKeys) ListSigningKeys() []*domain.SigningKey {
+    m.mu.Lock(); defer m.mu.Unlock()
+    out := make([]*domain.SigningKey, len(m.keys))
+    for i, k := range m.keys { cp := *k; out[i] = &cp }
+    return out
+}
+
+func (m *memKeys) UpdateSigningKeyStatus(kid, status string, at time.Time) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    for _, k := range m.keys {
+        if k.KID != kid { continue }
+        k.Status = status
+        if status == domain.KeyStatusPrevious { k.RotatedAt = &at }
+    }
+    return nil
+}
+
+func (m *memKeys) RotateSigningKey(old string, k *domain.SigningKey, at time.Time) (bool, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    var cur *domain.SigningKey
+    for _, c := range m.keys {
+        if c.Status == domain.KeyStatusActive { cur = c }
+    }
+    if (old == "" && cur != nil) || (old != "" && (cur == nil || cur.KID != old)) { return false, nil }
+    if cur != nil { cur.Status, cur.RotatedAt = domain.KeyStatusPrevious, &at }
+    m.keys = append(m.keys, k)
+    return true, nil
+}
+
+func (m *memKeys) active() []string {
+    m.mu.Lock(); defer m.mu.Unlock()
+    var out []string
+    for _, k := range m.keys {
+        if k.Status == domain.KeyStatusActive { out = append(out, k.KID) }
+    }
+    return out
+}
+
+func TestRotateRaceKeepsOneActiveKey(t *testing.T) {
+    repo := &memKeys{}
+    a, err := NewKeySet(repo, AlgEdDSA, 0, 0)
+    if err != nil { t.Fatal(err) }
+    b, err := NewKeySet(repo, AlgEdDSA, 0, 0)
+    if err != nil { t.Fatal(err) }
+    if got := repo.active(); len(got) != 1 { t.Fatalf("active keys after start: %v", got) }
+
+    // both instances decide to rotate away from the same key
+    var wg sync.WaitGroup
+    for _, ks := range []*KeySet{a, b} {
+        wg.Add(1)
+        go func() { defer wg.Done(); _ = ks.Rotate() }()
+    }
+    wg.Wait()
+    got := repo.active()
+    if len(got) != 1 { t.Fatalf("active keys after concurrent rotation: %v", got) }
+    if err := a.Reload(); err != nil { t.Fatal(err) }
+    if err := b.Reload(); err != nil { t.Fatal(err) }
+    if a.active.kid != got[0] || b.active.kid != got[0] { t.Error("instances sign with different keys") }
+}
+
+func TestReloadDemotesExtraActiveKeys(t *testing.T) {
+    repo := &memKeys{}
+    for i := 0; i < 2; i++ {
+        k, err := generateKey(AlgEdDSA)
+        if err != nil { t.Fatal(err) }
+        k.CreatedAt = time.Now().Add(time.Duration(i) * time.Second)
+        repo.keys = append(repo.keys, k)
+    }
+    ks, err := NewKeySet(repo, AlgEdDSA, 0, 0)
+    if err != nil { t.Fatal(err) }
+    got := repo.active()
+    if len(got) != 1 || got[0] != repo.keys[1].KID { t.Fatalf("active keys: %v", got) }
+    if ks.active.kid != got[0] { t.Error("signing with a demoted key") }
+    if ks.lookup(repo.keys[0].KID) == nil { t.Error("demoted key no longer verifies") }
+}
+
+func TestRetireActiveKeyRotates(t *testing.T) {
+    repo := &memKeys{}
+    ks, err := NewKeySet(repo, AlgEdDSA, 0, 0)
+    if err != nil { t.Fatal(err) }
+    old := ks.active.kid
+    if err := ks.Retire(old); err != nil { t.Fatal(err) }
+    if ks.active == nil || ks.active.kid == old { t.Fatal("retired key still signs") }
+    if ks.lookup(old) != nil { t.Error("retired key still verifies") }
+}
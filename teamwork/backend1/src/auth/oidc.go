@@ -0,0 +1,162 @@
+package auth
+
+import (
+    "context"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rsa"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math/big"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+)
+
+type OIDCConfig struct {
+    Issuer       string
+    ClientID     string
+    ClientSecret string
+    RedirectURL  string
+    Scopes       []string
+}
+
+// OIDCProvider is a minimal relying party for the authorization code flow with PKCE.
+// Discovery and the provider's JWKS are fetched lazily and cached.
+type OIDCProvider struct {
+    cfg    OIDCConfig
+    client *http.Client
+
+    mu     sync.Mutex
+    meta   *oidcMetadata
+    jwks   map[string]any
+    jwksAt time.Time
+}
+
+type oidcMetadata struct {
+    Issuer                string `json:"issuer"`
+    AuthorizationEndpoint string `json:"authorization_endpoint"`
+    TokenEndpoint         string `json:"token_endpoint"`
+    JWKSURI               string `json:"jwks_uri"`
+}
+
+func NewOIDCProvider(cfg OIDCConfig) *OIDCProvider {
+    if len(cfg.Scopes) == 0 { cfg.Scopes = []string{"openid", "profile", "email"} }
+    return &OIDCProvider{cfg: cfg, client: &http.Client{Timeout: 4 * time.Second}}
+}
+
+// NewPKCEVerifier returns a code_verifier and its S256 code_challenge.
+func NewPKCEVerifier() (verifier, challenge string) {
+    verifier = NewTokenID() + NewTokenID()
+    sum := sha256.Sum256([]byte(verifier))
+    return verifier, base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (p *OIDCProvider) AuthURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+    m, err := p.metadata(ctx)
+    if err != nil { return "", err }
+    v := url.Values{}
+    v.Set("response_type", "code")
+    v.Set("client_id", p.cfg.ClientID)
+    v.Set("redirect_uri", p.cfg.RedirectURL)
+    v.Set("scope", strings.Join(p.cfg.Scopes, " "))
+    v.Set("state", state)
+    v.Set("nonce", nonce)
+    v.Set("code_challenge", challenge)
+    v.Set("code_challenge_method", "S256")
+    sep := "?"
+    if strings.Contains(m.AuthorizationEndpoint, "?") { sep = "&" }
+    return m.AuthorizationEndpoint + sep + v.Encode(), nil
+}
+
+// Exchange redeems the code and returns the verified ID token claims.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier, nonce string) (jwt.MapClaims, error) {
+    m, err := p.metadata(ctx)
+    if err != nil { return nil, err }
+    form := url.Values{}
+    form.Set("grant_type", "authorization_code")
+    form.Set("code", code)
+    form.Set("redirect_uri", p.cfg.RedirectURL)
+    form.Set("code_verifier", verifier)
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+    if err != nil { return nil, err }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+    resp, err := p.client.Do(req)
+    if err != nil { return nil, err }
+    defer resp.Body.Close()
+    var tr struct {
+        IDToken string `json:"id_token"`
+        Error   string `json:"error"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil { return nil, err }
+    if resp.StatusCode != http.StatusOK || tr.IDToken == "" { return nil, fmt.Errorf("oidc token endpoint: %d %s", resp.StatusCode, tr.Error) }
+    claims := jwt.MapClaims{}
+    _, err = jwt.ParseWithClaims(tr.IDToken, claims, func(t *jwt.Token) (any, error) {
+        kid, _ := t.Header["kid"].(string)
+        return p.key(ctx, kid)
+    }, jwt.WithValidMethods([]string{"RS256", "ES256"}), jwt.WithIssuer(m.Issuer), jwt.WithAudience(p.cfg.ClientID), jwt.WithExpirationRequired())
+    if err != nil { return nil, err }
+    if n, _ := claims["nonce"].(string); n != nonce { return nil, errors.New("oidc nonce mismatch") }
+    return claims, nil
+}
+
+func (p *OIDCProvider) metadata(ctx context.Context) (*oidcMetadata, error) {
+    p.mu.Lock(); defer p.mu.Unlock()
+    if p.meta != nil { return p.meta, nil }
+    var m oidcMetadata
+    if err := p.getJSON(ctx, strings.TrimRight(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", &m); err != nil { return nil, err }
+    if m.Issuer != p.cfg.Issuer { return nil, fmt.Errorf("oidc issuer mismatch: %s", m.Issuer) }
+    p.meta = &m
+    return p.meta, nil
+}
+
+// key resolves a kid, refetching the JWKS (at most once a minute) when it is unknown.
+func (p *OIDCProvider) key(ctx context.Context, kid string) (any, error) {
+    m, err := p.metadata(ctx)
+    if err != nil { return nil, err }
+    p.mu.Lock(); defer p.mu.Unlock()
+    if k, ok := p.jwks[kid]; ok { return k, nil }
+    if time.Since(p.jwksAt) < time.Minute { return nil, errors.New("oidc unknown kid") }
+    var set struct{ Keys []JWK `json:"keys"` }
+    if err := p.getJSON(ctx, m.JWKSURI, &set); err != nil { return nil, err }
+    p.jwks, p.jwksAt = map[string]any{}, time.Now()
+    for _, j := range set.Keys {
+        if k, err := j.publicKey(); err == nil { p.jwks[j.Kid] = k }
+    }
+    if k, ok := p.jwks[kid]; ok { return k, nil }
+    return nil, errors.New("oidc unknown kid")
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, u string, v any) error {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+    if err != nil { return err }
+    resp, err := p.client.Do(req)
+    if err != nil { return err }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK { return fmt.Errorf("GET %s: %d", u, resp.StatusCode) }
+    return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (j JWK) publicKey() (any, error) {
+    dec := base64.RawURLEncoding.DecodeString
+    switch j.Kty {
+    case "RSA":
+        n, err := dec(j.N); if err != nil { return nil, err }
+        e, err := dec(j.E); if err != nil { return nil, err }
+        return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+    case "EC":
+        if j.Crv != "P-256" { return nil, errors.New("unsupported curve") }
+        x, err := dec(j.X); if err != nil { return nil, err }
+        y, err := dec(j.Y); if err != nil { return nil, err }
+        return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+    }
+    return nil, errors.New("unsupported key type")
+}
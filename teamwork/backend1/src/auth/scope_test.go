@@ -0,0 +1,70 @@
+package auth
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+type userMap struct {
+    repository.Repo
+    byID map[int64]*domain.User
+}
+
+func newUserMap(us ...*domain.User) userMap {
+    m := userMap{byID: map[int64]*domain.User{}}
+    for _, u := range us { m.byID[u.ID] = u }
+    return m
+}
+
+func (r userMap) GetUser(id int64) *domain.User { return r.byID[id] }
+
+type memPATs struct {
+    repository.PATRepo
+    byHash map[string]*domain.PersonalToken
+}
+
+func (m *memPATs) GetPersonalTokenByHash(hash string) *domain.PersonalToken { return m.byHash[hash] }
+
+func (m *memPATs) TouchPersonalToken(int64, time.Time) error { return nil }
+
+// newPAT stores a token created at created and returns the raw token.
+func (m *memPATs) newPAT(userID int64, created time.Time, scopes ...string) string {
+    tok, hash := NewPersonalToken()
+    m.byHash[hash] = &domain.PersonalToken{UserID: userID, Scopes: scopes, ExpiresAt: time.Now().Add(time.Hour), CreatedAt: created}
+    return tok
+}
+
+func TestPersonalTokenHonoursCutoff(t *testing.T) {
+    repo := newUserMap(&domain.User{ID: 1, Role: domain.RoleStudent})
+    pats := &memPATs{byHash: map[string]*domain.PersonalToken{}}
+    now := time.Now()
+    old, fresh := pats.newPAT(1, now.Add(-time.Hour), "projects:read"), pats.newPAT(1, now.Add(time.Millisecond), "projects:read")
+    tokens := &cutoffTokens{cutoff: now}
+    if u, _ := personalToken(repo, tokens, pats, old); u != nil { t.Error("token created before the cutoff still works") }
+    if u, _ := personalToken(repo, tokens, pats, fresh); u == nil { t.Error("token created after the cutoff rejected") }
+}
+
+func TestProfileNeedsScope(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    repo := newUserMap(&domain.User{ID: 1, Role: domain.RoleStudent})
+    pats := &memPATs{byHash: map[string]*domain.PersonalToken{}}
+    r := gin.New()
+    r.Use(Middleware(repo, &cutoffTokens{}, pats))
+    r.GET("/me", RequireScope("profile"), func(c *gin.Context) { c.Status(200) })
+    for tok, want := range map[string]int{
+        pats.newPAT(1, time.Now(), "projects:write"): 403,
+        pats.newPAT(1, time.Now(), "profile:read"):   200,
+    } {
+        w := httptest.NewRecorder()
+        req := httptest.NewRequest(http.MethodGet, "/me", nil)
+        req.Header.Set("Authorization", "Bearer "+tok)
+        r.ServeHTTP(w, req)
+        if w.Code != want { t.Errorf("got %d, want %d", w.Code, want) }
+    }
+}
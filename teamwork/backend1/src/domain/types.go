@@ -16,7 +16,16 @@ type User struct {
     Email        string   `json:"email" gorm:"size:191;uniqueIndex"`
     Role         Role     `json:"role" gorm:"size:32"`
     Skills       []string `json:"skills,omitempty" gorm:"serializer:json"`
+    Bio          string   `json:"bio,omitempty" gorm:"type:text"` // free-text profile read by the text matcher
+    // Proficiency rates some of the skills from 1 (basic) to 5 (expert).
+    Proficiency  map[string]int `json:"proficiency,omitempty" gorm:"serializer:json"`
     PasswordHash string   `json:"-"`
+    EmailVerified bool    `json:"email_verified"`
+    // ServiceAccount marks a non-human user; it has no password and acts only through access tokens.
+    ServiceAccount bool   `json:"service_account" gorm:"index"`
+    // Submission carries the cover letter and answers of the application being rated. It
+    // is set only on the copy handed to a matcher and never stored.
+    Submission   string   `json:"-" gorm:"-"`
 }
 
 type Project struct {
@@ -25,15 +34,109 @@ type Project struct {
     Title        string   `json:"title"`
     Description  string   `json:"description"`
     Requirements []string `json:"requirements" gorm:"serializer:json"`
+    // RequirementDetails weighs the requirements; when set, Requirements lists its skills.
+    RequirementDetails []Requirement `json:"requirement_details,omitempty" gorm:"serializer:json"`
     Tags         []string `json:"tags" gorm:"serializer:json"`
     Archived     bool     `json:"archived" gorm:"index"`
+    // CoSupervisors are teachers who help the owner review and supervise applicants.
+    CoSupervisors []int64 `json:"co_supervisors" gorm:"serializer:json"`
+    // Capacity caps approved students; 0 means unlimited. With AutoWaitlist, applicants
+    // beyond capacity are waitlisted and promoted in order when an approved student withdraws.
+    Capacity     int      `json:"capacity"`
+    AutoWaitlist bool     `json:"auto_waitlist"`
+    // Questions is the form applicants fill in; RequireCoverLetter makes the letter mandatory.
+    Questions          []Question `json:"questions" gorm:"serializer:json"`
+    RequireCoverLetter bool       `json:"require_cover_letter"`
+    // CountTeamMembers makes an approved team take one seat per member instead of one in total.
+    CountTeamMembers   bool       `json:"count_team_members"`
+}
+
+type Requirement struct {
+    Skill     string  `json:"skill"`
+    Mandatory bool    `json:"mandatory"`
+    Weight    float64 `json:"weight,omitempty"`    // 0 counts as 1
+    MinLevel  int     `json:"min_level,omitempty"` // 1-5, 0 = any
+}
+
+// Question types of a project's application form.
+const (
+    QuestionText   = "text"
+    QuestionChoice = "choice"
+    QuestionFile   = "file" // answered with a link to the file
+)
+
+type Question struct {
+    ID        string   `json:"id"`
+    Label     string   `json:"label"`
+    Type      string   `json:"type"`
+    Required  bool     `json:"required"`
+    Options   []string `json:"options,omitempty"`    // choice only
+    MaxLength int      `json:"max_length,omitempty"` // text only; 0 = default
+}
+
+// Answer keeps a copy of the question label so it still reads correctly after the form changes.
+type Answer struct {
+    QuestionID string `json:"question_id"`
+    Label      string `json:"label"`
+    Value      string `json:"value"`
 }
 
 type Application struct {
-    ID        int64  `json:"id" gorm:"primaryKey"`
-    StudentID int64  `json:"student_id" gorm:"index;uniqueIndex:uniq_student_project"`
-    ProjectID int64  `json:"project_id" gorm:"index;uniqueIndex:uniq_student_project"`
-    Status    string `json:"status" gorm:"size:32;index"`
+    ID            int64    `json:"id" gorm:"primaryKey"`
+    StudentID     int64    `json:"student_id" gorm:"index;uniqueIndex:uniq_student_project"`
+    ProjectID     int64    `json:"project_id" gorm:"index;uniqueIndex:uniq_student_project"`
+    Status        string   `json:"status" gorm:"size:32;index"`
+    RoundID       int64    `json:"round_id" gorm:"index"` // 0 when submitted outside any round
+    // StudentRank and TeacherRank are the preferences used by allocation runs (1 = first
+    // choice, 0 = unranked). Proposal is a draft run's outcome awaiting publication and
+    // ProposalFrom the status it was computed from. None of them is serialized here; each
+    // side reads its own ranks as []Rank and admins read AllocationProposal.
+    StudentRank   int      `json:"-"`
+    TeacherRank   int      `json:"-"`
+    Proposal      string   `json:"-" gorm:"size:32"`
+    ProposalFrom  string   `json:"-" gorm:"size:32"`
+    ProposalRunID int64    `json:"-" gorm:"index"`
+    CoverLetter   string   `json:"cover_letter" gorm:"type:text"`
+    Answers       []Answer `json:"answers" gorm:"serializer:json"`
+    // A team application is submitted by the leader (StudentID). Members snapshots the
+    // team, leader included, and Seats is how much capacity it takes (0 counts as 1).
+    TeamID        int64    `json:"team_id" gorm:"index"`
+    Members       []int64  `json:"members,omitempty" gorm:"serializer:json"`
+    Seats         int      `json:"seats"`
+}
+
+// Application statuses; see service/lifecycle.go for the allowed transitions.
+const (
+    StatusSubmitted  = "submitted"
+    StatusInterview  = "interview"
+    StatusWaitlisted = "waitlisted"
+    StatusApproved   = "approved"
+    StatusRejected   = "rejected"
+    StatusWithdrawn  = "withdrawn"
+    StatusCompleted  = "completed"
+    StatusExpired    = "expired" // still undecided when its round closed
+)
+
+var ApplicationStatuses = []string{StatusSubmitted, StatusInterview, StatusWaitlisted, StatusApproved, StatusRejected, StatusWithdrawn, StatusCompleted, StatusExpired}
+
+func ValidApplicationStatus(s string) bool {
+    for _, v := range ApplicationStatuses { if v == s { return true } }
+    return false
+}
+
+// RoleSystem marks status changes made automatically rather than by a user.
+const RoleSystem Role = "system"
+
+// ApplicationStatusChange is one step in an application's history. From is empty for the submission.
+type ApplicationStatusChange struct {
+    ID            int64     `json:"id" gorm:"primaryKey"`
+    ApplicationID int64     `json:"application_id" gorm:"index"`
+    From          string    `json:"from" gorm:"size:32"`
+    To            string    `json:"to" gorm:"size:32"`
+    ActorID       int64     `json:"actor_id"`
+    ActorRole     Role      `json:"actor_role" gorm:"size:32"`
+    Reason        string    `json:"reason"`
+    CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
 }
 
 type Tracking struct {
@@ -64,22 +167,329 @@ type ApplicationView struct {
     Student     *User        `json:"student"`
     Project     *Project     `json:"project"`
     Score       float64      `json:"score"`
+    Unread      int          `json:"unread"` // comments the viewer has not read yet
 }
 
 type ApplicationAnalysis struct {
-    Application *Application `json:"application"`
-    Student     *User        `json:"student"`
-    Project     *Project     `json:"project"`
-    Score       float64      `json:"score"`
-    Reason      string       `json:"reason"`
+    Application *Application    `json:"application"`
+    Student     *User           `json:"student"`
+    Project     *Project        `json:"project"`
+    Score       float64         `json:"score"`
+    Reason      string          `json:"reason"`
+    Breakdown   *MatchBreakdown `json:"breakdown,omitempty"`
 }
 
 type MatchResult struct {
-    Project *Project `json:"project"`
-    Score   float64  `json:"score"`
-    Reason  string   `json:"reason"`
+    Project   *Project        `json:"project"`
+    Score     float64         `json:"score"`
+    Reason    string          `json:"reason"`
+    Breakdown *MatchBreakdown `json:"breakdown,omitempty"`
+}
+
+// MatchBreakdown explains a score: how each requirement fared and what each part of
+// the scoring contributed. The contributions add up to the score.
+type MatchBreakdown struct {
+    Matcher        string             `json:"matcher"` // the registered name that produced the result
+    Version        string             `json:"version"`
+    Requirements   []RequirementMatch `json:"requirements"`
+    Components     []ScoreComponent   `json:"components"`
+    UnmetMandatory []string           `json:"unmet_mandatory,omitempty"`
+}
+
+// Requirement match statuses.
+const (
+    RequirementMatched = "matched"
+    RequirementPartial = "partial"
+    RequirementMissing = "missing"
+)
+
+type RequirementMatch struct {
+    Skill     string  `json:"skill"`
+    Mandatory bool    `json:"mandatory"`
+    Weight    float64 `json:"weight"`
+    MinLevel  int     `json:"min_level,omitempty"`
+    Status    string  `json:"status"`
+    Credit    float64 `json:"credit"`               // 0..1
+    By        string  `json:"by,omitempty"`         // the student's skill that earned the credit
+    Level     int     `json:"level,omitempty"`      // the student's rating of By
+    Unrated   bool    `json:"unrated,omitempty"`    // By is not rated but a level is required
+    Evidence  string  `json:"evidence,omitempty"`   // the LLM's explanation
+}
+
+type ScoreComponent struct {
+    Name         string  `json:"name"`   // e.g. requirements, text, llm, mandatory_penalty
+    Weight       float64 `json:"weight"`
+    Score        float64 `json:"score"`
+    Contribution float64 `json:"contribution"`
 }
 
 type Matcher interface {
     Match(student *User, projects []*Project) []MatchResult
 }
+
+// FallibleMatcher is implemented by matchers that can fail outright, such as a remote
+// model; the error lets a fallback chain move on to the next matcher.
+type FallibleMatcher interface {
+    TryMatch(student *User, projects []*Project) ([]MatchResult, error)
+}
+
+// HealthChecker reports whether a matcher can currently serve requests.
+type HealthChecker interface {
+    Healthy() error
+}
+
+// RequirementWeigher is implemented by matchers that apply a project's
+// RequirementDetails themselves: mandatory skills, weights and minimum levels. The
+// matcher registry wraps every matcher that does not, or reports false, so that the
+// details are applied on top of its score.
+type RequirementWeigher interface {
+    WeighsRequirements() bool
+}
+
+// RefreshToken is a rotating, single-use credential; only its SHA-256 hash is stored.
+// Tokens issued from the same login share a FamilyID so that reuse of a rotated
+// token can revoke the whole chain.
+type RefreshToken struct {
+    ID        int64      `json:"id" gorm:"primaryKey"`
+    UserID    int64      `json:"user_id" gorm:"index"`
+    FamilyID  string     `json:"family_id" gorm:"size:64;index"`
+    TokenHash string     `json:"-" gorm:"size:64;uniqueIndex"`
+    ExpiresAt time.Time  `json:"expires_at"`
+    RevokedAt *time.Time `json:"revoked_at"`
+    CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// RevokedToken records an access token ID (jti) that must be rejected until it expires.
+type RevokedToken struct {
+    JTI       string    `json:"jti" gorm:"primaryKey;size:64"`
+    ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
+
+// UserTokenCutoff invalidates every access token of a user issued before RevokedAt,
+// e.g. after a role change.
+type UserTokenCutoff struct {
+    UserID    int64     `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+    RevokedAt time.Time `json:"revoked_at"`
+}
+
+const (
+    KeyStatusActive   = "active"   // signs new tokens
+    KeyStatusPrevious = "previous" // superseded, still verifies and is published in JWKS
+    KeyStatusRetired  = "retired"  // rejected everywhere
+)
+
+// SigningKey is an asymmetric JWT signing key identified by its kid.
+type SigningKey struct {
+    KID        string     `json:"kid" gorm:"primaryKey;size:64"`
+    Alg        string     `json:"alg" gorm:"size:16"`
+    PrivateKey string     `json:"-" gorm:"type:text"`
+    PublicKey  string     `json:"public_key" gorm:"type:text"`
+    Status     string     `json:"status" gorm:"size:16;index"`
+    CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+    RotatedAt  *time.Time `json:"rotated_at"`
+}
+
+const (
+    TokenPurposeVerifyEmail   = "verify_email"
+    TokenPurposeResetPassword = "reset_password"
+    TokenPurposeCalendarFeed  = "calendar_feed"
+)
+
+// UserToken is a single-use, time-limited token sent by mail; only its hash is stored.
+// A calendar feed token is the exception: it is read on every fetch until revoked.
+type UserToken struct {
+    ID        int64      `json:"id" gorm:"primaryKey"`
+    UserID    int64      `json:"user_id" gorm:"index"`
+    Purpose   string     `json:"purpose" gorm:"size:32;index"`
+    TokenHash string     `json:"-" gorm:"size:64;uniqueIndex"`
+    ExpiresAt time.Time  `json:"expires_at"`
+    UsedAt    *time.Time `json:"used_at"`
+    CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// LoginThrottle counts failed logins for one key ("account:<email>" or "ip:<addr>").
+type LoginThrottle struct {
+    Key         string     `json:"key" gorm:"primaryKey;size:191"`
+    Failures    int        `json:"failures"`
+    LastFailure time.Time  `json:"last_failure"`
+    LockedUntil *time.Time `json:"locked_until" gorm:"index"`
+}
+
+// TwoFactor holds a user's TOTP enrollment. Enabled stays false until the first code is confirmed.
+type TwoFactor struct {
+    UserID    int64      `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+    Secret    string     `json:"-" gorm:"size:64"`
+    Enabled   bool       `json:"enabled"`
+    LastStep  int64      `json:"-"`
+    EnabledAt *time.Time `json:"enabled_at"`
+}
+
+type RecoveryCode struct {
+    ID       int64      `json:"id" gorm:"primaryKey"`
+    UserID   int64      `json:"user_id" gorm:"index"`
+    CodeHash string     `json:"-" gorm:"size:64;index"`
+    UsedAt   *time.Time `json:"used_at"`
+}
+
+// TwoFactorPolicy makes 2FA mandatory for every user of a role.
+type TwoFactorPolicy struct {
+    Role     Role `json:"role" gorm:"primaryKey;size:32"`
+    Required bool `json:"required"`
+}
+
+// ExternalIdentity links a user to an account at an external identity provider.
+type ExternalIdentity struct {
+    ID       int64  `json:"id" gorm:"primaryKey"`
+    Provider string `json:"provider" gorm:"size:32;uniqueIndex:uniq_provider_subject"`
+    Subject  string `json:"subject" gorm:"size:191;uniqueIndex:uniq_provider_subject"`
+    UserID   int64  `json:"user_id" gorm:"index"`
+}
+
+// SSOLoginState carries the PKCE verifier and nonce between the redirect to the IdP
+// and its callback.
+type SSOLoginState struct {
+    State     string    `gorm:"primaryKey;size:64"`
+    Verifier  string    `gorm:"size:128"`
+    Nonce     string    `gorm:"size:64"`
+    ExpiresAt time.Time `gorm:"index"`
+}
+
+// PersonalToken is a long-lived API credential ("pat_...") limited to Scopes. Only
+// the hash is stored; Prefix lets users tell their tokens apart.
+type PersonalToken struct {
+    ID         int64      `json:"id" gorm:"primaryKey"`
+    UserID     int64      `json:"user_id" gorm:"index"`
+    Name       string     `json:"name" gorm:"size:64"`
+    Prefix     string     `json:"prefix" gorm:"size:16"`
+    TokenHash  string     `json:"-" gorm:"size:64;uniqueIndex"`
+    Scopes     []string   `json:"scopes" gorm:"serializer:json"`
+    ExpiresAt  time.Time  `json:"expires_at"`
+    LastUsedAt *time.Time `json:"last_used_at"`
+    RevokedAt  *time.Time `json:"revoked_at"`
+    CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// AuditLog records administrative actions on behalf of other users.
+type AuditLog struct {
+    ID        int64     `json:"id" gorm:"primaryKey"`
+    ActorID   int64     `json:"actor_id" gorm:"index"`   // the admin who acted
+    SubjectID int64     `json:"subject_id" gorm:"index"` // the user acted as
+    Action    string    `json:"action" gorm:"size:64"`
+    Method    string    `json:"method,omitempty" gorm:"size:8"`
+    Path      string    `json:"path,omitempty" gorm:"size:255"`
+    Status    int       `json:"status,omitempty"`
+    IP        string    `json:"ip,omitempty" gorm:"size:64"`
+    TokenID   string    `json:"token_id,omitempty" gorm:"size:64;index"`
+    Detail    string    `json:"detail,omitempty"`
+    CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+// Round is a selection window. While any round exists, applications are only accepted
+// inside an open one.
+type Round struct {
+    ID              int64     `json:"id" gorm:"primaryKey"`
+    Name            string    `json:"name" gorm:"size:64"`
+    OpensAt         time.Time `json:"opens_at" gorm:"index"`
+    ClosesAt        time.Time `json:"closes_at" gorm:"index"`
+    MaxApplications int       `json:"max_applications"` // per student in this round, 0 = unlimited
+    Closed          bool      `json:"closed" gorm:"index"` // set once the close job has expired its applications
+}
+
+const (
+    AllocationDraft     = "draft"
+    AllocationPublished = "published"
+    AllocationDiscarded = "discarded"
+)
+
+// AllocationRun is one stable-matching pass over open applications.
+type AllocationRun struct {
+    ID          int64      `json:"id" gorm:"primaryKey"`
+    RoundID     int64      `json:"round_id"` // 0 = all open applications
+    Status      string     `json:"status" gorm:"size:16;index"`
+    CreatedBy   int64      `json:"created_by"`
+    Matched     int        `json:"matched"`
+    Unmatched   int        `json:"unmatched"`
+    CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+    PublishedAt *time.Time `json:"published_at"`
+}
+
+// AllocationProposal is an application with a draft run's outcome, as admins review it.
+type AllocationProposal struct {
+    *Application
+    StudentRank  int    `json:"student_rank"`
+    TeacherRank  int    `json:"teacher_rank"`
+    Proposal     string `json:"proposal"`
+    ProposalFrom string `json:"proposal_from"`
+}
+
+// Rank is one entry of a student's or a project's preference order.
+type Rank struct {
+    ApplicationID int64 `json:"application_id"`
+    ProjectID     int64 `json:"project_id"`
+    Rank          int   `json:"rank"`
+}
+
+// InterviewSlot is a time offered for interviews on a project. ApplicationID is the
+// booking; a slot held by an application no longer in interview counts as free.
+type InterviewSlot struct {
+    ID            int64     `json:"id" gorm:"primaryKey"`
+    ProjectID     int64     `json:"project_id" gorm:"index"`
+    StartsAt      time.Time `json:"starts_at" gorm:"index"`
+    EndsAt        time.Time `json:"ends_at"`
+    Location      string    `json:"location"`
+    ApplicationID int64     `json:"application_id" gorm:"index"`
+    CreatedBy     int64     `json:"created_by"`
+}
+
+type Team struct {
+    ID        int64     `json:"id" gorm:"primaryKey"`
+    Name      string    `json:"name" gorm:"size:64"`
+    LeaderID  int64     `json:"leader_id" gorm:"index"`
+    CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+const (
+    TeamMemberInvited = "invited"
+    TeamMemberActive  = "active"
+)
+
+type TeamMember struct {
+    ID        int64     `json:"id" gorm:"primaryKey"`
+    TeamID    int64     `json:"team_id" gorm:"uniqueIndex:uniq_team_user"`
+    UserID    int64     `json:"user_id" gorm:"uniqueIndex:uniq_team_user;index"`
+    Status    string    `json:"status" gorm:"size:16"`
+    InvitedBy int64     `json:"invited_by"`
+    CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// ApplicationComment is a message in an application's thread. Deleted comments keep
+// their place in the thread but lose their body.
+type ApplicationComment struct {
+    ID            int64      `json:"id" gorm:"primaryKey"`
+    ApplicationID int64      `json:"application_id" gorm:"index"`
+    AuthorID      int64      `json:"author_id"`
+    Body          string     `json:"body" gorm:"type:text"`
+    CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
+    EditedAt      *time.Time `json:"edited_at"`
+    DeletedAt     *time.Time `json:"deleted_at" gorm:"index"`
+}
+
+// CommentRead is how far a user has read an application's thread.
+type CommentRead struct {
+    ID            int64     `json:"-" gorm:"primaryKey"`
+    ApplicationID int64     `json:"application_id" gorm:"uniqueIndex:uniq_comment_read"`
+    UserID        int64     `json:"user_id" gorm:"uniqueIndex:uniq_comment_read"`
+    LastReadID    int64     `json:"last_read_id"`
+    ReadAt        time.Time `json:"read_at"`
+}
+
+// Skill is an entry of the admin-managed skill taxonomy. Users and projects store the
+// lowercased Name; Aliases (in any language) are rewritten to it when saved. ParentID
+// places the skill under a broader one, e.g. react under frontend.
+type Skill struct {
+    ID        int64     `json:"id" gorm:"primaryKey"`
+    Name      string    `json:"name" gorm:"size:64;uniqueIndex"`
+    Aliases   []string  `json:"aliases" gorm:"serializer:json"`
+    ParentID  int64     `json:"parent_id" gorm:"index"` // 0 = top level
+    CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
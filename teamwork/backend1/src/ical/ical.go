@@ -0,0 +1,71 @@
+// Package ical writes iCalendar (RFC 5545) feeds that calendar apps can subscribe to.
+package ical
+
+import (
+    "bufio"
+    "io"
+    "strings"
+    "time"
+)
+
+type Event struct {
+    UID         string
+    Start       time.Time
+    End         time.Time
+    Summary     string
+    Location    string
+    Description string
+    Updated     time.Time // DTSTAMP; now when zero
+}
+
+// Write renders events as a VCALENDAR named name. Times are written in UTC.
+func Write(w io.Writer, name string, events []Event) error {
+    bw := bufio.NewWriter(w)
+    line := func(s string) { bw.WriteString(fold(s)) }
+    line("BEGIN:VCALENDAR")
+    line("VERSION:2.0")
+    line("PRODID:-//SoftwareConstructionExp//Interviews//ZH")
+    line("CALSCALE:GREGORIAN")
+    line("METHOD:PUBLISH")
+    line("X-WR-CALNAME:" + escape(name))
+    now := time.Now()
+    for _, e := range events {
+        stamp := e.Updated
+        if stamp.IsZero() { stamp = now }
+        line("BEGIN:VEVENT")
+        line("UID:" + escape(e.UID))
+        line("DTSTAMP:" + utc(stamp))
+        line("DTSTART:" + utc(e.Start))
+        line("DTEND:" + utc(e.End))
+        line("SUMMARY:" + escape(e.Summary))
+        if e.Location != "" { line("LOCATION:" + escape(e.Location)) }
+        if e.Description != "" { line("DESCRIPTION:" + escape(e.Description)) }
+        line("END:VEVENT")
+    }
+    line("END:VCALENDAR")
+    return bw.Flush()
+}
+
+func utc(t time.Time) string { return t.UTC().Format("20060102T150405Z") }
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(s string) string { return escaper.Replace(s) }
+
+// fold ends a content line with CRLF, wrapping it at 75 octets without splitting a
+// UTF-8 sequence; continuation lines start with a space.
+func fold(s string) string {
+    var b strings.Builder
+    n := 0
+    for _, r := range s {
+        size := len(string(r))
+        if n+size > 75 {
+            b.WriteString("\r\n ")
+            n = 1
+        }
+        b.WriteRune(r)
+        n += size
+    }
+    b.WriteString("\r\n")
+    return b.String()
+}
@@ -0,0 +1,66 @@
+package ical
+
+import (
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestEscape(t *testing.T) {
+    for _, c := range []struct{ in, want string }{
+        {"plain", "plain"},
+        {`a\b`, `a\\b`},
+        {"a;b,c", `a\;b\,c`},
+        {"line1\nline2", `line1\nline2`},
+        {"line1\r\nline2", `line1\nline2`},
+    } {
+        if got := escape(c.in); got != c.want { t.Errorf("escape(%q) = %q, want %q", c.in, got, c.want) }
+    }
+}
+
+func TestFoldKeepsLinesWithin75Octets(t *testing.T) {
+    for _, in := range []string{strings.Repeat("a", 200), "SUMMARY:" + strings.Repeat("面试", 40)} {
+        out := fold(in)
+        if !strings.HasSuffix(out, "\r\n") { t.Fatalf("%q does not end with CRLF", out) }
+        lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+        for i, l := range lines {
+            if len(l) > 75 { t.Errorf("line %d has %d octets", i, len(l)) }
+            if i > 0 && !strings.HasPrefix(l, " ") { t.Errorf("continuation line %d does not start with a space", i) }
+        }
+        var joined strings.Builder
+        for i, l := range lines {
+            if i > 0 { l = l[1:] }
+            joined.WriteString(l)
+        }
+        if joined.String() != in { t.Errorf("unfolding gave %q, want %q", joined.String(), in) }
+    }
+    if got := fold("short"); got != "short\r\n" { t.Errorf("fold(short) = %q", got) }
+}
+
+func TestWriteEvent(t *testing.T) {
+    start := time.Date(2026, 3, 2, 9, 30, 0, 0, time.FixedZone("CST", 8*3600))
+    var b strings.Builder
+    err := Write(&b, "面试", []Event{{
+        UID: "slot-1@test", Start: start, End: start.Add(30 * time.Minute), Summary: "面试: 项目A, 第一轮",
+        Location: "A101", Updated: start,
+    }})
+    if err != nil { t.Fatal(err) }
+    want := strings.Join([]string{
+        "BEGIN:VCALENDAR",
+        "VERSION:2.0",
+        "PRODID:-//SoftwareConstructionExp//Interviews//ZH",
+        "CALSCALE:GREGORIAN",
+        "METHOD:PUBLISH",
+        "X-WR-CALNAME:面试",
+        "BEGIN:VEVENT",
+        "UID:slot-1@test",
+        "DTSTAMP:20260302T013000Z",
+        "DTSTART:20260302T013000Z",
+        "DTEND:20260302T020000Z",
+        `SUMMARY:面试: 项目A\, 第一轮`,
+        "LOCATION:A101",
+        "END:VEVENT",
+        "END:VCALENDAR",
+    }, "\r\n") + "\r\n"
+    if b.String() != want { t.Errorf("got\n%q\nwant\n%q", b.String(), want) }
+}
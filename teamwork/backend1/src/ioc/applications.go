@@ -0,0 +1,15 @@
+package ioc
+
+import (
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/config"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+func NewReapplyRules() service.ReapplyRules {
+    cfg, err := config.Load()
+    if err != nil { panic(err) }
+    r := cfg.Applications.Reapply
+    return service.ReapplyRules{AfterWithdrawn: r.AfterWithdrawn, AfterRejected: r.AfterRejected, Cooldown: time.Duration(r.CooldownHours) * time.Hour, MaxSubmissions: r.MaxSubmissions}
+}
@@ -11,10 +11,16 @@ func NewGormDB() *gorm.DB {
     cfg, err := config.Load()
     if err != nil { panic(err) }
     if cfg.Database == "" { panic("missing mysql dsn in config") }
-    db, err := gorm.Open(mysql.Open(cfg.Database), &gorm.Config{})
+    // TranslateError reports unique index violations as gorm.ErrDuplicatedKey
+    db, err := gorm.Open(mysql.Open(cfg.Database), &gorm.Config{TranslateError: true})
     if err != nil { panic(err) }
-    if err := db.AutoMigrate(&domain.User{}, &domain.Project{}, &domain.Application{}, &domain.Tracking{}, &domain.Feedback{}, &domain.Document{}); err != nil {
+    // accounts created before email verification existed are trusted as verified
+    backfillVerified := db.Migrator().HasTable(&domain.User{}) && !db.Migrator().HasColumn(&domain.User{}, "EmailVerified")
+    if err := db.AutoMigrate(&domain.User{}, &domain.Project{}, &domain.Application{}, &domain.Tracking{}, &domain.Feedback{}, &domain.Document{}, &domain.RefreshToken{}, &domain.RevokedToken{}, &domain.UserTokenCutoff{}, &domain.SigningKey{}, &domain.UserToken{}, &domain.LoginThrottle{}, &domain.TwoFactor{}, &domain.RecoveryCode{}, &domain.TwoFactorPolicy{}, &domain.ExternalIdentity{}, &domain.SSOLoginState{}, &domain.PersonalToken{}, &domain.AuditLog{}, &domain.ApplicationStatusChange{}, &domain.Round{}, &domain.AllocationRun{}, &domain.InterviewSlot{}, &domain.Team{}, &domain.TeamMember{}, &domain.ApplicationComment{}, &domain.CommentRead{}, &domain.Skill{}); err != nil {
         panic(err)
     }
+    if backfillVerified {
+        if err := db.Model(&domain.User{}).Where("1 = 1").Update("email_verified", true).Error; err != nil { panic(err) }
+    }
     return db
-}
\ No newline at end of file
+}
@@ -0,0 +1,18 @@
+package ioc
+
+import (
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/auth"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/config"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+    "gorm.io/gorm"
+)
+
+func NewKeySet(db *gorm.DB) *auth.KeySet {
+    cfg, err := config.Load()
+    if err != nil { panic(err) }
+    ks, err := auth.NewKeySet(repository.NewKeyRepo(db), cfg.JWT.Algorithm, time.Duration(cfg.JWT.RotateHours)*time.Hour, time.Duration(cfg.JWT.RetireHours)*time.Hour)
+    if err != nil { panic(err) }
+    return ks
+}
@@ -0,0 +1,35 @@
+package ioc
+
+import (
+    "crypto/tls"
+    "crypto/x509"
+    "os"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/config"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/ldapdir"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+// NewAuthProviders returns the configured login providers and, if "ldap" is among
+// them, the directory client and its attribute mapping.
+func NewAuthProviders() ([]string, ldapdir.Directory, service.LDAPOptions) {
+    cfg, err := config.Load()
+    if err != nil { panic(err) }
+    uses := false
+    for _, p := range cfg.Auth.Providers { if p == "ldap" { uses = true } }
+    if !uses { return cfg.Auth.Providers, nil, service.LDAPOptions{} }
+    l := cfg.Auth.LDAP
+    if l.URL == "" || l.BaseDN == "" { panic("missing ldap url or base_dn in config") }
+    tc := &tls.Config{}
+    if l.CAFile != "" {
+        pem, err := os.ReadFile(l.CAFile)
+        if err != nil { panic(err) }
+        tc.RootCAs = x509.NewCertPool()
+        if !tc.RootCAs.AppendCertsFromPEM(pem) { panic("no certificates in ldap ca_file") }
+    }
+    dir := &ldapdir.Client{URL: l.URL, BindDN: l.BindDN, BindPassword: l.BindPassword, BaseDN: l.BaseDN, StartTLS: l.StartTLS, TLSConfig: tc}
+    mapping := map[string]domain.Role{}
+    for g, r := range l.RoleMapping { mapping[g] = domain.Role(r) }
+    return cfg.Auth.Providers, dir, service.LDAPOptions{UserFilter: l.UserFilter, NameAttr: l.NameAttr, EmailAttr: l.EmailAttr, GroupAttr: l.GroupAttr, RoleMapping: mapping, DefaultRole: domain.Role(l.DefaultRole)}
+}
@@ -0,0 +1,17 @@
+package ioc
+
+import (
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/config"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+// NewLLMMatcher returns nil when no API key is configured, leaving the llm matcher
+// the service was built with in place.
+func NewLLMMatcher() domain.Matcher {
+    cfg, err := config.Load()
+    if err != nil { panic(err) }
+    o := cfg.OpenAI
+    if o.DeepseekAPIKey == "" { return nil }
+    return service.NewLLMMatcher(o.BaseURL, o.DeepseekAPIKey, o.Model)
+}
@@ -0,0 +1,18 @@
+package ioc
+
+import (
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/config"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/mailer"
+)
+
+func NewMailer() mailer.Mailer {
+    cfg, err := config.Load()
+    if err != nil { panic(err) }
+    m := cfg.Mail
+    if m.Driver == "smtp" {
+        if m.Host == "" || m.From == "" { panic("missing smtp host or from address in config") }
+        if m.Port == 0 { m.Port = 587 }
+        return &mailer.SMTPMailer{Host: m.Host, Port: m.Port, Username: m.Username, Password: m.Password, From: m.From}
+    }
+    return &mailer.LogMailer{Path: m.LogPath}
+}
@@ -0,0 +1,12 @@
+package ioc
+
+import (
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/config"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+func NewMatcherOptions() service.MatcherOptions {
+    cfg, err := config.Load()
+    if err != nil { panic(err) }
+    return service.MatcherOptions{Default: cfg.Matching.Default, Fallback: cfg.Matching.Fallback, LLM: NewLLMMatcher()}
+}
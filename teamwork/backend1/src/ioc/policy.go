@@ -0,0 +1,20 @@
+package ioc
+
+import (
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/config"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/policy"
+)
+
+func NewPolicy() *policy.Policy {
+    cfg, err := config.Load()
+    if err != nil { panic(err) }
+    var rules []policy.Rule
+    if !cfg.Policy.ReplaceDefaults { rules = policy.Default() }
+    for _, r := range cfg.Policy.Rules {
+        rules = append(rules, policy.Rule{Role: domain.Role(r.Role), Action: policy.Action(r.Action), Resource: policy.Resource(r.Resource), Relation: policy.Relation(r.Relation), Status: r.Status})
+    }
+    p, err := policy.New(rules)
+    if err != nil { panic(err) }
+    return p
+}
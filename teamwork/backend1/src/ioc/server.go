@@ -0,0 +1,10 @@
+package ioc
+
+import "github.com/bugoutianzhen123/SoftwareConstructionExp/config"
+
+// NewTrustedProxies lists the reverse proxies whose forwarded client address is used.
+func NewTrustedProxies() []string {
+    cfg, err := config.Load()
+    if err != nil { panic(err) }
+    return cfg.Server.TrustedProxies
+}
@@ -0,0 +1,21 @@
+package ioc
+
+import (
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/auth"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/config"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+// NewOIDC returns nil when single sign-on is disabled.
+func NewOIDC() (*auth.OIDCProvider, service.SSOOptions) {
+    cfg, err := config.Load()
+    if err != nil { panic(err) }
+    o := cfg.OIDC
+    if !o.Enabled { return nil, service.SSOOptions{} }
+    if o.Issuer == "" || o.ClientID == "" || o.RedirectURL == "" { panic("missing oidc issuer, client_id or redirect_url in config") }
+    mapping := map[string]domain.Role{}
+    for g, r := range o.RoleMapping { mapping[g] = domain.Role(r) }
+    p := auth.NewOIDCProvider(auth.OIDCConfig{Issuer: o.Issuer, ClientID: o.ClientID, ClientSecret: o.ClientSecret, RedirectURL: o.RedirectURL, Scopes: o.Scopes})
+    return p, service.SSOOptions{GroupsClaim: o.GroupsClaim, RoleMapping: mapping, DefaultRole: domain.Role(o.DefaultRole), FrontendURL: o.FrontendURL}
+}
@@ -0,0 +1,59 @@
+package ldapdir
+
+import (
+    "crypto/tls"
+    "fmt"
+    "net"
+    "time"
+
+    "github.com/go-ldap/ldap/v3"
+)
+
+// Client talks to a real directory; every call opens its own connection.
+type Client struct {
+    URL          string // ldap://host:389 or ldaps://host:636
+    BindDN       string // service account used for searches; empty for anonymous search
+    BindPassword string
+    BaseDN       string
+    StartTLS     bool
+    TLSConfig    *tls.Config
+}
+
+func (c *Client) dial() (*ldap.Conn, error) {
+    conn, err := ldap.DialURL(c.URL, ldap.DialWithDialer(&net.Dialer{Timeout: 3 * time.Second}), ldap.DialWithTLSConfig(c.TLSConfig))
+    if err != nil { return nil, err }
+    conn.SetTimeout(3 * time.Second)
+    if c.StartTLS {
+        if err := conn.StartTLS(c.TLSConfig); err != nil { conn.Close(); return nil, err }
+    }
+    return conn, nil
+}
+
+func (c *Client) Find(filter string, attrs []string) (*Entry, error) {
+    conn, err := c.dial()
+    if err != nil { return nil, err }
+    defer conn.Close()
+    if c.BindDN != "" {
+        if err := conn.Bind(c.BindDN, c.BindPassword); err != nil { return nil, fmt.Errorf("ldap service bind: %w", err) }
+    }
+    req := ldap.NewSearchRequest(c.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 5, false, filter, attrs, nil)
+    res, err := conn.Search(req)
+    if err != nil { return nil, err }
+    if len(res.Entries) == 0 { return nil, ErrNotFound }
+    if len(res.Entries) > 1 { return nil, fmt.Errorf("ldap: filter %s matched several entries", filter) }
+    e := &Entry{DN: res.Entries[0].DN, Attrs: map[string][]string{}}
+    for _, a := range res.Entries[0].Attributes { e.Attrs[a.Name] = a.Values }
+    return e, nil
+}
+
+func (c *Client) Bind(dn, password string) error {
+    if dn == "" || password == "" { return ErrInvalidCredentials }
+    conn, err := c.dial()
+    if err != nil { return err }
+    defer conn.Close()
+    if err := conn.Bind(dn, password); err != nil {
+        if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) { return ErrInvalidCredentials }
+        return err
+    }
+    return nil
+}
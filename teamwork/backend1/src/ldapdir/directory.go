@@ -0,0 +1,55 @@
+// Package ldapdir looks users up in an LDAP/Active Directory server. Memory is an
+// in-process stand-in with the same behaviour for local development and tests.
+package ldapdir
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+)
+
+var (
+    ErrNotFound           = errors.New("ldap: entry not found")
+    ErrInvalidCredentials = errors.New("ldap: invalid credentials")
+)
+
+type Entry struct {
+    DN    string
+    Attrs map[string][]string
+}
+
+// First returns the first value of attr, or "".
+func (e *Entry) First(attr string) string {
+    for k, v := range e.Attrs {
+        if strings.EqualFold(k, attr) && len(v) > 0 { return v[0] }
+    }
+    return ""
+}
+
+func (e *Entry) Values(attr string) []string {
+    for k, v := range e.Attrs { if strings.EqualFold(k, attr) { return v } }
+    return nil
+}
+
+type Directory interface {
+    // Find returns the single entry matching filter, searched with the service account.
+    Find(filter string, attrs []string) (*Entry, error)
+    // Bind verifies the password of dn. An empty password is always rejected so an
+    // unauthenticated bind can never pass as a login.
+    Bind(dn, password string) error
+}
+
+// EscapeFilter escapes a value for use inside an LDAP filter (RFC 4515).
+func EscapeFilter(v string) string {
+    var b strings.Builder
+    for i := 0; i < len(v); i++ {
+        c := v[i]
+        switch {
+        case c == '*' || c == '(' || c == ')' || c == '\\' || c == 0 || c >= 0x80:
+            fmt.Fprintf(&b, "\\%02x", c)
+        default:
+            b.WriteByte(c)
+        }
+    }
+    return b.String()
+}
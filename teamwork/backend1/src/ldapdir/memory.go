@@ -0,0 +1,91 @@
+package ldapdir
+
+import (
+    "errors"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// Memory is an in-process directory. Find understands equality filters and "&" of
+// equality filters, which is what the login flow sends.
+type Memory struct {
+    mu        sync.RWMutex
+    entries   []*Entry
+    passwords map[string]string
+}
+
+func NewMemory() *Memory { return &Memory{passwords: map[string]string{}} }
+
+func (m *Memory) Add(e *Entry, password string) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    m.entries = append(m.entries, e)
+    m.passwords[strings.ToLower(e.DN)] = password
+}
+
+func (m *Memory) Find(filter string, attrs []string) (*Entry, error) {
+    conds, err := parseFilter(filter)
+    if err != nil { return nil, err }
+    m.mu.RLock(); defer m.mu.RUnlock()
+    var found *Entry
+    for _, e := range m.entries {
+        if !matchAll(e, conds) { continue }
+        if found != nil { return nil, errors.New("ldap: filter matched several entries") }
+        found = e
+    }
+    if found == nil { return nil, ErrNotFound }
+    return found, nil
+}
+
+func (m *Memory) Bind(dn, password string) error {
+    if dn == "" || password == "" { return ErrInvalidCredentials }
+    m.mu.RLock(); defer m.mu.RUnlock()
+    if pw, ok := m.passwords[strings.ToLower(dn)]; !ok || pw != password { return ErrInvalidCredentials }
+    return nil
+}
+
+type cond struct {
+    attr, value string
+    present     bool // (attr=*)
+}
+
+func parseFilter(f string) ([]cond, error) {
+    f = strings.TrimSpace(f)
+    if strings.HasPrefix(f, "(&") && strings.HasSuffix(f, ")") {
+        var out []cond
+        rest := f[2 : len(f)-1]
+        for rest != "" {
+            end := strings.IndexByte(rest, ')')
+            if rest[0] != '(' || end < 0 { return nil, errors.New("ldap: unsupported filter " + f) }
+            c, err := parseFilter(rest[:end+1])
+            if err != nil { return nil, err }
+            out = append(out, c...)
+            rest = rest[end+1:]
+        }
+        return out, nil
+    }
+    if !strings.HasPrefix(f, "(") || !strings.HasSuffix(f, ")") { return nil, errors.New("ldap: unsupported filter " + f) }
+    attr, value, ok := strings.Cut(f[1:len(f)-1], "=")
+    if !ok { return nil, errors.New("ldap: unsupported filter " + f) }
+    return []cond{{attr: attr, value: unescapeFilter(value), present: value == "*"}}, nil
+}
+
+func matchAll(e *Entry, conds []cond) bool {
+    for _, c := range conds {
+        ok := false
+        for _, v := range e.Values(c.attr) { if c.present || strings.EqualFold(v, c.value) { ok = true; break } }
+        if !ok { return false }
+    }
+    return true
+}
+
+func unescapeFilter(v string) string {
+    var b strings.Builder
+    for i := 0; i < len(v); i++ {
+        if v[i] == '\\' && i+2 < len(v) {
+            if n, err := strconv.ParseUint(v[i+1:i+3], 16, 8); err == nil { b.WriteByte(byte(n)); i += 2; continue }
+        }
+        b.WriteByte(v[i])
+    }
+    return b.String()
+}
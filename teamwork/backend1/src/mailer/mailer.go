@@ -0,0 +1,56 @@
+package mailer
+
+import (
+    "fmt"
+    "log"
+    "net/smtp"
+    "os"
+    "strings"
+    "sync"
+    "time"
+)
+
+type Mailer interface {
+    Send(to, subject, body string) error
+}
+
+// SMTPMailer delivers plain-text mail through an SMTP relay (STARTTLS when offered).
+type SMTPMailer struct {
+    Host     string
+    Port     int
+    Username string
+    Password string
+    From     string
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+    var a smtp.Auth
+    if m.Username != "" { a = smtp.PlainAuth("", m.Username, m.Password, m.Host) }
+    msg := strings.Join([]string{
+        "From: " + m.From,
+        "To: " + to,
+        "Subject: " + subject,
+        "MIME-Version: 1.0",
+        "Content-Type: text/plain; charset=UTF-8",
+        "",
+        body,
+    }, "\r\n")
+    return smtp.SendMail(fmt.Sprintf("%s:%d", m.Host, m.Port), a, m.From, []string{to}, []byte(msg))
+}
+
+// LogMailer writes mails to a file (or the standard logger when Path is empty) for local testing.
+type LogMailer struct {
+    Path string
+    mu   sync.Mutex
+}
+
+func (m *LogMailer) Send(to, subject, body string) error {
+    entry := fmt.Sprintf("---- %s\nTo: %s\nSubject: %s\n\n%s\n", time.Now().Format(time.RFC3339), to, subject, body)
+    if m.Path == "" { log.Print(entry); return nil }
+    m.mu.Lock(); defer m.mu.Unlock()
+    f, err := os.OpenFile(m.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+    if err != nil { return err }
+    defer f.Close()
+    _, err = f.WriteString(entry)
+    return err
+}
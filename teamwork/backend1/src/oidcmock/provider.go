@@ -0,0 +1,138 @@
+// Package oidcmock is an in-process OpenID Connect provider for local development and
+// tests. It signs in a fixed user without asking, so never expose it in production.
+//
+//    idp := oidcmock.New(oidcmock.User{Subject: "s1", Email: "a@example.edu", Name: "A", Groups: []string{"students"}})
+//    srv := httptest.NewServer(idp)
+//    idp.SetIssuer(srv.URL)
+package oidcmock
+
+import (
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "math/big"
+    "net/http"
+    "net/url"
+    "sync"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+)
+
+type User struct {
+    Subject string
+    Email   string
+    Name    string
+    Groups  []string
+}
+
+type pendingCode struct {
+    clientID, redirectURI, challenge, nonce string
+    user                                    User
+}
+
+type Provider struct {
+    mu     sync.Mutex
+    issuer string
+    user   User
+    key    *rsa.PrivateKey
+    codes  map[string]pendingCode
+    mux    *http.ServeMux
+}
+
+func New(u User) *Provider {
+    key, err := rsa.GenerateKey(rand.Reader, 2048)
+    if err != nil { panic(err) }
+    p := &Provider{user: u, key: key, codes: map[string]pendingCode{}, mux: http.NewServeMux()}
+    p.mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+    p.mux.HandleFunc("/authorize", p.authorize)
+    p.mux.HandleFunc("/token", p.token)
+    p.mux.HandleFunc("/jwks", p.jwks)
+    return p
+}
+
+// SetIssuer must be called with the server's base URL before the first request.
+func (p *Provider) SetIssuer(issuer string) { p.mu.Lock(); p.issuer = issuer; p.mu.Unlock() }
+
+// SetUser changes who is signed in by the next authorization request.
+func (p *Provider) SetUser(u User) { p.mu.Lock(); p.user = u; p.mu.Unlock() }
+
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) { p.mux.ServeHTTP(w, r) }
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+    p.mu.Lock(); iss := p.issuer; p.mu.Unlock()
+    writeJSON(w, 200, map[string]any{
+        "issuer":                                iss,
+        "authorization_endpoint":                iss + "/authorize",
+        "token_endpoint":                        iss + "/token",
+        "jwks_uri":                              iss + "/jwks",
+        "response_types_supported":              []string{"code"},
+        "code_challenge_methods_supported":      []string{"S256"},
+        "id_token_signing_alg_values_supported": []string{"RS256"},
+    })
+}
+
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+        http.Error(w, "unsupported request", http.StatusBadRequest); return
+    }
+    code := randomID()
+    p.mu.Lock()
+    p.codes[code] = pendingCode{clientID: q.Get("client_id"), redirectURI: q.Get("redirect_uri"), challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), user: p.user}
+    p.mu.Unlock()
+    back, err := url.Parse(q.Get("redirect_uri"))
+    if err != nil { http.Error(w, "bad redirect_uri", http.StatusBadRequest); return }
+    v := back.Query()
+    v.Set("code", code)
+    v.Set("state", q.Get("state"))
+    back.RawQuery = v.Encode()
+    http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+    if err := r.ParseForm(); err != nil { writeJSON(w, 400, map[string]string{"error": "invalid_request"}); return }
+    p.mu.Lock()
+    pc, ok := p.codes[r.PostForm.Get("code")]
+    delete(p.codes, r.PostForm.Get("code"))
+    iss := p.issuer
+    p.mu.Unlock()
+    sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+    if !ok || pc.redirectURI != r.PostForm.Get("redirect_uri") || base64.RawURLEncoding.EncodeToString(sum[:]) != pc.challenge {
+        writeJSON(w, 400, map[string]string{"error": "invalid_grant"}); return
+    }
+    now := time.Now()
+    tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+        "iss": iss, "aud": pc.clientID, "sub": pc.user.Subject, "nonce": pc.nonce,
+        "email": pc.user.Email, "email_verified": true, "name": pc.user.Name, "groups": pc.user.Groups,
+        "iat": now.Unix(), "exp": now.Add(5 * time.Minute).Unix(),
+    })
+    tok.Header["kid"] = "mock"
+    idToken, err := tok.SignedString(p.key)
+    if err != nil { writeJSON(w, 500, map[string]string{"error": "server_error"}); return }
+    writeJSON(w, 200, map[string]any{"access_token": randomID(), "token_type": "Bearer", "expires_in": 300, "id_token": idToken})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+    pub := p.key.PublicKey
+    writeJSON(w, 200, map[string]any{"keys": []map[string]string{{
+        "kty": "RSA", "kid": "mock", "use": "sig", "alg": "RS256",
+        "n": base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+        "e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+    }}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    _ = json.NewEncoder(w).Encode(v)
+}
+
+func randomID() string {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil { panic(err) }
+    return hex.EncodeToString(b)
+}
@@ -0,0 +1,147 @@
+// Package policy decides who may do what to projects and applications. A decision
+// depends on the user's role, the action, the resource and how the user relates to
+// that resource (owner, co-supervisor, applicant, or just anyone).
+package policy
+
+import (
+    "fmt"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+type Action string
+
+const (
+    ActRead     Action = "read"
+    ActCreate   Action = "create"
+    ActUpdate   Action = "update"
+    ActDelete   Action = "delete"
+    ActArchive  Action = "archive"
+    ActDecide   Action = "decide"   // change an application's status
+    ActTrack    Action = "track"    // add a progress entry
+    ActFeedback Action = "feedback" // rate the applicant
+    ActWithdraw Action = "withdraw" // take back one's own application
+    ActComment  Action = "comment"  // read and write the application's message thread
+)
+
+type Resource string
+
+const (
+    ResProject     Resource = "project"
+    ResApplication Resource = "application"
+)
+
+// Relation is how a user stands to a resource. For an application, owner and
+// co_supervisor refer to the project it was submitted to.
+type Relation string
+
+const (
+    RelAny          Relation = "any"
+    RelOwner        Relation = "owner"
+    RelCoSupervisor Relation = "co_supervisor"
+    RelApplicant    Relation = "applicant"
+)
+
+var (
+    Roles     = []domain.Role{domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin}
+    Actions   = []Action{ActRead, ActCreate, ActUpdate, ActDelete, ActArchive, ActDecide, ActTrack, ActFeedback, ActWithdraw, ActComment}
+    Resources = []Resource{ResProject, ResApplication}
+    Relations = []Relation{RelAny, RelOwner, RelCoSupervisor, RelApplicant}
+)
+
+// Rule grants Action on Resource to Role when the user has Relation to it. Status, if
+// set, further requires the application to be in that status.
+type Rule struct {
+    Role     domain.Role `json:"role"`
+    Action   Action      `json:"action"`
+    Resource Resource    `json:"resource"`
+    Relation Relation    `json:"relation"`
+    Status   string      `json:"status,omitempty"`
+}
+
+// Default mirrors the checks the handlers used to hard-code.
+func Default() []Rule {
+    var rs []Rule
+    grant := func(role domain.Role, res Resource, rel Relation, acts ...Action) {
+        for _, a := range acts { rs = append(rs, Rule{Role: role, Action: a, Resource: res, Relation: rel}) }
+    }
+    for _, r := range Roles { grant(r, ResProject, RelAny, ActRead) }
+    grant(domain.RoleAdmin, ResProject, RelAny, ActCreate, ActUpdate, ActDelete, ActArchive)
+    grant(domain.RoleTeacher, ResProject, RelOwner, ActCreate, ActUpdate, ActDelete, ActArchive)
+    grant(domain.RoleAdmin, ResApplication, RelAny, ActRead, ActCreate, ActDecide, ActTrack, ActFeedback, ActWithdraw, ActComment)
+    grant(domain.RoleTeacher, ResApplication, RelAny, ActRead)
+    grant(domain.RoleTeacher, ResApplication, RelOwner, ActDecide, ActTrack, ActFeedback, ActComment)
+    // the message thread is between the applicants and the owner
+    grant(domain.RoleTeacher, ResApplication, RelCoSupervisor, ActDecide, ActTrack, ActFeedback)
+    grant(domain.RoleStudent, ResApplication, RelApplicant, ActRead, ActCreate, ActWithdraw, ActComment)
+    rs = append(rs, Rule{Role: domain.RoleStudent, Action: ActTrack, Resource: ResApplication, Relation: RelApplicant, Status: domain.StatusApproved})
+    return rs
+}
+
+type Policy struct {
+    rules []Rule
+}
+
+// New validates the rules; unknown names are almost always typos in the deployment config.
+// Roles are limited to the built-in ones on purpose: users can only hold those, so a
+// rule for any other role could never apply. New roles start in domain.Role and Roles.
+func New(rules []Rule) (*Policy, error) {
+    for _, r := range rules {
+        if !known(Roles, r.Role) || !known(Actions, r.Action) || !known(Resources, r.Resource) || !known(Relations, r.Relation) {
+            return nil, fmt.Errorf("invalid policy rule %+v", r)
+        }
+    }
+    return &Policy{rules: rules}, nil
+}
+
+func known[T comparable](set []T, v T) bool {
+    for _, s := range set { if s == v { return true } }
+    return false
+}
+
+func (p *Policy) Rules() []Rule { return p.rules }
+
+// Allowed reports whether any rule grants the action. rels are the relations the user
+// has to the resource; RelAny is implied.
+func (p *Policy) Allowed(role domain.Role, act Action, res Resource, rels []Relation, status string) bool {
+    for _, r := range p.rules {
+        if r.Role != role || r.Action != act || r.Resource != res { continue }
+        if r.Status != "" && r.Status != status { continue }
+        if r.Relation == RelAny || known(rels, r.Relation) { return true }
+    }
+    return false
+}
+
+// Entry is one cell of the permission matrix.
+type Entry struct {
+    Role     domain.Role `json:"role"`
+    Action   Action      `json:"action"`
+    Resource Resource    `json:"resource"`
+    Relation Relation    `json:"relation"`
+    Allowed  bool        `json:"allowed"`
+    Status   string      `json:"status,omitempty"` // only when the grant depends on it
+}
+
+// Matrix enumerates every role × action × resource × relation with the decision,
+// so a deployment's policy can be reviewed as a whole.
+func (p *Policy) Matrix() []Entry {
+    var out []Entry
+    for _, role := range Roles {
+        for _, res := range Resources {
+            for _, act := range Actions {
+                for _, rel := range Relations {
+                    e := Entry{Role: role, Action: act, Resource: res, Relation: rel}
+                    rels := []Relation{rel}
+                    e.Allowed = p.Allowed(role, act, res, rels, "")
+                    if !e.Allowed {
+                        for _, r := range p.rules {
+                            if r.Status != "" && p.Allowed(role, act, res, rels, r.Status) { e.Allowed, e.Status = true, r.Status; break }
+                        }
+                    }
+                    out = append(out, e)
+                }
+            }
+        }
+    }
+    return out
+}
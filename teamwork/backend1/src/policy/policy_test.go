@@ -0,0 +1,77 @@
+package policy
+
+import (
+    "sort"
+    "strings"
+    "testing"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+// defaultGrants lists, per role/resource/relation, the actions the default policy allows;
+// "track@approved" is allowed only for an approved application. Every cell of the matrix
+// that is not listed must be denied.
+var defaultGrants = map[string]string{
+    "student/project/any":           "read",
+    "student/project/owner":         "read",
+    "student/project/co_supervisor": "read",
+    "student/project/applicant":     "read",
+    "teacher/project/any":           "read",
+    "teacher/project/owner":         "archive,create,delete,read,update",
+    "teacher/project/co_supervisor": "read",
+    "teacher/project/applicant":     "read",
+    "admin/project/any":             "archive,create,delete,read,update",
+    "admin/project/owner":           "archive,create,delete,read,update",
+    "admin/project/co_supervisor":   "archive,create,delete,read,update",
+    "admin/project/applicant":       "archive,create,delete,read,update",
+
+    "student/application/applicant":     "comment,create,read,track@approved,withdraw",
+    "teacher/application/any":           "read",
+    "teacher/application/owner":         "comment,decide,feedback,read,track",
+    "teacher/application/co_supervisor": "decide,feedback,read,track",
+    "teacher/application/applicant":     "read",
+    "admin/application/any":             "comment,create,decide,feedback,read,track,withdraw",
+    "admin/application/owner":           "comment,create,decide,feedback,read,track,withdraw",
+    "admin/application/co_supervisor":   "comment,create,decide,feedback,read,track,withdraw",
+    "admin/application/applicant":       "comment,create,decide,feedback,read,track,withdraw",
+}
+
+func TestDefaultMatrix(t *testing.T) {
+    p, err := New(Default())
+    if err != nil { t.Fatal(err) }
+    got := map[string][]string{}
+    cells := 0
+    for _, e := range p.Matrix() {
+        cells++
+        key := string(e.Role) + "/" + string(e.Resource) + "/" + string(e.Relation)
+        if _, ok := got[key]; !ok { got[key] = nil }
+        if !e.Allowed { continue }
+        a := string(e.Action)
+        if e.Status != "" { a += "@" + e.Status }
+        got[key] = append(got[key], a)
+    }
+    if want := len(Roles) * len(Resources) * len(Actions) * len(Relations); cells != want { t.Fatalf("matrix has %d cells, want %d", cells, want) }
+    for key, acts := range got {
+        sort.Strings(acts)
+        if g, w := strings.Join(acts, ","), defaultGrants[key]; g != w { t.Errorf("%s: allowed %q, want %q", key, g, w) }
+    }
+}
+
+func TestAllowedRequiresStatus(t *testing.T) {
+    p, _ := New(Default())
+    rels := []Relation{RelApplicant}
+    if p.Allowed(domain.RoleStudent, ActTrack, ResApplication, rels, domain.StatusSubmitted) { t.Error("student tracks a pending application") }
+    if !p.Allowed(domain.RoleStudent, ActTrack, ResApplication, rels, domain.StatusApproved) { t.Error("student cannot track an approved application") }
+}
+
+func TestNewRejectsUnknownNames(t *testing.T) {
+    ok := Rule{Role: domain.RoleTeacher, Action: ActRead, Resource: ResProject, Relation: RelAny}
+    for _, r := range []Rule{
+        {Role: "reviewer", Action: ActRead, Resource: ResProject, Relation: RelAny},
+        {Role: domain.RoleTeacher, Action: "approve", Resource: ResProject, Relation: RelAny},
+        {Role: domain.RoleTeacher, Action: ActRead, Resource: "team", Relation: RelAny},
+        {Role: domain.RoleTeacher, Action: ActRead, Resource: ResProject, Relation: "member"},
+    } {
+        if _, err := New([]Rule{ok, r}); err == nil { t.Errorf("accepted %+v", r) }
+    }
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "gorm.io/gorm"
+)
+
+// AccountRepo stores mailed one-time tokens and the account fields they change.
+type AccountRepo interface {
+    AddUserToken(t *domain.UserToken) (*domain.UserToken, error)
+    GetUserTokenByHash(hash string) *domain.UserToken
+    // ConsumeUserToken marks the token used and reports whether this call did so.
+    ConsumeUserToken(id int64) (bool, error)
+    InvalidateUserTokens(userID int64, purpose string) error
+    SetEmailVerified(userID int64, verified bool) error
+    SetPasswordHash(userID int64, hash string) error
+}
+
+type gormAccountRepo struct { db *gorm.DB }
+
+func NewAccountRepo(db *gorm.DB) AccountRepo { return &gormAccountRepo{db: db} }
+
+func (r *gormAccountRepo) AddUserToken(t *domain.UserToken) (*domain.UserToken, error) {
+    if err := r.db.Create(t).Error; err != nil { return nil, err }
+    return t, nil
+}
+
+func (r *gormAccountRepo) GetUserTokenByHash(hash string) *domain.UserToken {
+    var t domain.UserToken
+    if err := r.db.Where("token_hash = ?", hash).First(&t).Error; err != nil { return nil }
+    return &t
+}
+
+func (r *gormAccountRepo) ConsumeUserToken(id int64) (bool, error) {
+    res := r.db.Model(&domain.UserToken{}).Where("id = ? AND used_at IS NULL", id).Update("used_at", time.Now())
+    if res.Error != nil { return false, res.Error }
+    return res.RowsAffected == 1, nil
+}
+
+func (r *gormAccountRepo) InvalidateUserTokens(userID int64, purpose string) error {
+    return r.db.Model(&domain.UserToken{}).Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).Update("used_at", time.Now()).Error
+}
+
+func (r *gormAccountRepo) SetEmailVerified(userID int64, verified bool) error {
+    return r.db.Model(&domain.User{}).Where("id = ?", userID).Update("email_verified", verified).Error
+}
+
+func (r *gormAccountRepo) SetPasswordHash(userID int64, hash string) error {
+    return r.db.Model(&domain.User{}).Where("id = ?", userID).Update("password_hash", hash).Error
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "gorm.io/gorm"
+)
+
+type AllocationRepo interface {
+    // SetStudentRanks replaces all of a student's ranks; applications not in ranks become unranked.
+    SetStudentRanks(studentID int64, ranks map[int64]int) error
+    // SetTeacherRanks replaces the ranks of a project's applicants.
+    SetTeacherRanks(projectID int64, ranks map[int64]int) error
+    AddRun(r *domain.AllocationRun) (*domain.AllocationRun, error)
+    GetRun(id int64) *domain.AllocationRun
+    ListRuns() []*domain.AllocationRun
+    UpdateRun(r *domain.AllocationRun) error
+    // SetProposals clears every pending proposal and writes those of the run, recording
+    // each application's current status in ProposalFrom.
+    SetProposals(runID int64, outcomes map[int64]string) error
+    ListProposals(runID int64) []*domain.Application
+    ClearProposals(runID int64) error
+}
+
+type gormAllocationRepo struct { db *gorm.DB }
+
+func NewAllocationRepo(db *gorm.DB) AllocationRepo { return &gormAllocationRepo{db: db} }
+
+func (r *gormAllocationRepo) SetStudentRanks(studentID int64, ranks map[int64]int) error {
+    return r.db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Model(&domain.Application{}).Where("student_id = ?", studentID).Update("student_rank", 0).Error; err != nil { return err }
+        for id, rank := range ranks {
+            if err := tx.Model(&domain.Application{}).Where("id = ? AND student_id = ?", id, studentID).Update("student_rank", rank).Error; err != nil { return err }
+        }
+        return nil
+    })
+}
+
+func (r *gormAllocationRepo) SetTeacherRanks(projectID int64, ranks map[int64]int) error {
+    return r.db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Model(&domain.Application{}).Where("project_id = ?", projectID).Update("teacher_rank", 0).Error; err != nil { return err }
+        for id, rank := range ranks {
+            if err := tx.Model(&domain.Application{}).Where("id = ? AND project_id = ?", id, projectID).Update("teacher_rank", rank).Error; err != nil { return err }
+        }
+        return nil
+    })
+}
+
+func (r *gormAllocationRepo) AddRun(run *domain.AllocationRun) (*domain.AllocationRun, error) {
+    if err := r.db.Create(run).Error; err != nil { return nil, err }
+    return run, nil
+}
+
+func (r *gormAllocationRepo) GetRun(id int64) *domain.AllocationRun {
+    var run domain.AllocationRun
+    if err := r.db.First(&run, id).Error; err != nil { return nil }
+    return &run
+}
+
+func (r *gormAllocationRepo) ListRuns() []*domain.AllocationRun {
+    var out []*domain.AllocationRun
+    r.db.Order("id desc").Find(&out)
+    return out
+}
+
+func (r *gormAllocationRepo) UpdateRun(run *domain.AllocationRun) error { return r.db.Save(run).Error }
+
+func (r *gormAllocationRepo) SetProposals(runID int64, outcomes map[int64]string) error {
+    return r.db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Model(&domain.Application{}).Where("proposal_run_id <> 0").Updates(map[string]any{"proposal": "", "proposal_from": "", "proposal_run_id": 0}).Error; err != nil { return err }
+        for id, outcome := range outcomes {
+            if err := tx.Model(&domain.Application{}).Where("id = ?", id).Updates(map[string]any{"proposal": outcome, "proposal_from": gorm.Expr("status"), "proposal_run_id": runID}).Error; err != nil { return err }
+        }
+        return nil
+    })
+}
+
+func (r *gormAllocationRepo) ListProposals(runID int64) []*domain.Application {
+    var out []*domain.Application
+    r.db.Where("proposal_run_id = ?", runID).Order("project_id, id").Find(&out)
+    return out
+}
+
+func (r *gormAllocationRepo) ClearProposals(runID int64) error {
+    return r.db.Model(&domain.Application{}).Where("proposal_run_id = ?", runID).Updates(map[string]any{"proposal": "", "proposal_from": "", "proposal_run_id": 0}).Error
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "gorm.io/gorm"
+)
+
+type AuditFilter struct {
+    ActorID   int64
+    SubjectID int64
+    TokenID   string
+    Limit     int
+}
+
+type AuditRepo interface {
+    AddAuditLog(l *domain.AuditLog) error
+    ListAuditLogs(f AuditFilter) []*domain.AuditLog
+}
+
+type gormAuditRepo struct { db *gorm.DB }
+
+func NewAuditRepo(db *gorm.DB) AuditRepo { return &gormAuditRepo{db: db} }
+
+func (r *gormAuditRepo) AddAuditLog(l *domain.AuditLog) error { return r.db.Create(l).Error }
+
+func (r *gormAuditRepo) ListAuditLogs(f AuditFilter) []*domain.AuditLog {
+    q := r.db.Order("id desc")
+    if f.ActorID != 0 { q = q.Where("actor_id = ?", f.ActorID) }
+    if f.SubjectID != 0 { q = q.Where("subject_id = ?", f.SubjectID) }
+    if f.TokenID != "" { q = q.Where("token_id = ?", f.TokenID) }
+    if f.Limit <= 0 || f.Limit > 500 { f.Limit = 100 }
+    var out []*domain.AuditLog
+    q.Limit(f.Limit).Find(&out)
+    return out
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+    "errors"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "gorm.io/gorm"
+)
+
+type CommentRepo interface {
+    AddComment(c *domain.ApplicationComment) (*domain.ApplicationComment, error)
+    GetComment(id int64) *domain.ApplicationComment
+    UpdateComment(c *domain.ApplicationComment) error
+    // ListComments pages through a thread oldest first and returns the total count.
+    ListComments(appID int64, offset, limit int) ([]*domain.ApplicationComment, int64)
+    LastCommentID(appID int64) int64
+    ListReads(appID int64) []*domain.CommentRead
+    // MarkRead moves the user's read marker forward to lastID; it never moves back.
+    MarkRead(appID, userID, lastID int64) error
+    // CountUnread counts, per application, live comments by others past the user's marker.
+    CountUnread(userID int64, appIDs []int64) map[int64]int
+}
+
+type gormCommentRepo struct { db *gorm.DB }
+
+func NewCommentRepo(db *gorm.DB) CommentRepo { return &gormCommentRepo{db: db} }
+
+func (r *gormCommentRepo) AddComment(c *domain.ApplicationComment) (*domain.ApplicationComment, error) {
+    if err := r.db.Create(c).Error; err != nil { return nil, err }
+    return c, nil
+}
+
+func (r *gormCommentRepo) GetComment(id int64) *domain.ApplicationComment {
+    var c domain.ApplicationComment
+    if err := r.db.First(&c, id).Error; err != nil { return nil }
+    return &c
+}
+
+func (r *gormCommentRepo) UpdateComment(c *domain.ApplicationComment) error { return r.db.Save(c).Error }
+
+func (r *gormCommentRepo) ListComments(appID int64, offset, limit int) ([]*domain.ApplicationComment, int64) {
+    var out []*domain.ApplicationComment
+    var total int64
+    q := r.db.Model(&domain.ApplicationComment{}).Where("application_id = ?", appID)
+    q.Count(&total)
+    q.Order("id").Offset(offset).Limit(limit).Find(&out)
+    return out, total
+}
+
+func (r *gormCommentRepo) LastCommentID(appID int64) int64 {
+    var id int64
+    r.db.Model(&domain.ApplicationComment{}).Select("COALESCE(MAX(id), 0)").Where("application_id = ?", appID).Scan(&id)
+    return id
+}
+
+func (r *gormCommentRepo) ListReads(appID int64) []*domain.CommentRead {
+    var out []*domain.CommentRead
+    r.db.Where("application_id = ?", appID).Find(&out)
+    return out
+}
+
+func (r *gormCommentRepo) MarkRead(appID, userID, lastID int64) error {
+    return r.db.Transaction(func(tx *gorm.DB) error {
+        var cur domain.CommentRead
+        err := tx.Where("application_id = ? AND user_id = ?", appID, userID).First(&cur).Error
+        if errors.Is(err, gorm.ErrRecordNotFound) { return tx.Create(&domain.CommentRead{ApplicationID: appID, UserID: userID, LastReadID: lastID, ReadAt: time.Now()}).Error }
+        if err != nil { return err }
+        if lastID <= cur.LastReadID { return nil }
+        return tx.Model(&cur).Updates(map[string]any{"last_read_id": lastID, "read_at": time.Now()}).Error
+    })
+}
+
+func (r *gormCommentRepo) CountUnread(userID int64, appIDs []int64) map[int64]int {
+    out := map[int64]int{}
+    if len(appIDs) == 0 { return out }
+    var rows []struct { ApplicationID int64; N int }
+    r.db.Table("application_comments c").Select("c.application_id, COUNT(*) AS n").
+        Joins("LEFT JOIN comment_reads r ON r.application_id = c.application_id AND r.user_id = ?", userID).
+        Where("c.application_id IN ? AND c.author_id <> ? AND c.deleted_at IS NULL AND c.id > COALESCE(r.last_read_id, 0)", appIDs, userID).
+        Group("c.application_id").Scan(&rows)
+    for _, row := range rows { out[row.ApplicationID] = row.N }
+    return out
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+    "errors"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+// ErrSlotTaken means another application still in interview holds the slot.
+var ErrSlotTaken = errors.New("该时段已被预约")
+
+type InterviewRepo interface {
+    AddSlots(slots []*domain.InterviewSlot) error
+    GetSlot(id int64) *domain.InterviewSlot
+    DeleteSlot(id int64) error
+    ListProjectSlots(projectIDs []int64) []*domain.InterviewSlot
+    ListApplicationSlots(appIDs []int64) []*domain.InterviewSlot
+    // BookSlot gives the slot to the application of studentID and frees any other slot it
+    // held. Under a lock on the student, it first looks for a slot of the student's other
+    // applications that overlaps and returns it without booking. ErrSlotTaken means the
+    // slot is held by another application still in interview.
+    BookSlot(slotID, appID, studentID int64, others []int64) (clash *domain.InterviewSlot, err error)
+}
+
+type gormInterviewRepo struct { db *gorm.DB }
+
+func NewInterviewRepo(db *gorm.DB) InterviewRepo { return &gormInterviewRepo{db: db} }
+
+func (r *gormInterviewRepo) AddSlots(slots []*domain.InterviewSlot) error { return r.db.Create(slots).Error }
+
+func (r *gormInterviewRepo) GetSlot(id int64) *domain.InterviewSlot {
+    var s domain.InterviewSlot
+    if err := r.db.First(&s, id).Error; err != nil { return nil }
+    return &s
+}
+
+func (r *gormInterviewRepo) DeleteSlot(id int64) error { return r.db.Delete(&domain.InterviewSlot{}, id).Error }
+
+func (r *gormInterviewRepo) ListProjectSlots(projectIDs []int64) []*domain.InterviewSlot {
+    var out []*domain.InterviewSlot
+    if len(projectIDs) == 0 { return out }
+    r.db.Where("project_id IN ?", projectIDs).Order("starts_at").Find(&out)
+    return out
+}
+
+func (r *gormInterviewRepo) ListApplicationSlots(appIDs []int64) []*domain.InterviewSlot {
+    var out []*domain.InterviewSlot
+    if len(appIDs) == 0 { return out }
+    r.db.Where("application_id IN ?", appIDs).Order("starts_at").Find(&out)
+    return out
+}
+
+func (r *gormInterviewRepo) BookSlot(slotID, appID, studentID int64, others []int64) (*domain.InterviewSlot, error) {
+    var clash *domain.InterviewSlot
+    err := r.db.Transaction(func(tx *gorm.DB) error {
+        // the student's row serializes their bookings, so two overlapping slots cannot both pass the check
+        if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&domain.User{}, studentID).Error; err != nil { return err }
+        var sl domain.InterviewSlot
+        if err := tx.First(&sl, slotID).Error; err != nil { return err }
+        if len(others) > 0 {
+            var found []*domain.InterviewSlot
+            if err := tx.Where("application_id IN ? AND starts_at < ? AND ends_at > ?", others, sl.EndsAt, sl.StartsAt).Order("starts_at").Limit(1).Find(&found).Error; err != nil { return err }
+            if len(found) > 0 { clash = found[0]; return nil }
+        }
+        active := tx.Model(&domain.Application{}).Select("id").Where("status = ?", domain.StatusInterview)
+        res := tx.Model(&domain.InterviewSlot{}).
+            Where("id = ? AND (application_id = 0 OR application_id = ? OR application_id NOT IN (?))", slotID, appID, active).
+            Update("application_id", appID)
+        if res.Error != nil { return res.Error }
+        if res.RowsAffected != 1 { return ErrSlotTaken }
+        return tx.Model(&domain.InterviewSlot{}).Where("application_id = ? AND id <> ?", appID, slotID).Update("application_id", 0).Error
+    })
+    return clash, err
+}
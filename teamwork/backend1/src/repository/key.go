@@ -0,0 +1,54 @@
+package repository
+
+import (
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+// KeyRepo persists JWT signing keys so every instance signs and verifies with the same set.
+type KeyRepo interface {
+    ListSigningKeys() []*domain.SigningKey
+    UpdateSigningKeyStatus(kid, status string, at time.Time) error
+    // RotateSigningKey adds k and demotes old in one transaction, provided old is still
+    // the active key (or, with old empty, there is no active key). It reports false and
+    // changes nothing when another instance rotated first.
+    RotateSigningKey(old string, k *domain.SigningKey, at time.Time) (bool, error)
+}
+
+type gormKeyRepo struct { db *gorm.DB }
+
+func NewKeyRepo(db *gorm.DB) KeyRepo { return &gormKeyRepo{db: db} }
+
+func (r *gormKeyRepo) ListSigningKeys() []*domain.SigningKey {
+    var ks []*domain.SigningKey
+    r.db.Order("created_at").Find(&ks)
+    return ks
+}
+
+func (r *gormKeyRepo) UpdateSigningKeyStatus(kid, status string, at time.Time) error {
+    upd := map[string]any{"status": status}
+    if status == domain.KeyStatusPrevious { upd["rotated_at"] = at }
+    return r.db.Model(&domain.SigningKey{}).Where("kid = ?", kid).Updates(upd).Error
+}
+
+func (r *gormKeyRepo) RotateSigningKey(old string, k *domain.SigningKey, at time.Time) (bool, error) {
+    ok := false
+    err := r.db.Transaction(func(tx *gorm.DB) error {
+        if old == "" {
+            var n int64
+            if err := tx.Model(&domain.SigningKey{}).Clauses(clause.Locking{Strength: "UPDATE"}).Where("status = ?", domain.KeyStatusActive).Count(&n).Error; err != nil { return err }
+            if n > 0 { return nil }
+        } else {
+            res := tx.Model(&domain.SigningKey{}).Where("kid = ? AND status = ?", old, domain.KeyStatusActive).Updates(map[string]any{"status": domain.KeyStatusPrevious, "rotated_at": at})
+            if res.Error != nil { return res.Error }
+            if res.RowsAffected != 1 { return nil }
+        }
+        if err := tx.Create(k).Error; err != nil { return err }
+        ok = true
+        return nil
+    })
+    return ok, err
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+    "errors"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+var (
+    // ErrCapacityFull is returned when approving would exceed the project's capacity.
+    ErrCapacityFull = errors.New("项目名额已满")
+    // ErrStaleStatus reports that a batch item no longer had its expected status.
+    ErrStaleStatus = errors.New("申请状态已被他人修改，请刷新后重试")
+    // ErrBatchAborted marks the items of an atomic batch rolled back because another failed.
+    ErrBatchAborted = errors.New("批量操作中有失败项，已全部回滚")
+    // ErrRoundLimit is returned when a student already has the most applications a round allows.
+    ErrRoundLimit = errors.New("已达到本轮申请数量上限")
+    // ErrDuplicateApplication is returned when the student already applied to the project;
+    // the unique index on (student_id, project_id) catches concurrent submissions.
+    ErrDuplicateApplication = errors.New("已提交过该项目申请")
+)
+
+// PlacedError refuses an approval that would put StudentID on two projects: a member
+// of a team application already holding another project, or an individual applicant
+// whose team already holds one.
+type PlacedError struct{ StudentID int64 }
+
+func (e *PlacedError) Error() string { return "申请人已通过其他申请被录取" }
+
+// RoundLimit caps the applications each of Students may have in a round, counting those
+// they submitted or are a team member of; withdrawn ones are not counted, nor Except,
+// the application being resubmitted.
+type RoundLimit struct {
+    RoundID  int64
+    Max      int
+    Students []int64
+    Except   int64
+}
+
+// StatusTransition is one item of a batch; Change.ApplicationID names the application.
+type StatusTransition struct {
+    From, To string
+    Change   *domain.ApplicationStatusChange
+}
+
+type LifecycleRepo interface {
+    // TransitionApplication moves the application from one status to another and
+    // records the change; it reports false if the status was no longer from. Approvals
+    // lock the project row and fail with ErrCapacityFull once it is full.
+    TransitionApplication(appID int64, from, to string, change *domain.ApplicationStatusChange) (bool, error)
+    // TransitionApplications applies a batch in one transaction and returns one result
+    // per item: nil, ErrStaleStatus or ErrCapacityFull. With atomic, a failed item rolls
+    // back the batch and the other items report ErrBatchAborted.
+    TransitionApplications(items []StatusTransition, atomic bool) ([]error, error)
+    // CountTaken counts the seats held by approved and completed applications of a project.
+    CountTaken(projectID int64) int
+    // NextWaitlisted returns the longest-waiting waitlisted application of a project.
+    NextWaitlisted(projectID int64) *domain.Application
+    // CreateApplication stores a new application and its first history entry in one
+    // transaction; change.ApplicationID is filled in. A limit is checked in the same
+    // transaction under a lock on the round, failing with ErrRoundLimit.
+    CreateApplication(a *domain.Application, change *domain.ApplicationStatusChange, limit *RoundLimit) (*domain.Application, error)
+    ListStatusChanges(appID int64) []*domain.ApplicationStatusChange
+    // ResubmitApplication moves app back from from to submitted in one transaction,
+    // writing its new cover letter, answers, team snapshot and round with the history
+    // entry. It reports false if the status was no longer from; a limit is checked as
+    // in CreateApplication.
+    ResubmitApplication(app *domain.Application, from string, change *domain.ApplicationStatusChange, limit *RoundLimit) (bool, error)
+}
+
+type gormLifecycleRepo struct { db *gorm.DB }
+
+func NewLifecycleRepo(db *gorm.DB) LifecycleRepo { return &gormLifecycleRepo{db: db} }
+
+func (r *gormLifecycleRepo) TransitionApplication(appID int64, from, to string, change *domain.ApplicationStatusChange) (bool, error) {
+    ok := false
+    err := r.db.Transaction(func(tx *gorm.DB) error {
+        var err error
+        ok, err = transition(tx, appID, from, to, change)
+        return err
+    })
+    return ok, err
+}
+
+var errRollback = errors.New("rollback")
+
+func (r *gormLifecycleRepo) TransitionApplications(items []StatusTransition, atomic bool) ([]error, error) {
+    results := make([]error, len(items))
+    failed := false
+    err := r.db.Transaction(func(tx *gorm.DB) error {
+        for i, it := range items {
+            ok, err := transition(tx, it.Change.ApplicationID, it.From, it.To, it.Change)
+            var placed *PlacedError
+            switch {
+            case errors.Is(err, ErrCapacityFull), errors.As(err, &placed):
+                results[i] = err
+            case err != nil:
+                return err
+            case !ok:
+                results[i] = ErrStaleStatus
+            }
+            if results[i] != nil { failed = true }
+        }
+        if atomic && failed { return errRollback }
+        return nil
+    })
+    if errors.Is(err, errRollback) {
+        for i := range results { if results[i] == nil { results[i] = ErrBatchAborted } }
+        return results, nil
+    }
+    if err != nil { return nil, err }
+    return results, nil
+}
+
+// transition moves one application inside tx; an approval checks capacity and the
+// students' other projects before anything is written.
+func transition(tx *gorm.DB, appID int64, from, to string, change *domain.ApplicationStatusChange) (bool, error) {
+    if to == domain.StatusApproved {
+        var app domain.Application
+        if err := tx.First(&app, appID).Error; err != nil { return false, err }
+        if err := checkCapacity(tx, &app); err != nil { return false, err }
+        if err := checkPlaced(tx, &app); err != nil { return false, err }
+    }
+    res := tx.Model(&domain.Application{}).Where("id = ? AND status = ?", appID, from).Update("status", to)
+    if res.Error != nil { return false, res.Error }
+    if res.RowsAffected != 1 { return false, nil }
+    return true, tx.Create(change).Error
+}
+
+func (r *gormLifecycleRepo) CreateApplication(a *domain.Application, change *domain.ApplicationStatusChange, limit *RoundLimit) (*domain.Application, error) {
+    err := r.db.Transaction(func(tx *gorm.DB) error {
+        if limit != nil && limit.Max > 0 {
+            if err := checkRoundLimit(tx, limit); err != nil { return err }
+        }
+        if err := tx.Create(a).Error; err != nil { return err }
+        change.ApplicationID = a.ID
+        return tx.Create(change).Error
+    })
+    if errors.Is(err, gorm.ErrDuplicatedKey) { return nil, ErrDuplicateApplication }
+    if err != nil { return nil, err }
+    return a, nil
+}
+
+func (r *gormLifecycleRepo) ListStatusChanges(appID int64) []*domain.ApplicationStatusChange {
+    var out []*domain.ApplicationStatusChange
+    r.db.Where("application_id = ?", appID).Order("id").Find(&out)
+    return out
+}
+
+// checkRoundLimit counts under a lock on the round row, so that concurrent submissions to
+// the round cannot all pass the count before any of them is inserted.
+func checkRoundLimit(tx *gorm.DB, limit *RoundLimit) error {
+    var rd domain.Round
+    if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&rd, limit.RoundID).Error; err != nil { return err }
+    var apps []*domain.Application
+    if err := tx.Where("round_id = ? AND status <> ?", limit.RoundID, domain.StatusWithdrawn).Find(&apps).Error; err != nil { return err }
+    for _, id := range limit.Students {
+        n := 0
+        for _, a := range apps { if a.ID != limit.Except && involves(a, id) { n++ } }
+        if n >= limit.Max { return ErrRoundLimit }
+    }
+    return nil
+}
+
+func checkCapacity(tx *gorm.DB, app *domain.Application) error {
+    var p domain.Project
+    // the row lock serializes concurrent approvals for the same project
+    if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&p, app.ProjectID).Error; err != nil { return err }
+    if p.Capacity <= 0 { return nil }
+    n, err := seatsTaken(tx, p.ID)
+    if err != nil { return err }
+    if n+seats(app) > p.Capacity { return ErrCapacityFull }
+    return nil
+}
+
+// checkPlaced returns a PlacedError when approving app would put one of its students on
+// a second project. The students' rows are locked so that concurrent approvals for the
+// same student cannot both pass.
+func checkPlaced(tx *gorm.DB, app *domain.Application) error {
+    ids := app.Members
+    if len(ids) == 0 { ids = []int64{app.StudentID} }
+    var users []domain.User
+    if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id IN ?", ids).Order("id").Find(&users).Error; err != nil { return err }
+    var held []*domain.Application
+    if err := tx.Where("id <> ? AND status IN ?", app.ID, takenStatuses).Find(&held).Error; err != nil { return err }
+    for _, id := range ids {
+        for _, o := range held {
+            if app.TeamID == 0 && o.TeamID == 0 { continue }
+            if involves(o, id) { return &PlacedError{StudentID: id} }
+        }
+    }
+    return nil
+}
+
+// involves reports whether the student submitted a or is on its team.
+func involves(a *domain.Application, studentID int64) bool {
+    if a.StudentID == studentID { return true }
+    for _, id := range a.Members { if id == studentID { return true } }
+    return false
+}
+
+var takenStatuses = []string{domain.StatusApproved, domain.StatusCompleted}
+
+// seats is the capacity an application takes; rows from before teams have 0.
+func seats(a *domain.Application) int {
+    if a.Seats > 0 { return a.Seats }
+    return 1
+}
+
+func seatsTaken(db *gorm.DB, projectID int64) (int, error) {
+    var n int64
+    err := db.Model(&domain.Application{}).Select("COALESCE(SUM(CASE WHEN seats > 0 THEN seats ELSE 1 END), 0)").
+        Where("project_id = ? AND status IN ?", projectID, takenStatuses).Scan(&n).Error
+    return int(n), err
+}
+
+func (r *gormLifecycleRepo) CountTaken(projectID int64) int {
+    n, _ := seatsTaken(r.db, projectID)
+    return n
+}
+
+func (r *gormLifecycleRepo) NextWaitlisted(projectID int64) *domain.Application {
+    // order by when the application entered the waitlist, not when it was first submitted
+    var a domain.Application
+    err := r.db.Table("applications").Select("applications.*").
+        Joins("LEFT JOIN application_status_changes h ON h.application_id = applications.id AND h.`to` = ?", domain.StatusWaitlisted).
+        Where("applications.project_id = ? AND applications.status = ?", projectID, domain.StatusWaitlisted).
+        Group("applications.id").Order("MAX(h.id), applications.id").Take(&a).Error
+    if err != nil { return nil }
+    return &a
+}
+
+func (r *gormLifecycleRepo) ResubmitApplication(app *domain.Application, from string, change *domain.ApplicationStatusChange, limit *RoundLimit) (bool, error) {
+    ok := false
+    err := r.db.Transaction(func(tx *gorm.DB) error {
+        if limit != nil && limit.Max > 0 {
+            if err := checkRoundLimit(tx, limit); err != nil { return err }
+        }
+        res := tx.Model(&domain.Application{ID: app.ID}).Where("status = ?", from).
+            Select("status", "cover_letter", "answers", "members", "seats", "round_id").
+            Updates(&domain.Application{Status: domain.StatusSubmitted, CoverLetter: app.CoverLetter, Answers: app.Answers, Members: app.Members, Seats: app.Seats, RoundID: app.RoundID})
+        if res.Error != nil { return res.Error }
+        if res.RowsAffected != 1 { return nil }
+        ok = true
+        return tx.Create(change).Error
+    })
+    return ok, err
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+    "errors"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+// LockoutRepo persists failed-login counters so lockouts survive restarts.
+type LockoutRepo interface {
+    GetLoginThrottle(key string) *domain.LoginThrottle
+    // RecordLoginFailure increments the counter of key, restarting from zero when the
+    // previous failure happened before resetBefore, and returns the updated row.
+    RecordLoginFailure(key string, now, resetBefore time.Time) (*domain.LoginThrottle, error)
+    SetLoginLockedUntil(key string, until time.Time) error
+    ListLockedThrottles(now time.Time) []*domain.LoginThrottle
+    DeleteLoginThrottle(key string) error
+}
+
+type gormLockoutRepo struct { db *gorm.DB }
+
+func NewLockoutRepo(db *gorm.DB) LockoutRepo { return &gormLockoutRepo{db: db} }
+
+func (r *gormLockoutRepo) GetLoginThrottle(key string) *domain.LoginThrottle {
+    var t domain.LoginThrottle
+    if err := r.db.First(&t, "`key` = ?", key).Error; err != nil { return nil }
+    return &t
+}
+
+func (r *gormLockoutRepo) RecordLoginFailure(key string, now, resetBefore time.Time) (*domain.LoginThrottle, error) {
+    var t domain.LoginThrottle
+    err := r.db.Transaction(func(tx *gorm.DB) error {
+        err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, "`key` = ?", key).Error
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            t = domain.LoginThrottle{Key: key}
+        } else if err != nil {
+            return err
+        }
+        if t.LastFailure.Before(resetBefore) { t.Failures = 0; t.LockedUntil = nil }
+        t.Failures++
+        t.LastFailure = now
+        return tx.Save(&t).Error
+    })
+    if err != nil { return nil, err }
+    return &t, nil
+}
+
+func (r *gormLockoutRepo) SetLoginLockedUntil(key string, until time.Time) error {
+    return r.db.Model(&domain.LoginThrottle{}).Where("`key` = ?", key).Update("locked_until", until).Error
+}
+
+func (r *gormLockoutRepo) ListLockedThrottles(now time.Time) []*domain.LoginThrottle {
+    var ts []*domain.LoginThrottle
+    r.db.Where("locked_until > ?", now).Order("locked_until desc").Find(&ts)
+    return ts
+}
+
+func (r *gormLockoutRepo) DeleteLoginThrottle(key string) error {
+    return r.db.Delete(&domain.LoginThrottle{}, "`key` = ?", key).Error
+}
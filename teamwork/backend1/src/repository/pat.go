@@ -0,0 +1,48 @@
+package repository
+
+import (
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "gorm.io/gorm"
+)
+
+type PATRepo interface {
+    AddPersonalToken(t *domain.PersonalToken) (*domain.PersonalToken, error)
+    GetPersonalTokenByHash(hash string) *domain.PersonalToken
+    ListPersonalTokens(userID int64) []*domain.PersonalToken
+    // RevokePersonalToken reports whether a live token of the user was revoked.
+    RevokePersonalToken(userID, id int64) (bool, error)
+    TouchPersonalToken(id int64, at time.Time) error
+}
+
+type gormPATRepo struct { db *gorm.DB }
+
+func NewPATRepo(db *gorm.DB) PATRepo { return &gormPATRepo{db: db} }
+
+func (r *gormPATRepo) AddPersonalToken(t *domain.PersonalToken) (*domain.PersonalToken, error) {
+    if err := r.db.Create(t).Error; err != nil { return nil, err }
+    return t, nil
+}
+
+func (r *gormPATRepo) GetPersonalTokenByHash(hash string) *domain.PersonalToken {
+    var t domain.PersonalToken
+    if err := r.db.Where("token_hash = ?", hash).First(&t).Error; err != nil { return nil }
+    return &t
+}
+
+func (r *gormPATRepo) ListPersonalTokens(userID int64) []*domain.PersonalToken {
+    var out []*domain.PersonalToken
+    r.db.Where("user_id = ?", userID).Order("id desc").Find(&out)
+    return out
+}
+
+func (r *gormPATRepo) RevokePersonalToken(userID, id int64) (bool, error) {
+    res := r.db.Model(&domain.PersonalToken{}).Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).Update("revoked_at", time.Now())
+    if res.Error != nil { return false, res.Error }
+    return res.RowsAffected == 1, nil
+}
+
+func (r *gormPATRepo) TouchPersonalToken(id int64, at time.Time) error {
+    return r.db.Model(&domain.PersonalToken{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "gorm.io/gorm"
+)
+
+type RoundRepo interface {
+    ListRounds() []*domain.Round
+    GetRound(id int64) *domain.Round
+    AddRound(r *domain.Round) (*domain.Round, error)
+    UpdateRound(r *domain.Round) error
+    // CountRoundApplications counts the applications in a round a student submitted or is
+    // a team member of, ignoring withdrawn ones.
+    CountRoundApplications(studentID, roundID int64) int
+    ListRoundApplications(roundID int64, statuses []string) []*domain.Application
+    // CloseRound marks the round closed and reports whether this call did it, so that only
+    // one of several instances running the close job finishes a round.
+    CloseRound(id int64) (bool, error)
+}
+
+type gormRoundRepo struct { db *gorm.DB }
+
+func NewRoundRepo(db *gorm.DB) RoundRepo { return &gormRoundRepo{db: db} }
+
+func (r *gormRoundRepo) ListRounds() []*domain.Round {
+    var out []*domain.Round
+    r.db.Order("opens_at").Find(&out)
+    return out
+}
+
+func (r *gormRoundRepo) GetRound(id int64) *domain.Round {
+    var rd domain.Round
+    if err := r.db.First(&rd, id).Error; err != nil { return nil }
+    return &rd
+}
+
+func (r *gormRoundRepo) AddRound(rd *domain.Round) (*domain.Round, error) {
+    if err := r.db.Create(rd).Error; err != nil { return nil, err }
+    return rd, nil
+}
+
+func (r *gormRoundRepo) UpdateRound(rd *domain.Round) error { return r.db.Save(rd).Error }
+
+func (r *gormRoundRepo) CountRoundApplications(studentID, roundID int64) int {
+    var apps []*domain.Application
+    r.db.Where("round_id = ? AND status <> ?", roundID, domain.StatusWithdrawn).Find(&apps)
+    n := 0
+    for _, a := range apps { if involves(a, studentID) { n++ } }
+    return n
+}
+
+func (r *gormRoundRepo) ListRoundApplications(roundID int64, statuses []string) []*domain.Application {
+    var out []*domain.Application
+    r.db.Where("round_id = ? AND status IN ?", roundID, statuses).Find(&out)
+    return out
+}
+
+func (r *gormRoundRepo) CloseRound(id int64) (bool, error) {
+    res := r.db.Model(&domain.Round{}).Where("id = ? AND closed = ?", id, false).Update("closed", true)
+    return res.RowsAffected == 1, res.Error
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+    "encoding/json"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "gorm.io/gorm"
+)
+
+type SkillRepo interface {
+    ListSkills() []*domain.Skill
+    GetSkill(id int64) *domain.Skill
+    AddSkill(s *domain.Skill) (*domain.Skill, error)
+    UpdateSkill(s *domain.Skill) error
+    // DeleteSkill removes the skill and moves its children up to its parent.
+    DeleteSkill(id int64) error
+    // RenameUserSkills and RenameProjectSkills rewrite the skill columns of a row while
+    // they still hold the old values, and report whether they did.
+    RenameUserSkills(userID int64, old, skills []string, oldProf, prof map[string]int) (bool, error)
+    RenameProjectSkills(projectID int64, old, reqs []string, oldDetails, details []domain.Requirement) (bool, error)
+}
+
+type gormSkillRepo struct { db *gorm.DB }
+
+func NewSkillRepo(db *gorm.DB) SkillRepo { return &gormSkillRepo{db: db} }
+
+func (r *gormSkillRepo) ListSkills() []*domain.Skill {
+    var out []*domain.Skill
+    r.db.Order("name").Find(&out)
+    return out
+}
+
+func (r *gormSkillRepo) GetSkill(id int64) *domain.Skill {
+    var s domain.Skill
+    if err := r.db.First(&s, id).Error; err != nil { return nil }
+    return &s
+}
+
+func (r *gormSkillRepo) AddSkill(s *domain.Skill) (*domain.Skill, error) {
+    if err := r.db.Create(s).Error; err != nil { return nil, err }
+    return s, nil
+}
+
+func (r *gormSkillRepo) UpdateSkill(s *domain.Skill) error { return r.db.Save(s).Error }
+
+func (r *gormSkillRepo) DeleteSkill(id int64) error {
+    return r.db.Transaction(func(tx *gorm.DB) error {
+        var s domain.Skill
+        if err := tx.First(&s, id).Error; err != nil { return err }
+        if err := tx.Model(&domain.Skill{}).Where("parent_id = ?", id).Update("parent_id", s.ParentID).Error; err != nil { return err }
+        return tx.Delete(&domain.Skill{}, id).Error
+    })
+}
+
+func (r *gormSkillRepo) RenameUserSkills(userID int64, old, skills []string, oldProf, prof map[string]int) (bool, error) {
+    q := r.db.Model(&domain.User{ID: userID})
+    q = whereJSON(whereJSON(q, "skills", old), "proficiency", oldProf)
+    res := q.Select("skills", "proficiency").Updates(&domain.User{Skills: skills, Proficiency: prof})
+    return res.RowsAffected == 1, res.Error
+}
+
+func (r *gormSkillRepo) RenameProjectSkills(projectID int64, old, reqs []string, oldDetails, details []domain.Requirement) (bool, error) {
+    q := r.db.Model(&domain.Project{ID: projectID})
+    q = whereJSON(whereJSON(q, "requirements", old), "requirement_details", oldDetails)
+    res := q.Select("requirements", "requirement_details").Updates(&domain.Project{Requirements: reqs, RequirementDetails: details})
+    return res.RowsAffected == 1, res.Error
+}
+
+// whereJSON matches a serializer:json column against v as gorm stores it: marshalled,
+// or NULL when v is nil.
+func whereJSON(q *gorm.DB, col string, v any) *gorm.DB {
+    b, err := json.Marshal(v)
+    if err != nil || string(b) == "null" { return q.Where(col + " IS NULL") }
+    return q.Where(col+" = ?", string(b))
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "gorm.io/gorm"
+)
+
+type SSORepo interface {
+    SaveLoginState(s *domain.SSOLoginState) error
+    // TakeLoginState returns and deletes the state so a callback can only be used once.
+    TakeLoginState(state string) *domain.SSOLoginState
+    GetExternalIdentity(provider, subject string) *domain.ExternalIdentity
+    AddExternalIdentity(e *domain.ExternalIdentity) error
+}
+
+type gormSSORepo struct { db *gorm.DB }
+
+func NewSSORepo(db *gorm.DB) SSORepo { return &gormSSORepo{db: db} }
+
+func (r *gormSSORepo) SaveLoginState(s *domain.SSOLoginState) error {
+    r.db.Where("expires_at < ?", time.Now()).Delete(&domain.SSOLoginState{})
+    return r.db.Create(s).Error
+}
+
+func (r *gormSSORepo) TakeLoginState(state string) *domain.SSOLoginState {
+    var s domain.SSOLoginState
+    if err := r.db.First(&s, "state = ?", state).Error; err != nil { return nil }
+    if res := r.db.Delete(&domain.SSOLoginState{}, "state = ?", state); res.Error != nil || res.RowsAffected == 0 { return nil }
+    return &s
+}
+
+func (r *gormSSORepo) GetExternalIdentity(provider, subject string) *domain.ExternalIdentity {
+    var e domain.ExternalIdentity
+    if err := r.db.First(&e, "provider = ? AND subject = ?", provider, subject).Error; err != nil { return nil }
+    return &e
+}
+
+func (r *gormSSORepo) AddExternalIdentity(e *domain.ExternalIdentity) error { return r.db.Create(e).Error }
@@ -0,0 +1,97 @@
+package repository
+
+import (
+    "strconv"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "gorm.io/gorm"
+)
+
+type TeamRepo interface {
+    // AddTeam creates the team with its leader as the first active member.
+    AddTeam(t *domain.Team) (*domain.Team, error)
+    GetTeam(id int64) *domain.Team
+    // DeleteTeam removes the team and its memberships.
+    DeleteTeam(id int64) error
+    ListMembers(teamID int64) []*domain.TeamMember
+    GetMember(teamID, userID int64) *domain.TeamMember
+    AddMember(m *domain.TeamMember) error
+    UpdateMember(m *domain.TeamMember) error
+    RemoveMember(teamID, userID int64) error
+    ListMemberships(userID int64) []*domain.TeamMember
+    ListTeamApplications(teamID int64) []*domain.Application
+    // ListMemberApplications lists team applications that name the user as a member
+    // but were submitted by someone else; an empty status matches all.
+    ListMemberApplications(userID int64, status string) []*domain.Application
+    SetLeader(teamID, userID int64) error
+}
+
+type gormTeamRepo struct { db *gorm.DB }
+
+func NewTeamRepo(db *gorm.DB) TeamRepo { return &gormTeamRepo{db: db} }
+
+func (r *gormTeamRepo) AddTeam(t *domain.Team) (*domain.Team, error) {
+    err := r.db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Create(t).Error; err != nil { return err }
+        return tx.Create(&domain.TeamMember{TeamID: t.ID, UserID: t.LeaderID, Status: domain.TeamMemberActive, InvitedBy: t.LeaderID}).Error
+    })
+    if err != nil { return nil, err }
+    return t, nil
+}
+
+func (r *gormTeamRepo) GetTeam(id int64) *domain.Team {
+    var t domain.Team
+    if err := r.db.First(&t, id).Error; err != nil { return nil }
+    return &t
+}
+
+func (r *gormTeamRepo) DeleteTeam(id int64) error {
+    return r.db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Where("team_id = ?", id).Delete(&domain.TeamMember{}).Error; err != nil { return err }
+        return tx.Delete(&domain.Team{}, id).Error
+    })
+}
+
+func (r *gormTeamRepo) ListMembers(teamID int64) []*domain.TeamMember {
+    var out []*domain.TeamMember
+    r.db.Where("team_id = ?", teamID).Order("id").Find(&out)
+    return out
+}
+
+func (r *gormTeamRepo) GetMember(teamID, userID int64) *domain.TeamMember {
+    var m domain.TeamMember
+    if err := r.db.Where("team_id = ? AND user_id = ?", teamID, userID).First(&m).Error; err != nil { return nil }
+    return &m
+}
+
+func (r *gormTeamRepo) AddMember(m *domain.TeamMember) error { return r.db.Create(m).Error }
+
+func (r *gormTeamRepo) UpdateMember(m *domain.TeamMember) error { return r.db.Save(m).Error }
+
+func (r *gormTeamRepo) RemoveMember(teamID, userID int64) error {
+    return r.db.Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&domain.TeamMember{}).Error
+}
+
+func (r *gormTeamRepo) ListMemberships(userID int64) []*domain.TeamMember {
+    var out []*domain.TeamMember
+    r.db.Where("user_id = ?", userID).Order("id").Find(&out)
+    return out
+}
+
+func (r *gormTeamRepo) ListTeamApplications(teamID int64) []*domain.Application {
+    var out []*domain.Application
+    r.db.Where("team_id = ?", teamID).Order("id").Find(&out)
+    return out
+}
+
+func (r *gormTeamRepo) ListMemberApplications(userID int64, status string) []*domain.Application {
+    var out []*domain.Application
+    q := r.db.Where("team_id <> 0 AND student_id <> ? AND JSON_CONTAINS(members, ?)", userID, strconv.FormatInt(userID, 10))
+    if status != "" { q = q.Where("status = ?", status) }
+    q.Order("id").Find(&out)
+    return out
+}
+
+func (r *gormTeamRepo) SetLeader(teamID, userID int64) error {
+    return r.db.Model(&domain.Team{}).Where("id = ?", teamID).Update("leader_id", userID).Error
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+    "errors"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+// TokenRepo persists refresh tokens and access-token revocations.
+type TokenRepo interface {
+    AddRefreshToken(t *domain.RefreshToken) (*domain.RefreshToken, error)
+    GetRefreshTokenByHash(hash string) *domain.RefreshToken
+    // RevokeRefreshToken reports whether this call revoked the token, so that two
+    // concurrent refreshes with the same token cannot both succeed.
+    RevokeRefreshToken(id int64) (bool, error)
+    RevokeRefreshFamily(familyID string) error
+    RevokeUserRefreshTokens(userID int64) error
+    RevokeAccessToken(jti string, expiresAt time.Time) error
+    IsAccessTokenRevoked(jti string) bool
+    SetUserTokenCutoff(userID int64, at time.Time) error
+    GetUserTokenCutoff(userID int64) time.Time
+    PurgeExpiredTokens(now time.Time) error
+}
+
+type gormTokenRepo struct { db *gorm.DB }
+
+func NewTokenRepo(db *gorm.DB) TokenRepo { return &gormTokenRepo{db: db} }
+
+func (r *gormTokenRepo) AddRefreshToken(t *domain.RefreshToken) (*domain.RefreshToken, error) {
+    if err := r.db.Create(t).Error; err != nil { return nil, err }
+    return t, nil
+}
+
+func (r *gormTokenRepo) GetRefreshTokenByHash(hash string) *domain.RefreshToken {
+    var t domain.RefreshToken
+    if err := r.db.Where("token_hash = ?", hash).First(&t).Error; err != nil { return nil }
+    return &t
+}
+
+func (r *gormTokenRepo) RevokeRefreshToken(id int64) (bool, error) {
+    res := r.db.Model(&domain.RefreshToken{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", time.Now())
+    if res.Error != nil { return false, res.Error }
+    return res.RowsAffected == 1, nil
+}
+
+func (r *gormTokenRepo) RevokeRefreshFamily(familyID string) error {
+    return r.db.Model(&domain.RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", familyID).Update("revoked_at", time.Now()).Error
+}
+
+func (r *gormTokenRepo) RevokeUserRefreshTokens(userID int64) error {
+    return r.db.Model(&domain.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", time.Now()).Error
+}
+
+func (r *gormTokenRepo) RevokeAccessToken(jti string, expiresAt time.Time) error {
+    return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&domain.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+func (r *gormTokenRepo) IsAccessTokenRevoked(jti string) bool {
+    var n int64
+    // fail closed: a lookup error is treated as revoked
+    if err := r.db.Model(&domain.RevokedToken{}).Where("jti = ?", jti).Count(&n).Error; err != nil { return true }
+    return n > 0
+}
+
+func (r *gormTokenRepo) SetUserTokenCutoff(userID int64, at time.Time) error {
+    return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&domain.UserTokenCutoff{UserID: userID, RevokedAt: at}).Error
+}
+
+func (r *gormTokenRepo) GetUserTokenCutoff(userID int64) time.Time {
+    var c domain.UserTokenCutoff
+    if err := r.db.First(&c, "user_id = ?", userID).Error; err != nil {
+        // fail closed, same as IsAccessTokenRevoked
+        if !errors.Is(err, gorm.ErrRecordNotFound) { return time.Now() }
+        return time.Time{}
+    }
+    return c.RevokedAt
+}
+
+func (r *gormTokenRepo) PurgeExpiredTokens(now time.Time) error {
+    if err := r.db.Where("expires_at < ?", now).Delete(&domain.RevokedToken{}).Error; err != nil { return err }
+    return r.db.Where("expires_at < ?", now).Delete(&domain.RefreshToken{}).Error
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+type TwoFactorRepo interface {
+    GetTwoFactor(userID int64) *domain.TwoFactor
+    SaveTwoFactor(t *domain.TwoFactor) error
+    // DeleteTwoFactor removes the enrollment together with its recovery codes.
+    DeleteTwoFactor(userID int64) error
+    // AdvanceTOTPStep records step as used; false means it (or a later one) was used already.
+    AdvanceTOTPStep(userID, step int64) (bool, error)
+    ReplaceRecoveryCodes(userID int64, hashes []string) error
+    ConsumeRecoveryCode(userID int64, hash string) (bool, error)
+    CountRecoveryCodes(userID int64) int
+    ListTwoFactorPolicies() []*domain.TwoFactorPolicy
+    SetTwoFactorPolicy(p *domain.TwoFactorPolicy) error
+}
+
+type gormTwoFactorRepo struct { db *gorm.DB }
+
+func NewTwoFactorRepo(db *gorm.DB) TwoFactorRepo { return &gormTwoFactorRepo{db: db} }
+
+func (r *gormTwoFactorRepo) GetTwoFactor(userID int64) *domain.TwoFactor {
+    var t domain.TwoFactor
+    if err := r.db.First(&t, "user_id = ?", userID).Error; err != nil { return nil }
+    return &t
+}
+
+func (r *gormTwoFactorRepo) SaveTwoFactor(t *domain.TwoFactor) error {
+    return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(t).Error
+}
+
+func (r *gormTwoFactorRepo) DeleteTwoFactor(userID int64) error {
+    return r.db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Delete(&domain.RecoveryCode{}, "user_id = ?", userID).Error; err != nil { return err }
+        return tx.Delete(&domain.TwoFactor{}, "user_id = ?", userID).Error
+    })
+}
+
+func (r *gormTwoFactorRepo) AdvanceTOTPStep(userID, step int64) (bool, error) {
+    res := r.db.Model(&domain.TwoFactor{}).Where("user_id = ? AND last_step < ?", userID, step).Update("last_step", step)
+    if res.Error != nil { return false, res.Error }
+    return res.RowsAffected == 1, nil
+}
+
+func (r *gormTwoFactorRepo) ReplaceRecoveryCodes(userID int64, hashes []string) error {
+    return r.db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Delete(&domain.RecoveryCode{}, "user_id = ?", userID).Error; err != nil { return err }
+        codes := make([]domain.RecoveryCode, 0, len(hashes))
+        for _, h := range hashes { codes = append(codes, domain.RecoveryCode{UserID: userID, CodeHash: h}) }
+        if len(codes) == 0 { return nil }
+        return tx.Create(&codes).Error
+    })
+}
+
+func (r *gormTwoFactorRepo) ConsumeRecoveryCode(userID int64, hash string) (bool, error) {
+    res := r.db.Model(&domain.RecoveryCode{}).Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).Update("used_at", time.Now())
+    if res.Error != nil { return false, res.Error }
+    return res.RowsAffected > 0, nil
+}
+
+func (r *gormTwoFactorRepo) CountRecoveryCodes(userID int64) int {
+    var n int64
+    r.db.Model(&domain.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&n)
+    return int(n)
+}
+
+func (r *gormTwoFactorRepo) ListTwoFactorPolicies() []*domain.TwoFactorPolicy {
+    var ps []*domain.TwoFactorPolicy
+    r.db.Find(&ps)
+    return ps
+}
+
+func (r *gormTwoFactorRepo) SetTwoFactorPolicy(p *domain.TwoFactorPolicy) error {
+    return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(p).Error
+}
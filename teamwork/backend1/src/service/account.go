@@ -0,0 +1,147 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/auth"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/mailer"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+    verifyEmailTTL   = 48 * time.Hour
+    resetPasswordTTL = time.Hour
+)
+
+// AccountService drives email verification and password reset through mailed one-time tokens.
+type AccountService struct {
+    svc      *Service
+    accounts repository.AccountRepo
+    tokens   *TokenService
+    mailer   mailer.Mailer
+    baseURL  string
+}
+
+func NewAccountService(s *Service, accounts repository.AccountRepo, ts *TokenService, m mailer.Mailer, baseURL string) *AccountService {
+    return &AccountService{svc: s, accounts: accounts, tokens: ts, mailer: m, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Register creates the account and mails a verification link. The account exists once
+// it is saved, so failing to prepare the mail is only logged; the user can ask for
+// another link.
+func (a *AccountService) Register(name, email, password string, role domain.Role, skills []string) (*domain.User, error) {
+    u, err := a.svc.Register(name, email, password, role, skills)
+    if err != nil { return nil, err }
+    if err := a.SendVerification(u); err != nil { log.Printf("verification mail for user %d: %v", u.ID, err) }
+    return u, nil
+}
+
+// CreateUser adds an account on an admin's behalf. It has no password yet, so the user
+// is mailed a link to set one, which also verifies the address.
+func (a *AccountService) CreateUser(u *domain.User) (*domain.User, error) {
+    u.PasswordHash = ""
+    created, err := a.svc.CreateUser(u)
+    if err != nil { return nil, err }
+    if err := a.SendInvitation(created); err != nil { log.Printf("invitation mail for user %d: %v", created.ID, err) }
+    return created, nil
+}
+
+// SendInvitation mails a set-password link that stays valid as long as a verification link.
+func (a *AccountService) SendInvitation(u *domain.User) error {
+    raw, err := a.issue(u.ID, domain.TokenPurposeResetPassword, verifyEmailTTL)
+    if err != nil { return err }
+    link := a.baseURL + "/reset-password?token=" + url.QueryEscape(raw)
+    a.send(u.Email, "您的账号已创建", fmt.Sprintf("%s，您好：\n\n管理员为您创建了账号。请在48小时内打开以下链接设置密码，完成后即可登录：\n%s\n", u.Name, link))
+    return nil
+}
+
+func (a *AccountService) SendVerification(u *domain.User) error {
+    if u.EmailVerified { return errors.New("邮箱已验证") }
+    raw, err := a.issue(u.ID, domain.TokenPurposeVerifyEmail, verifyEmailTTL)
+    if err != nil { return err }
+    link := a.baseURL + "/verify-email?token=" + url.QueryEscape(raw)
+    a.send(u.Email, "请验证您的邮箱", fmt.Sprintf("%s，您好：\n\n请在48小时内打开以下链接完成邮箱验证：\n%s\n", u.Name, link))
+    return nil
+}
+
+func (a *AccountService) VerifyEmail(raw string) error {
+    t, err := a.consume(raw, domain.TokenPurposeVerifyEmail)
+    if err != nil { return err }
+    return a.accounts.SetEmailVerified(t.UserID, true)
+}
+
+// RequestPasswordReset never reveals whether the email is registered.
+func (a *AccountService) RequestPasswordReset(email string) error {
+    if email == "" { return errors.New("缺少邮箱") }
+    u := a.svc.repo.GetUserByEmail(email)
+    if u == nil { return nil }
+    raw, err := a.issue(u.ID, domain.TokenPurposeResetPassword, resetPasswordTTL)
+    if err != nil { return err }
+    link := a.baseURL + "/reset-password?token=" + url.QueryEscape(raw)
+    a.send(u.Email, "重置密码", fmt.Sprintf("%s，您好：\n\n请在1小时内打开以下链接重置密码：\n%s\n\n如果这不是您本人的操作，请忽略此邮件。\n", u.Name, link))
+    return nil
+}
+
+// ResetPassword sets the new password and logs the user out everywhere, including the
+// calendar feed link. Receiving the mail also proves ownership of the address, so the
+// account counts as verified.
+func (a *AccountService) ResetPassword(raw, password string) error {
+    if password == "" { return errors.New("缺少新密码") }
+    t, err := a.consume(raw, domain.TokenPurposeResetPassword)
+    if err != nil { return err }
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil { return err }
+    if err := a.accounts.SetPasswordHash(t.UserID, string(hash)); err != nil { return err }
+    if err := a.accounts.SetEmailVerified(t.UserID, true); err != nil { return err }
+    if err := a.accounts.InvalidateUserTokens(t.UserID, domain.TokenPurposeCalendarFeed); err != nil { return err }
+    return a.tokens.RevokeUser(t.UserID)
+}
+
+// UpdateMe updates the profile; a changed email must be verified again.
+func (a *AccountService) UpdateMe(userID int64, name, email string, skills []string, bio *string, proficiency map[string]int) (*domain.User, error) {
+    cur := a.svc.repo.GetUser(userID)
+    if cur == nil { return nil, errors.New("用户不存在") }
+    changed := cur.Email != email
+    u, err := a.svc.UpdateMe(userID, name, email, skills, bio, proficiency)
+    if err != nil || !changed { return u, err }
+    if err := a.accounts.SetEmailVerified(u.ID, false); err != nil { return nil, err }
+    u.EmailVerified = false
+    return u, a.SendVerification(u)
+}
+
+func (a *AccountService) issue(userID int64, purpose string, ttl time.Duration) (string, error) {
+    if err := a.accounts.InvalidateUserTokens(userID, purpose); err != nil { return "", err }
+    raw := auth.NewTokenID() + auth.NewTokenID()
+    t := &domain.UserToken{UserID: userID, Purpose: purpose, TokenHash: hashToken(raw), ExpiresAt: time.Now().Add(ttl)}
+    if _, err := a.accounts.AddUserToken(t); err != nil { return "", err }
+    return raw, nil
+}
+
+func (a *AccountService) consume(raw, purpose string) (*domain.UserToken, error) {
+    if raw == "" { return nil, errors.New("缺少token") }
+    t := a.accounts.GetUserTokenByHash(hashToken(raw))
+    if t == nil || t.Purpose != purpose || t.UsedAt != nil || time.Now().After(t.ExpiresAt) { return nil, errors.New("链接无效或已过期") }
+    ok, err := a.accounts.ConsumeUserToken(t.ID)
+    if err != nil { return nil, err }
+    if !ok { return nil, errors.New("链接无效或已过期") }
+    return t, nil
+}
+
+// send delivers in the background so SMTP latency neither hits the request timeout
+// nor reveals whether an address exists.
+func (a *AccountService) send(to, subject, body string) { sendAsync(a.mailer, to, subject, body) }
+
+// sendAsync keeps slow mail servers out of the request path; failures are only logged.
+func sendAsync(m mailer.Mailer, to, subject, body string) {
+    if m == nil { return }
+    go func() {
+        if err := m.Send(to, subject, body); err != nil { log.Printf("mail to %s failed: %v", to, err) }
+    }()
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+    "errors"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+// memAccounts is an in-memory repository.AccountRepo over a memRepo's users.
+type memAccounts struct {
+    mu      sync.Mutex
+    users   *memRepo
+    tokens  []*domain.UserToken
+    failAdd bool
+}
+
+func (m *memAccounts) AddUserToken(t *domain.UserToken) (*domain.UserToken, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    if m.failAdd { return nil, errors.New("db down") }
+    t.ID = int64(len(m.tokens) + 1)
+    m.tokens = append(m.tokens, t)
+    return t, nil
+}
+
+func (m *memAccounts) GetUserTokenByHash(hash string) *domain.UserToken {
+    m.mu.Lock(); defer m.mu.Unlock()
+    for _, t := range m.tokens {
+        if t.TokenHash == hash { cp := *t; return &cp }
+    }
+    return nil
+}
+
+func (m *memAccounts) ConsumeUserToken(id int64) (bool, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    for _, t := range m.tokens {
+        if t.ID == id && t.UsedAt == nil { now := time.Now(); t.UsedAt = &now; return true, nil }
+    }
+    return false, nil
+}
+
+func (m *memAccounts) InvalidateUserTokens(userID int64, purpose string) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    for _, t := range m.tokens {
+        if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil { now := time.Now(); t.UsedAt = &now }
+    }
+    return nil
+}
+
+func (m *memAccounts) SetEmailVerified(userID int64, verified bool) error {
+    if u := m.users.GetUser(userID); u != nil { u.EmailVerified = verified }
+    return nil
+}
+
+func (m *memAccounts) SetPasswordHash(userID int64, hash string) error {
+    if u := m.users.GetUser(userID); u != nil { u.PasswordHash = hash }
+    return nil
+}
+
+type sentMail struct{ To, Subject, Body string }
+
+// chanMailer hands every mail to the test, which may read it from the channel.
+type chanMailer chan sentMail
+
+func (m chanMailer) Send(to, subject, body string) error { m <- sentMail{to, subject, body}; return nil }
+
+func (m chanMailer) next(t *testing.T) sentMail {
+    t.Helper()
+    select {
+    case s := <-m: return s
+    case <-time.After(2 * time.Second): t.Fatal("no mail sent"); return sentMail{}
+    }
+}
+
+// mailToken extracts the token from the link in a mail body.
+func mailToken(t *testing.T, body string) string {
+    t.Helper()
+    i := strings.Index(body, "token=")
+    if i < 0 { t.Fatalf("no link in mail: %q", body) }
+    raw := body[i+len("token="):]
+    if j := strings.IndexAny(raw, "\n "); j >= 0 { raw = raw[:j] }
+    return raw
+}
+
+func newAccountTest(t *testing.T) (*AccountService, *memRepo, *memAccounts, chanMailer) {
+    useTestKeys(t)
+    repo := newMemRepo()
+    accounts := &memAccounts{users: repo}
+    mails := make(chanMailer, 4)
+    svc := &Service{repo: repo}
+    return NewAccountService(svc, accounts, NewTokenService(svc, newMemTokens()), mails, "http://app"), repo, accounts, mails
+}
+
+func TestRegisterMailsVerificationLink(t *testing.T) {
+    acc, repo, _, mails := newAccountTest(t)
+    u, err := acc.Register("Ann", "ann@example.com", "secret", domain.RoleStudent, nil)
+    if err != nil { t.Fatal(err) }
+    m := mails.next(t)
+    if m.To != "ann@example.com" { t.Fatalf("mail went to %s", m.To) }
+    if err := acc.VerifyEmail(mailToken(t, m.Body)); err != nil { t.Fatal(err) }
+    if !repo.GetUser(u.ID).EmailVerified { t.Error("email not verified") }
+    if err := acc.VerifyEmail(mailToken(t, m.Body)); err == nil { t.Error("verification link worked twice") }
+}
+
+func TestRegisterSurvivesMailFailure(t *testing.T) {
+    acc, repo, accounts, _ := newAccountTest(t)
+    accounts.failAdd = true
+    u, err := acc.Register("Ann", "ann@example.com", "secret", domain.RoleStudent, nil)
+    if err != nil { t.Fatalf("registration failed after the user was saved: %v", err) }
+    if repo.GetUser(u.ID) == nil { t.Error("user not saved") }
+}
+
+func TestAdminCreatedUserSetsPasswordByMail(t *testing.T) {
+    acc, repo, _, mails := newAccountTest(t)
+    u, err := acc.CreateUser(&domain.User{Name: "Bob", Email: "bob@example.com", Role: domain.RoleTeacher})
+    if err != nil { t.Fatal(err) }
+    if u.EmailVerified { t.Fatal("admin-created user verified before using the link") }
+    m := mails.next(t)
+    if err := acc.ResetPassword(mailToken(t, m.Body), "new-secret"); err != nil { t.Fatal(err) }
+    got := repo.GetUser(u.ID)
+    if !got.EmailVerified { t.Error("setting the password did not verify the address") }
+    if _, err := acc.svc.Login("bob@example.com", "new-secret"); err != nil { t.Errorf("cannot log in with the new password: %v", err) }
+}
+
+func TestResetPasswordRevokesCalendarFeed(t *testing.T) {
+    acc, _, accounts, mails := newAccountTest(t)
+    u, err := acc.Register("Ann", "ann@example.com", "secret", domain.RoleStudent, nil)
+    if err != nil { t.Fatal(err) }
+    mails.next(t)
+    feeds := NewInterviewService(acc.svc, nil, nil, accounts, nil)
+    feed, err := feeds.NewFeedToken(u.ID)
+    if err != nil { t.Fatal(err) }
+    if err := acc.RequestPasswordReset("ann@example.com"); err != nil { t.Fatal(err) }
+    if err := acc.ResetPassword(mailToken(t, mails.next(t).Body), "new-secret"); err != nil { t.Fatal(err) }
+    if _, err := feeds.FeedUser(feed); err == nil { t.Error("calendar feed still works after a password reset") }
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+// StableMatch runs student-proposing deferred acceptance. prefs lists each student's
+// projects best first; priority ranks each project's students (lower is better);
+// capacity limits each project, a negative value meaning unlimited; seats[p][s] is the
+// capacity s takes at p, 1 when missing, so that a team counts as its size. It returns
+// the project each matched student ends up with. With one seat each the result is
+// stable and the best stable outcome for every student.
+func StableMatch(prefs map[int64][]int64, priority map[int64]map[int64]int, capacity map[int64]int, seats map[int64]map[int64]int) map[int64]int64 {
+    size := func(p, s int64) int {
+        if n := seats[p][s]; n > 0 { return n }
+        return 1
+    }
+    next := map[int64]int{}
+    held := map[int64][]int64{}
+    used := map[int64]int{}
+    var free []int64
+    for s := range prefs { free = append(free, s) }
+    sort.Slice(free, func(i, j int) bool { return free[i] < free[j] })
+    for len(free) > 0 {
+        s := free[0]
+        free = free[1:]
+        if next[s] >= len(prefs[s]) { continue }
+        p := prefs[s][next[s]]
+        next[s]++
+        if c := capacity[p]; c == 0 { free = append(free, s); continue }
+        held[p] = append(held[p], s)
+        used[p] += size(p, s)
+        if c := capacity[p]; c > 0 && used[p] > c {
+            sort.SliceStable(held[p], func(i, j int) bool { return priority[p][held[p][i]] < priority[p][held[p][j]] })
+            for used[p] > c {
+                worst := held[p][len(held[p])-1]
+                held[p] = held[p][:len(held[p])-1]
+                used[p] -= size(p, worst)
+                free = append(free, worst)
+            }
+        }
+    }
+    out := map[int64]int64{}
+    for p, ss := range held { for _, s := range ss { out[s] = p } }
+    return out
+}
+
+// AllocationService collects rankings and turns them into proposed outcomes that an
+// admin reviews and then publishes through the lifecycle.
+type AllocationService struct {
+    svc   *Service
+    alloc repository.AllocationRepo
+    life  *LifecycleService
+}
+
+func NewAllocationService(s *Service, alloc repository.AllocationRepo, life *LifecycleService) *AllocationService {
+    return &AllocationService{svc: s, alloc: alloc, life: life}
+}
+
+// open statuses take part in allocation.
+var allocatable = map[string]bool{domain.StatusSubmitted: true, domain.StatusInterview: true, domain.StatusWaitlisted: true}
+
+func rankMap(appIDs []int64) (map[int64]int, error) {
+    ranks := map[int64]int{}
+    for i, id := range appIDs {
+        if _, dup := ranks[id]; dup { return nil, errors.New("排序中存在重复申请") }
+        ranks[id] = i + 1
+    }
+    return ranks, nil
+}
+
+// RankProjects stores a student's preference order over their own open applications.
+func (a *AllocationService) RankProjects(studentID int64, appIDs []int64) error {
+    ranks, err := rankMap(appIDs)
+    if err != nil { return err }
+    for id := range ranks {
+        app := a.svc.repo.GetApplication(id)
+        if app == nil || app.StudentID != studentID { return fmt.Errorf("申请%d不存在", id) }
+        if !allocatable[app.Status] { return fmt.Errorf("申请%d已结束，不能参与排序", id) }
+    }
+    return a.alloc.SetStudentRanks(studentID, ranks)
+}
+
+// RankApplicants stores a project's order over its applicants. The caller checks that
+// the teacher may decide on the project's applications.
+func (a *AllocationService) RankApplicants(projectID int64, appIDs []int64) error {
+    ranks, err := rankMap(appIDs)
+    if err != nil { return err }
+    for id := range ranks {
+        app := a.svc.repo.GetApplication(id)
+        if app == nil || app.ProjectID != projectID { return fmt.Errorf("申请%d不属于该项目", id) }
+    }
+    return a.alloc.SetTeacherRanks(projectID, ranks)
+}
+
+// Run computes a draft allocation over the open applications of roundID (0 = all) and
+// writes the proposals. Projects without a teacher ranking order applicants by match
+// score under m (nil = the configured matcher).
+func (a *AllocationService) Run(admin *domain.User, roundID int64, m domain.Matcher) (*domain.AllocationRun, error) {
+    matcher := a.svc.matcherOr(m)
+    apps := map[int64]*domain.Application{}
+    byStudent := map[int64][]*domain.Application{}
+    byProject := map[int64][]*domain.Application{}
+    // every member of an approved team application has a project
+    placed := map[int64]bool{}
+    for _, app := range a.svc.repo.ListApplications() {
+        if app.Status == domain.StatusApproved || app.Status == domain.StatusCompleted {
+            for _, id := range roundStudents(app) { placed[id] = true }
+        }
+        if !allocatable[app.Status] || (roundID != 0 && app.RoundID != roundID) { continue }
+        apps[app.ID] = app
+    }
+    hasProject := func(app *domain.Application) bool {
+        for _, id := range roundStudents(app) { if placed[id] { return true } }
+        return false
+    }
+    seats := map[int64]map[int64]int{}
+    for _, app := range apps {
+        if hasProject(app) { continue }
+        byStudent[app.StudentID] = append(byStudent[app.StudentID], app)
+        byProject[app.ProjectID] = append(byProject[app.ProjectID], app)
+        if seats[app.ProjectID] == nil { seats[app.ProjectID] = map[int64]int{} }
+        seats[app.ProjectID][app.StudentID] = appSeats(app)
+    }
+    if len(byStudent) == 0 { return nil, errors.New("没有可分配的申请") }
+
+    prefs := map[int64][]int64{}
+    for s, list := range byStudent {
+        sort.Slice(list, func(i, j int) bool { return prefLess(list[i].StudentRank, list[j].StudentRank, list[i].ID, list[j].ID) })
+        for _, app := range list { prefs[s] = append(prefs[s], app.ProjectID) }
+    }
+    priority := map[int64]map[int64]int{}
+    capacity := map[int64]int{}
+    for pid, list := range byProject {
+        proj := a.svc.repo.GetProject(pid)
+        if proj == nil || proj.Archived { capacity[pid] = 0; continue }
+        capacity[pid] = -1
+        if proj.Capacity > 0 {
+            capacity[pid] = proj.Capacity - a.life.history.CountTaken(pid)
+            if capacity[pid] < 0 { capacity[pid] = 0 }
+        }
+        scores := map[int64]float64{}
+        for _, app := range list {
+            if app.TeacherRank > 0 { continue }
+            if stu := a.svc.repo.GetUser(app.StudentID); stu != nil {
+                scores[app.ID], _ = a.svc.score(matcher, app, stu, proj)
+            }
+        }
+        sort.Slice(list, func(i, j int) bool {
+            ri, rj := list[i].TeacherRank, list[j].TeacherRank
+            if (ri > 0) != (rj > 0) || ri != rj { return prefLess(ri, rj, list[i].ID, list[j].ID) }
+            if scores[list[i].ID] != scores[list[j].ID] { return scores[list[i].ID] > scores[list[j].ID] }
+            return list[i].ID < list[j].ID
+        })
+        priority[pid] = map[int64]int{}
+        for i, app := range list { priority[pid][app.StudentID] = i }
+    }
+
+    match := StableMatch(prefs, priority, capacity, seats)
+    outcomes := map[int64]string{}
+    run := &domain.AllocationRun{RoundID: roundID, Status: domain.AllocationDraft, CreatedBy: admin.ID}
+    for s, list := range byStudent {
+        if _, ok := match[s]; ok { run.Matched++ } else { run.Unmatched++ }
+        for _, app := range list {
+            switch {
+            case match[s] == app.ProjectID:
+                outcomes[app.ID] = domain.StatusApproved
+            case a.waitlists(app.ProjectID):
+                outcomes[app.ID] = domain.StatusWaitlisted
+            default:
+                outcomes[app.ID] = domain.StatusRejected
+            }
+        }
+    }
+    // a new draft replaces any earlier one
+    for _, old := range a.alloc.ListRuns() {
+        if old.Status == domain.AllocationDraft { old.Status = domain.AllocationDiscarded; _ = a.alloc.UpdateRun(old) }
+    }
+    run, err := a.alloc.AddRun(run)
+    if err != nil { return nil, err }
+    if err := a.alloc.SetProposals(run.ID, outcomes); err != nil { return nil, err }
+    return run, nil
+}
+
+// prefLess orders ranked entries (1, 2, ...) before unranked ones (0), then by id.
+func prefLess(ri, rj int, idi, idj int64) bool {
+    if (ri > 0) != (rj > 0) { return ri > 0 }
+    if ri != rj { return ri < rj }
+    return idi < idj
+}
+
+func (a *AllocationService) waitlists(projectID int64) bool {
+    p := a.svc.repo.GetProject(projectID)
+    return p != nil && p.AutoWaitlist
+}
+
+func (a *AllocationService) Runs() []*domain.AllocationRun { return a.alloc.ListRuns() }
+
+func (a *AllocationService) Proposals(runID int64) ([]domain.AllocationProposal, error) {
+    if a.alloc.GetRun(runID) == nil { return nil, errors.New("分配记录不存在") }
+    out := []domain.AllocationProposal{}
+    for _, app := range a.alloc.ListProposals(runID) {
+        out = append(out, domain.AllocationProposal{Application: app, StudentRank: app.StudentRank, TeacherRank: app.TeacherRank, Proposal: app.Proposal, ProposalFrom: app.ProposalFrom})
+    }
+    return out, nil
+}
+
+// StudentRanks returns the student's own preference order.
+func (a *AllocationService) StudentRanks(studentID int64) []domain.Rank {
+    return ranks(a.svc.repo.ListApplicationsByStudent(studentID, ""), func(app *domain.Application) int { return app.StudentRank })
+}
+
+// ApplicantRanks returns a project's order over its applicants. The caller checks that
+// the teacher may decide on the project's applications.
+func (a *AllocationService) ApplicantRanks(projectID int64) []domain.Rank {
+    var apps []*domain.Application
+    for _, app := range a.svc.repo.ListApplications() {
+        if app.ProjectID == projectID { apps = append(apps, app) }
+    }
+    return ranks(apps, func(app *domain.Application) int { return app.TeacherRank })
+}
+
+func ranks(apps []*domain.Application, rank func(*domain.Application) int) []domain.Rank {
+    out := []domain.Rank{}
+    for _, app := range apps {
+        if r := rank(app); r > 0 { out = append(out, domain.Rank{ApplicationID: app.ID, ProjectID: app.ProjectID, Rank: r}) }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Rank < out[j].Rank })
+    return out
+}
+
+// Publish applies a draft's proposals as status changes by admin. Applications whose
+// status changed since the run are skipped and reported.
+func (a *AllocationService) Publish(admin *domain.User, runID int64) ([]string, error) {
+    run := a.alloc.GetRun(runID)
+    if run == nil { return nil, errors.New("分配记录不存在") }
+    if run.Status != domain.AllocationDraft { return nil, errors.New("只能发布草稿状态的分配") }
+    var skipped []string
+    props := a.alloc.ListProposals(runID)
+    // approvals first so that later rejections cannot free seats mid-way
+    sort.SliceStable(props, func(i, j int) bool { return props[i].Proposal == domain.StatusApproved && props[j].Proposal != domain.StatusApproved })
+    for _, app := range props {
+        if app.Status != app.ProposalFrom {
+            skipped = append(skipped, fmt.Sprintf("申请%d: 分配后状态已变为%s", app.ID, app.Status))
+            continue
+        }
+        if _, err := a.life.Transition(admin, app.ID, app.Proposal, fmt.Sprintf("分配结果 #%d", runID)); err != nil {
+            skipped = append(skipped, fmt.Sprintf("申请%d: %v", app.ID, err))
+        }
+    }
+    if err := a.alloc.ClearProposals(runID); err != nil { return nil, err }
+    now := time.Now()
+    run.Status, run.PublishedAt = domain.AllocationPublished, &now
+    return skipped, a.alloc.UpdateRun(run)
+}
+
+func (a *AllocationService) Discard(runID int64) error {
+    run := a.alloc.GetRun(runID)
+    if run == nil { return errors.New("分配记录不存在") }
+    if run.Status != domain.AllocationDraft { return errors.New("只能丢弃草稿状态的分配") }
+    if err := a.alloc.ClearProposals(runID); err != nil { return err }
+    run.Status = domain.AllocationDiscarded
+    return a.alloc.UpdateRun(run)
+}
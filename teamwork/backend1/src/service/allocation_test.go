@@ -0,0 +1,138 @@
+package service
+
+import (
+    "encoding/json"
+    "sort"
+    "strings"
+    "testing"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+// memAlloc keeps ranks and proposals on the applications of repo, like the gorm
+// repository keeps them on the application rows.
+type memAlloc struct {
+    repository.AllocationRepo
+    repo *memRepo
+    runs []*domain.AllocationRun
+}
+
+func (m *memAlloc) SetStudentRanks(studentID int64, ranks map[int64]int) error {
+    for _, a := range m.repo.ListApplications() { if a.StudentID == studentID { a.StudentRank = ranks[a.ID] } }
+    return nil
+}
+
+func (m *memAlloc) SetTeacherRanks(projectID int64, ranks map[int64]int) error {
+    for _, a := range m.repo.ListApplications() { if a.ProjectID == projectID { a.TeacherRank = ranks[a.ID] } }
+    return nil
+}
+
+func (m *memAlloc) AddRun(r *domain.AllocationRun) (*domain.AllocationRun, error) {
+    r.ID = int64(len(m.runs) + 1)
+    m.runs = append(m.runs, r)
+    return r, nil
+}
+
+func (m *memAlloc) GetRun(id int64) *domain.AllocationRun {
+    for _, r := range m.runs { if r.ID == id { return r } }
+    return nil
+}
+
+func (m *memAlloc) ListRuns() []*domain.AllocationRun { return m.runs }
+
+func (m *memAlloc) UpdateRun(r *domain.AllocationRun) error { return nil }
+
+func (m *memAlloc) SetProposals(runID int64, outcomes map[int64]string) error {
+    for _, a := range m.repo.ListApplications() {
+        a.Proposal, a.ProposalFrom, a.ProposalRunID = "", "", 0
+        if o, ok := outcomes[a.ID]; ok { a.Proposal, a.ProposalFrom, a.ProposalRunID = o, a.Status, runID }
+    }
+    return nil
+}
+
+func (m *memAlloc) ListProposals(runID int64) []*domain.Application {
+    var out []*domain.Application
+    for _, a := range m.repo.ListApplications() { if a.ProposalRunID == runID { cp := *a; out = append(out, &cp) } }
+    sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+    return out
+}
+
+func (m *memAlloc) ClearProposals(runID int64) error {
+    for _, a := range m.repo.ListApplications() { if a.ProposalRunID == runID { a.Proposal, a.ProposalFrom, a.ProposalRunID = "", "", 0 } }
+    return nil
+}
+
+func newAllocationTest(t *testing.T) (*AllocationService, *memAlloc, *domain.User, *domain.Project) {
+    l, hist, stu, p := newLifecycleTest(t)
+    alloc := &memAlloc{repo: hist.repo}
+    return NewAllocationService(l.svc, alloc, l), alloc, stu, p
+}
+
+func TestApplicationDoesNotSerializeAllocationFields(t *testing.T) {
+    b, _ := json.Marshal(&domain.Application{ID: 1, StudentRank: 2, TeacherRank: 3, Proposal: domain.StatusApproved, ProposalFrom: domain.StatusSubmitted, ProposalRunID: 4})
+    for _, key := range []string{"student_rank", "teacher_rank", "proposal"} {
+        if strings.Contains(string(b), key) { t.Errorf("application JSON contains %s: %s", key, b) }
+    }
+}
+
+func TestRanksAreReadPerSide(t *testing.T) {
+    a, alloc, stu, p := newAllocationTest(t)
+    repo := alloc.repo
+    other, _ := repo.AddUser(&domain.User{Name: "o", Role: domain.RoleStudent})
+    repo.apps[10] = &domain.Application{ID: 10, StudentID: stu.ID, ProjectID: p.ID, Status: domain.StatusSubmitted}
+    repo.apps[11] = &domain.Application{ID: 11, StudentID: other.ID, ProjectID: p.ID, Status: domain.StatusSubmitted}
+    if err := a.RankProjects(stu.ID, []int64{10}); err != nil { t.Fatal(err) }
+    if err := a.RankApplicants(p.ID, []int64{11, 10}); err != nil { t.Fatal(err) }
+
+    mine := a.StudentRanks(stu.ID)
+    if len(mine) != 1 || mine[0].ApplicationID != 10 || mine[0].Rank != 1 { t.Errorf("student ranks = %+v", mine) }
+    proj := a.ApplicantRanks(p.ID)
+    if len(proj) != 2 || proj[0].ApplicationID != 11 || proj[1].ApplicationID != 10 { t.Errorf("project ranks = %+v", proj) }
+}
+
+func TestPublishSkipsApplicationsChangedSinceRun(t *testing.T) {
+    a, alloc, stu, p := newAllocationTest(t)
+    repo := alloc.repo
+    admin, _ := repo.AddUser(&domain.User{Name: "adm", Role: domain.RoleAdmin})
+    other, _ := repo.AddProject(&domain.Project{Title: "q", TeacherID: p.TeacherID})
+    repo.apps[10] = &domain.Application{ID: 10, StudentID: stu.ID, ProjectID: p.ID, Status: domain.StatusSubmitted}
+    repo.apps[11] = &domain.Application{ID: 11, StudentID: stu.ID, ProjectID: other.ID, Status: domain.StatusSubmitted}
+    run, _ := alloc.AddRun(&domain.AllocationRun{Status: domain.AllocationDraft})
+    _ = alloc.SetProposals(run.ID, map[int64]string{10: domain.StatusRejected, 11: domain.StatusApproved})
+
+    props, err := a.Proposals(run.ID)
+    if err != nil || len(props) != 2 || props[0].Proposal != domain.StatusRejected || props[0].ProposalFrom != domain.StatusSubmitted { t.Fatalf("proposals = %+v, %v", props, err) }
+
+    // the application moves on, and the move is still a legal step towards its proposal
+    repo.apps[10].Status = domain.StatusInterview
+    skipped, err := a.Publish(admin, run.ID)
+    if err != nil { t.Fatal(err) }
+    if len(skipped) != 1 || !strings.Contains(skipped[0], "申请10") { t.Errorf("skipped = %v", skipped) }
+    if got := repo.apps[10].Status; got != domain.StatusInterview { t.Errorf("changed application is %s, want interview", got) }
+    if got := repo.apps[11].Status; got != domain.StatusApproved { t.Errorf("unchanged application is %s, want approved", got) }
+}
+
+func TestStableMatchCountsSeats(t *testing.T) {
+    prefs := map[int64][]int64{1: {10}, 2: {10}}
+    priority := map[int64]map[int64]int{10: {1: 0, 2: 1}}
+    got := StableMatch(prefs, priority, map[int64]int{10: 2}, map[int64]map[int64]int{10: {1: 2}})
+    if got[1] != 10 { t.Errorf("team of two not matched: %v", got) }
+    if _, ok := got[2]; ok { t.Errorf("project over capacity: %v", got) }
+}
+
+func TestRunSkipsPlacedTeamMembers(t *testing.T) {
+    a, alloc, stu, p := newAllocationTest(t)
+    repo := alloc.repo
+    admin, _ := repo.AddUser(&domain.User{Name: "adm", Role: domain.RoleAdmin})
+    other, _ := repo.AddProject(&domain.Project{Title: "q", TeacherID: p.TeacherID})
+    free, _ := repo.AddUser(&domain.User{Name: "free", Role: domain.RoleStudent})
+    repo.apps[50] = &domain.Application{ID: 50, StudentID: 99, ProjectID: other.ID, TeamID: 7, Members: []int64{99, stu.ID}, Status: domain.StatusApproved}
+    repo.apps[51] = &domain.Application{ID: 51, StudentID: stu.ID, ProjectID: p.ID, Status: domain.StatusSubmitted, TeacherRank: 1}
+    repo.apps[52] = &domain.Application{ID: 52, StudentID: free.ID, ProjectID: p.ID, Status: domain.StatusSubmitted, TeacherRank: 2}
+    run, err := a.Run(admin, 0, nil)
+    if err != nil { t.Fatal(err) }
+    if run.Matched != 1 || run.Unmatched != 0 { t.Errorf("run = %+v", run) }
+    if repo.apps[51].ProposalRunID != 0 { t.Error("placed team member got a proposal") }
+    if repo.apps[52].Proposal != domain.StatusApproved { t.Errorf("free student proposal = %q", repo.apps[52].Proposal) }
+}
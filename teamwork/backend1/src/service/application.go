@@ -2,40 +2,36 @@ package service
 
 import (
 	"errors"
-	"os"
 	"strconv"
 
 	"github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
 )
 
-func (s *Service) Apply(a *domain.Application) (*domain.Application, error) {
-    if a.StudentID == 0 || a.ProjectID == 0 { return nil, errors.New("缺少必填字段") }
-    apps := s.repo.ListApplications()
-    for _, ex := range apps { if ex.StudentID == a.StudentID && ex.ProjectID == a.ProjectID { return nil, errors.New("已提交过该项目申请") } }
-    a.Status = "submitted"
-    return s.repo.AddApplication(a)
+// checkApply validates a new application and marks it submitted; storing it is left to
+// the caller, which writes it together with its first history entry. A second
+// application to the same project is refused by the repository.
+func (s *Service) checkApply(a *domain.Application) error {
+    if err := s.checkSubmission(a); err != nil { return err }
+    a.Status = domain.StatusSubmitted
+    return nil
 }
 
-func (s *Service) ListApplicationsWithScores(projectID string, status string) ([]domain.ApplicationView, error) {
-    var pid int64
-    if projectID != "" { pid, _ = strconv.ParseInt(projectID, 10, 64) }
-    apps := s.repo.ListApplications()
-    var views []domain.ApplicationView
-    for _, a := range apps {
-        if projectID != "" && a.ProjectID != pid { continue }
-        if status != "" && a.Status != status { continue }
-        stu := s.repo.GetUser(a.StudentID)
-        proj := s.repo.GetProject(a.ProjectID)
-        if stu == nil || proj == nil { continue }
-        score := 0.0
-        res := s.matcher.Match(stu, []*domain.Project{proj})
-        if len(res) > 0 { score = res[0].Score }
-        views = append(views, domain.ApplicationView{Application: a, Student: stu, Project: proj, Score: score})
-    }
-    return views, nil
+// checkSubmission holds for every submission, first or repeated: a verified student, a
+// project still taking applications and complete answers to its form.
+func (s *Service) checkSubmission(a *domain.Application) error {
+    if a.StudentID == 0 || a.ProjectID == 0 { return errors.New("缺少必填字段") }
+    stu := s.repo.GetUser(a.StudentID)
+    if stu == nil { return errors.New("学生不存在") }
+    if !stu.EmailVerified { return errors.New("请先验证邮箱后再提交申请") }
+    proj := s.repo.GetProject(a.ProjectID)
+    if proj == nil { return errors.New("项目不存在") }
+    if proj.Archived { return errors.New("项目已归档，不再接受申请") }
+    return checkAnswers(proj, a)
 }
 
-func (s *Service) ListApplicationsWithScoresOpt(projectID, status string, page, size int, useSimple bool) ([]domain.ApplicationView, error) {
+// ListApplicationsWithScoresOpt scores one page of applications with m; nil means the
+// configured matcher.
+func (s *Service) ListApplicationsWithScoresOpt(projectID, status string, page, size int, m domain.Matcher) ([]domain.ApplicationView, error) {
     if page <= 0 { page = 1 }
     if size <= 0 { size = 50 }
     var pid int64
@@ -43,10 +39,7 @@ func (s *Service) ListApplicationsWithScoresOpt(projectID, status string, page,
     apps := s.repo.ListApplications()
     start := (page-1)*size
     end := start + size
-    var matcher domain.Matcher = s.matcher
-    if useSimple || os.Getenv("SC_LLM_LIST_DISABLE") == "1" {
-        matcher = SimpleMatcher{}
-    }
+    matcher := s.matcherOr(m)
     var views []domain.ApplicationView
     idx := 0
     for _, a := range apps {
@@ -56,9 +49,7 @@ func (s *Service) ListApplicationsWithScoresOpt(projectID, status string, page,
             stu := s.repo.GetUser(a.StudentID)
             proj := s.repo.GetProject(a.ProjectID)
             if stu == nil || proj == nil { idx++; continue }
-            score := 0.0
-            res := matcher.Match(stu, []*domain.Project{proj})
-            if len(res) > 0 { score = res[0].Score }
+            score, _ := s.score(matcher, a, stu, proj)
             views = append(views, domain.ApplicationView{Application: a, Student: stu, Project: proj, Score: score})
         }
         idx++
@@ -68,41 +59,25 @@ func (s *Service) ListApplicationsWithScoresOpt(projectID, status string, page,
 }
 
 func (s *Service) ListStudentApplicationsWithScores(studentID int64, status string) ([]domain.ApplicationView, error) {
-    apps := s.repo.ListApplicationsByStudent(studentID, status)
-    var views []domain.ApplicationView
-    for _, a := range apps {
-        stu := s.repo.GetUser(a.StudentID)
-        proj := s.repo.GetProject(a.ProjectID)
-        if stu == nil || proj == nil { continue }
-        score := 0.0
-        res := s.matcher.Match(stu, []*domain.Project{proj})
-        if len(res) > 0 { score = res[0].Score }
-        views = append(views, domain.ApplicationView{Application: a, Student: stu, Project: proj, Score: score})
-    }
-    return views, nil
+    return s.ListStudentApplicationsWithScoresOpt(studentID, status, nil)
 }
 
-func (s *Service) ListStudentApplicationsWithScoresOpt(studentID int64, status string, useSimple bool) ([]domain.ApplicationView, error) {
-    var matcher domain.Matcher = s.matcher
-    if useSimple || os.Getenv("SC_LLM_LIST_DISABLE") == "1" {
-        matcher = SimpleMatcher{}
-    }
-    apps := s.repo.ListApplicationsByStudent(studentID, status)
+func (s *Service) ListStudentApplicationsWithScoresOpt(studentID int64, status string, m domain.Matcher) ([]domain.ApplicationView, error) {
+    matcher := s.matcherOr(m)
+    apps := s.studentApplications(studentID, status)
     var views []domain.ApplicationView
     for _, a := range apps {
         stu := s.repo.GetUser(a.StudentID)
         proj := s.repo.GetProject(a.ProjectID)
         if stu == nil || proj == nil { continue }
-        score := 0.0
-        res := matcher.Match(stu, []*domain.Project{proj})
-        if len(res) > 0 { score = res[0].Score }
+        score, _ := s.score(matcher, a, stu, proj)
         views = append(views, domain.ApplicationView{Application: a, Student: stu, Project: proj, Score: score})
     }
     return views, nil
 }
 
 func (s *Service) ListStudentApplicationsPlain(studentID int64, status string) ([]domain.ApplicationView, error) {
-    apps := s.repo.ListApplicationsByStudent(studentID, status)
+    apps := s.studentApplications(studentID, status)
     var views []domain.ApplicationView
     for _, a := range apps {
         stu := s.repo.GetUser(a.StudentID)
@@ -113,12 +88,11 @@ func (s *Service) ListStudentApplicationsPlain(studentID int64, status string) (
     return views, nil
 }
 
-func (s *Service) AnalyzeApplicationsForTeacher(teacherID int64, projectID string, useSimple bool) ([]domain.ApplicationAnalysis, error) {
+func (s *Service) AnalyzeApplicationsForTeacher(teacherID int64, projectID string, m domain.Matcher) ([]domain.ApplicationAnalysis, error) {
     var pid int64
     if projectID != "" { pid, _ = strconv.ParseInt(projectID, 10, 64) }
     apps := s.repo.ListApplications()
-    var matcher domain.Matcher = s.matcher
-    if useSimple || os.Getenv("SC_LLM_LIST_DISABLE") == "1" { matcher = SimpleMatcher{} }
+    matcher := s.matcherOr(m)
     var out []domain.ApplicationAnalysis
     for _, a := range apps {
         proj := s.repo.GetProject(a.ProjectID)
@@ -126,20 +100,8 @@ func (s *Service) AnalyzeApplicationsForTeacher(teacherID int64, projectID strin
         if projectID != "" && a.ProjectID != pid { continue }
         stu := s.repo.GetUser(a.StudentID)
         if stu == nil { continue }
-        res := matcher.Match(stu, []*domain.Project{proj})
-        score := 0.0
-        reason := ""
-        if len(res) > 0 { score = res[0].Score; reason = res[0].Reason }
-        out = append(out, domain.ApplicationAnalysis{Application: a, Student: stu, Project: proj, Score: score, Reason: reason})
+        r := s.match(matcher, a, stu, proj)
+        out = append(out, domain.ApplicationAnalysis{Application: a, Student: stu, Project: proj, Score: r.Score, Reason: r.Reason, Breakdown: r.Breakdown})
     }
     return out, nil
 }
-
-func (s *Service) UpdateApplicationStatus(appID int64, status string) error {
-    if status == "" { return errors.New("缺少状态") }
-    existing := s.repo.GetApplication(appID)
-    if existing == nil { return errors.New("申请不存在") }
-    if existing.Status == status { return nil }
-    if existing.Status == "approved" { return errors.New("已通过的申请不可再次修改") }
-    return s.repo.UpdateApplicationStatus(appID, status)
-}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/ldapdir"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+// Authenticator checks an email/password pair. Unknown users and wrong passwords both
+// yield ErrBadCredentials so a chain can move on to the next provider.
+type Authenticator interface {
+    Authenticate(email, password string) (*domain.User, error)
+}
+
+// PasswordAuthenticator is the local bcrypt login (Service.Login).
+type PasswordAuthenticator struct{ svc *Service }
+
+func NewPasswordAuthenticator(s *Service) PasswordAuthenticator { return PasswordAuthenticator{svc: s} }
+
+func (a PasswordAuthenticator) Authenticate(email, password string) (*domain.User, error) {
+    return a.svc.Login(email, password)
+}
+
+// AuthChain tries each authenticator in order and returns the first success.
+type AuthChain []Authenticator
+
+var ErrAuthUnavailable = errors.New("认证服务暂不可用，请稍后重试")
+
+func (c AuthChain) Authenticate(email, password string) (*domain.User, error) {
+    unavailable := false
+    var notLinked error
+    for _, a := range c {
+        u, err := a.Authenticate(email, password)
+        if err == nil { return u, nil }
+        if errors.Is(err, ErrAccountNotLinked) {
+            notLinked = err
+        } else if !errors.Is(err, ErrBadCredentials) {
+            log.Printf("authenticator %T: %v", a, err)
+            unavailable = true
+        }
+    }
+    if unavailable { return nil, ErrAuthUnavailable }
+    if notLinked != nil { return nil, notLinked }
+    return nil, ErrBadCredentials
+}
+
+type LDAPOptions struct {
+    // UserFilter finds the login entry; %s is replaced by the escaped email,
+    // e.g. "(&(objectClass=person)(mail=%s))".
+    UserFilter  string
+    NameAttr    string // default cn
+    EmailAttr   string // default mail
+    GroupAttr   string // default memberOf
+    RoleMapping map[string]domain.Role // group DN -> role
+    DefaultRole domain.Role
+}
+
+// LDAPAuthenticator binds as the user found by UserFilter and provisions a local user
+// from the entry's attributes on first login. A local account with the same email is
+// never taken over at login; its owner links it with Link.
+type LDAPAuthenticator struct {
+    dir   ldapdir.Directory
+    opts  LDAPOptions
+    users *externalUsers
+}
+
+func NewLDAPAuthenticator(s *Service, dir ldapdir.Directory, opts LDAPOptions, links repository.SSORepo, accounts repository.AccountRepo, ts *TokenService) *LDAPAuthenticator {
+    if opts.UserFilter == "" { opts.UserFilter = "(mail=%s)" }
+    if opts.NameAttr == "" { opts.NameAttr = "cn" }
+    if opts.EmailAttr == "" { opts.EmailAttr = "mail" }
+    if opts.GroupAttr == "" { opts.GroupAttr = "memberOf" }
+    if opts.DefaultRole == "" { opts.DefaultRole = domain.RoleStudent }
+    return &LDAPAuthenticator{dir: dir, opts: opts, users: &externalUsers{svc: s, links: links, accounts: accounts, tokens: ts}}
+}
+
+func (a *LDAPAuthenticator) Authenticate(email, password string) (*domain.User, error) {
+    id, err := a.bind(email, password)
+    if err != nil { return nil, err }
+    return a.users.resolve(id)
+}
+
+// Link attaches the directory entry for u's email to u, after checking the directory
+// password. It is how an existing local account starts logging in through LDAP.
+func (a *LDAPAuthenticator) Link(u *domain.User, password string) error {
+    id, err := a.bind(u.Email, password)
+    if err != nil { return err }
+    if !strings.EqualFold(id.Email, u.Email) { return errors.New("目录账号的邮箱与当前账号不一致") }
+    return a.users.link(u, id)
+}
+
+// bind finds the entry for email and checks password against it.
+func (a *LDAPAuthenticator) bind(email, password string) (ExternalIdentity, error) {
+    email = strings.TrimSpace(email)
+    if email == "" || password == "" { return ExternalIdentity{}, ErrBadCredentials }
+    filter := strings.ReplaceAll(a.opts.UserFilter, "%s", ldapdir.EscapeFilter(email))
+    e, err := a.dir.Find(filter, []string{a.opts.NameAttr, a.opts.EmailAttr, a.opts.GroupAttr})
+    if errors.Is(err, ldapdir.ErrNotFound) { return ExternalIdentity{}, ErrBadCredentials }
+    if err != nil { return ExternalIdentity{}, fmt.Errorf("ldap search: %w", err) }
+    if err := a.dir.Bind(e.DN, password); err != nil {
+        if errors.Is(err, ldapdir.ErrInvalidCredentials) { return ExternalIdentity{}, ErrBadCredentials }
+        return ExternalIdentity{}, fmt.Errorf("ldap bind: %w", err)
+    }
+    id := ExternalIdentity{Provider: "ldap", Subject: strings.ToLower(e.DN), Email: e.First(a.opts.EmailAttr), Name: e.First(a.opts.NameAttr), EmailVerified: true}
+    if id.Email == "" { id.Email = email }
+    id.Role, id.RoleMapped = mapGroups(e.Values(a.opts.GroupAttr), a.opts.RoleMapping, a.opts.DefaultRole)
+    return id, nil
+}
+
+// NewAuthChain builds the login chain from provider names ("local", "ldap");
+// an empty list means local only.
+func NewAuthChain(s *Service, providers []string, ldap *LDAPAuthenticator) (AuthChain, error) {
+    if len(providers) == 0 { providers = []string{"local"} }
+    var c AuthChain
+    for _, p := range providers {
+        switch p {
+        case "local":
+            c = append(c, NewPasswordAuthenticator(s))
+        case "ldap":
+            if ldap == nil { return nil, errors.New("ldap provider selected but not configured") }
+            c = append(c, ldap)
+        default:
+            return nil, fmt.Errorf("unknown auth provider %q", p)
+        }
+    }
+    return c, nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+    "errors"
+    "testing"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/ldapdir"
+)
+
+func newLDAPTest(t *testing.T) (*LDAPAuthenticator, *memRepo) {
+    dir := ldapdir.NewMemory()
+    dir.Add(&ldapdir.Entry{DN: "uid=ann,dc=example", Attrs: map[string][]string{"mail": {"ann@example.com"}, "cn": {"Ann"}}}, "dir-pw")
+    repo := newMemRepo()
+    svc := &Service{repo: repo}
+    return NewLDAPAuthenticator(svc, dir, LDAPOptions{}, newMemSSO(), &memAccounts{users: repo}, NewTokenService(svc, newMemTokens())), repo
+}
+
+func TestLDAPLoginDoesNotTakeOverLocalAccount(t *testing.T) {
+    a, repo := newLDAPTest(t)
+    local, _ := repo.AddUser(&domain.User{Name: "Ann", Email: "ann@example.com", Role: domain.RoleTeacher})
+    if _, err := a.Authenticate("ann@example.com", "dir-pw"); !errors.Is(err, ErrAccountNotLinked) { t.Fatalf("got %v, want ErrAccountNotLinked", err) }
+    // the owner links it explicitly, after which the directory login reaches the same account
+    if err := a.Link(local, "wrong"); !errors.Is(err, ErrBadCredentials) { t.Fatalf("link with a wrong password: %v", err) }
+    if err := a.Link(local, "dir-pw"); err != nil { t.Fatal(err) }
+    u, err := a.Authenticate("ann@example.com", "dir-pw")
+    if err != nil { t.Fatal(err) }
+    if u.ID != local.ID { t.Errorf("logged in as user %d, want %d", u.ID, local.ID) }
+}
+
+func TestLDAPLinkNeedsMatchingEmail(t *testing.T) {
+    a, repo := newLDAPTest(t)
+    other, _ := repo.AddUser(&domain.User{Name: "Bob", Email: "bob@example.com", Role: domain.RoleStudent})
+    if err := a.Link(other, "dir-pw"); err == nil { t.Fatal("linked a directory entry of another email") }
+}
+
+func TestLDAPProvisionsNewUser(t *testing.T) {
+    a, _ := newLDAPTest(t)
+    u, err := a.Authenticate("ann@example.com", "dir-pw")
+    if err != nil { t.Fatal(err) }
+    if u.Email != "ann@example.com" || u.Role != domain.RoleStudent { t.Errorf("unexpected user %+v", u) }
+}
+
+func TestAuthChainReportsUnlinkedAccount(t *testing.T) {
+    bad := authFunc(func(string, string) (*domain.User, error) { return nil, ErrBadCredentials })
+    unlinked := authFunc(func(string, string) (*domain.User, error) { return nil, ErrAccountNotLinked })
+    if _, err := (AuthChain{bad, unlinked}).Authenticate("a", "b"); !errors.Is(err, ErrAccountNotLinked) { t.Errorf("got %v", err) }
+    locks := newMemLockouts()
+    ls := NewLockoutService(AuthChain{bad, unlinked}, locks)
+    for i := 0; i < 2*accountPolicy.threshold; i++ { _, _ = ls.Login("ann@example.com", "dir-pw", "10.0.0.1") }
+    if len(locks.rows) != 0 { t.Errorf("a right password for an unlinked account counted as failure: %v", locks.rows) }
+}
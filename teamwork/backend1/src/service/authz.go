@@ -0,0 +1,75 @@
+package service
+
+import (
+    "errors"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/policy"
+)
+
+var (
+    ErrForbidden = errors.New("无权执行该操作")
+    ErrNotFound  = errors.New("资源不存在")
+)
+
+// Authorizer resolves how a user relates to a project or application and asks the
+// policy whether the action is allowed.
+type Authorizer struct {
+    svc *Service
+    pol *policy.Policy
+}
+
+// NewAuthorizer uses policy.Default when pol is nil.
+func NewAuthorizer(s *Service, pol *policy.Policy) *Authorizer {
+    if pol == nil { pol, _ = policy.New(policy.Default()) }
+    return &Authorizer{svc: s, pol: pol}
+}
+
+func (a *Authorizer) Policy() *policy.Policy { return a.pol }
+
+func (a *Authorizer) Project(u *domain.User, act policy.Action, p *domain.Project) error {
+    if u == nil { return ErrForbidden }
+    if p == nil { return ErrNotFound }
+    if !a.pol.Allowed(u.Role, act, policy.ResProject, projectRelations(u, p), "") { return ErrForbidden }
+    return nil
+}
+
+// ProjectByID loads the project and checks act on it.
+func (a *Authorizer) ProjectByID(u *domain.User, act policy.Action, id int64) (*domain.Project, error) {
+    p := a.svc.repo.GetProject(id)
+    if p == nil { return nil, ErrNotFound }
+    return p, a.Project(u, act, p)
+}
+
+func (a *Authorizer) Application(u *domain.User, act policy.Action, app *domain.Application) error {
+    if u == nil { return ErrForbidden }
+    if app == nil { return ErrNotFound }
+    var rels []policy.Relation
+    if app.StudentID == u.ID || containsID(app.Members, u.ID) { rels = append(rels, policy.RelApplicant) }
+    if p := a.svc.repo.GetProject(app.ProjectID); p != nil { rels = append(rels, projectRelations(u, p)...) }
+    if !a.pol.Allowed(u.Role, act, policy.ResApplication, rels, app.Status) { return ErrForbidden }
+    return nil
+}
+
+func (a *Authorizer) ApplicationByID(u *domain.User, act policy.Action, id int64) (*domain.Application, error) {
+    app := a.svc.repo.GetApplication(id)
+    if app == nil { return nil, ErrNotFound }
+    return app, a.Application(u, act, app)
+}
+
+// SeesSubmission reports whether u may read the cover letter and form answers of app.
+// They are written for the project's supervisors, so teachers who may list every
+// application do not see those of other projects.
+func (a *Authorizer) SeesSubmission(u *domain.User, app *domain.Application) bool {
+    if u == nil || app == nil { return false }
+    if u.Role == domain.RoleAdmin || app.StudentID == u.ID || containsID(app.Members, u.ID) { return true }
+    p := a.svc.repo.GetProject(app.ProjectID)
+    return p != nil && len(projectRelations(u, p)) > 0
+}
+
+func projectRelations(u *domain.User, p *domain.Project) []policy.Relation {
+    var rels []policy.Relation
+    if p.TeacherID == u.ID { rels = append(rels, policy.RelOwner) }
+    for _, id := range p.CoSupervisors { if id == u.ID { rels = append(rels, policy.RelCoSupervisor); break } }
+    return rels
+}
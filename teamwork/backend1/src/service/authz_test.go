@@ -0,0 +1,28 @@
+package service
+
+import (
+    "testing"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+func TestSubmissionOnlyForSupervisorsApplicantsAndAdmins(t *testing.T) {
+    repo := newMemRepo()
+    owner, _ := repo.AddUser(&domain.User{Role: domain.RoleTeacher})
+    co, _ := repo.AddUser(&domain.User{Role: domain.RoleTeacher})
+    other, _ := repo.AddUser(&domain.User{Role: domain.RoleTeacher})
+    admin, _ := repo.AddUser(&domain.User{Role: domain.RoleAdmin})
+    stu, _ := repo.AddUser(&domain.User{Role: domain.RoleStudent})
+    member, _ := repo.AddUser(&domain.User{Role: domain.RoleStudent})
+    p, _ := repo.AddProject(&domain.Project{TeacherID: owner.ID, CoSupervisors: []int64{co.ID}})
+    app := &domain.Application{StudentID: stu.ID, ProjectID: p.ID, Members: []int64{stu.ID, member.ID}, CoverLetter: "信"}
+    authz := NewAuthorizer(&Service{repo: repo}, nil)
+
+    for _, c := range []struct {
+        who  string
+        u    *domain.User
+        sees bool
+    }{{"owner", owner, true}, {"co-supervisor", co, true}, {"admin", admin, true}, {"applicant", stu, true}, {"member", member, true}, {"other teacher", other, false}, {"nobody", nil, false}} {
+        if got := authz.SeesSubmission(c.u, app); got != c.sees { t.Errorf("%s sees the submission: %v, want %v", c.who, got, c.sees) }
+    }
+}
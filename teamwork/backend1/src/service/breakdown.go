@@ -0,0 +1,84 @@
+package service
+
+import (
+    "math"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+// Versions reported in the breakdowns of the built-in matchers; bump them when the
+// scoring changes so that stored or audited scores can be told apart.
+const (
+    skillMatcherVersion = "1"
+    textMatcherVersion  = "bm25-1"
+    defaultVersion      = "1" // matchers that do not explain themselves
+)
+
+func round2(f float64) float64 { return math.Round(f*100) / 100 }
+
+// requirementMatches turns an evaluation into breakdown rows.
+func requirementMatches(ev reqEval) []domain.RequirementMatch {
+    out := []domain.RequirementMatch{}
+    for _, c := range ev.Checks {
+        st := domain.RequirementMissing
+        if c.Credit >= 1 { st = domain.RequirementMatched } else if c.Credit > 0 { st = domain.RequirementPartial }
+        out = append(out, domain.RequirementMatch{Skill: c.Skill, Mandatory: c.Mandatory, Weight: weightOf(c.Requirement), MinLevel: c.MinLevel, Status: st, Credit: round2(c.Credit), By: c.By, Level: c.Level, Unrated: c.Unrated})
+    }
+    return out
+}
+
+func newBreakdown(version string, ev reqEval, components ...domain.ScoreComponent) *domain.MatchBreakdown {
+    return &domain.MatchBreakdown{Version: version, Requirements: requirementMatches(ev), Components: components}
+}
+
+// component is a part of the score whose contribution is weight times score.
+func component(name string, weight, score float64) domain.ScoreComponent {
+    return domain.ScoreComponent{Name: name, Weight: weight, Score: round2(score), Contribution: round2(weight * score)}
+}
+
+// explainedMatcher makes sure every result carries a breakdown that names the
+// registered matcher. Results without one get the student's requirement rows and the
+// matcher's score as the only component.
+type explainedMatcher struct {
+    name  string
+    inner domain.Matcher
+    tax   *Taxonomy
+}
+
+func explain(name string, tax *Taxonomy, m domain.Matcher) domain.Matcher {
+    return explainedMatcher{name: name, inner: m, tax: tax}
+}
+
+func (m explainedMatcher) Match(student *domain.User, projects []*domain.Project) []domain.MatchResult {
+    res, _ := m.TryMatch(student, projects)
+    return res
+}
+
+func (m explainedMatcher) TryMatch(student *domain.User, projects []*domain.Project) ([]domain.MatchResult, error) {
+    res, err := tryMatch(m.inner, student, projects)
+    if err != nil { return nil, err }
+    for i := range res {
+        r := &res[i]
+        if r.Breakdown == nil {
+            var ev reqEval
+            if r.Project != nil { ev = evaluate(m.tax, student, projectRequirements(r.Project)) }
+            r.Breakdown = newBreakdown(defaultVersion, ev, component(m.name, 1, r.Score))
+            r.Breakdown.UnmetMandatory = ev.Unmet
+        }
+        if r.Breakdown.Matcher == "" { r.Breakdown.Matcher = m.name }
+    }
+    return res, nil
+}
+
+func (m explainedMatcher) Healthy() error {
+    if hc, ok := m.inner.(domain.HealthChecker); ok { return hc.Healthy() }
+    return nil
+}
+
+func (m explainedMatcher) ProjectChanged(p *domain.Project) {
+    if pi, ok := m.inner.(ProjectIndexer); ok { pi.ProjectChanged(p) }
+}
+
+func (m explainedMatcher) ProjectRemoved(id int64) {
+    if pi, ok := m.inner.(ProjectIndexer); ok { pi.ProjectRemoved(id) }
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+const maxBulkDecisions = 500
+
+// BulkDecision selects applications either by ID or by rule: every application of
+// ProjectID currently in FromStatus, e.g. "reject all remaining submitted".
+type BulkDecision struct {
+    ApplicationIDs []int64 `json:"application_ids"`
+    ProjectID      int64   `json:"project_id"`
+    FromStatus     string  `json:"from_status"`
+    Status         string  `json:"status"`
+    Reason         string  `json:"reason"`
+    Atomic         bool    `json:"atomic"` // apply all or nothing
+}
+
+type BulkResult struct {
+    ApplicationID int64  `json:"application_id"`
+    OK            bool   `json:"ok"`
+    Status        string `json:"status,omitempty"`
+    Error         string `json:"error,omitempty"`
+}
+
+// Bulk moves the selected applications to d.Status in one transaction. allow is the
+// caller's per-application permission check. Items that fail are reported and, unless
+// d.Atomic, the rest are still applied.
+func (l *LifecycleService) Bulk(actor *domain.User, d BulkDecision, allow func(*domain.Application) error) ([]BulkResult, error) {
+    to := strings.TrimSpace(d.Status)
+    if !domain.ValidApplicationStatus(to) { return nil, fmt.Errorf("无效状态: %s", d.Status) }
+    apps, results, err := l.selectBulk(d)
+    if err != nil { return nil, err }
+    change := func(app *domain.Application) *domain.ApplicationStatusChange {
+        return &domain.ApplicationStatusChange{ApplicationID: app.ID, From: app.Status, To: to, ActorID: actor.ID, ActorRole: actor.Role, Reason: strings.TrimSpace(d.Reason)}
+    }
+    var items []repository.StatusTransition
+    var pending []int // result index of each item
+    failed := false
+    for i, app := range apps {
+        r := &results[i]
+        if app == nil { failed = true; continue }
+        if err := allow(app); err != nil { r.Error, failed = err.Error(), true; continue }
+        if app.Status == to { r.OK, r.Status = true, to; continue }
+        if !roleMayMove(app.Status, to, actor.Role) { r.Error, failed = fmt.Sprintf("不能将申请从%s改为%s", app.Status, to), true; continue }
+        items = append(items, repository.StatusTransition{From: app.Status, To: to, Change: change(app)})
+        pending = append(pending, i)
+    }
+    if d.Atomic && failed {
+        for _, i := range pending { results[i].Error = repository.ErrBatchAborted.Error() }
+        return results, nil
+    }
+    if len(items) == 0 { return results, nil }
+    errs, err := l.history.TransitionApplications(items, d.Atomic)
+    if err != nil { return nil, err }
+    freed := map[int64]bool{}
+    for k, i := range pending {
+        if errs[k] != nil { results[i].Error = l.placedError(errs[k]).Error(); continue }
+        results[i].OK, results[i].Status = true, to
+        if items[k].From == domain.StatusApproved { freed[apps[i].ProjectID] = true }
+    }
+    for pid := range freed { l.promote(pid) }
+    return results, nil
+}
+
+// selectBulk resolves the selection; a nil application marks an unknown ID whose
+// result already carries the error.
+func (l *LifecycleService) selectBulk(d BulkDecision) ([]*domain.Application, []BulkResult, error) {
+    var apps []*domain.Application
+    var results []BulkResult
+    switch {
+    case len(d.ApplicationIDs) > 0 && d.ProjectID != 0:
+        return nil, nil, errors.New("申请ID列表与筛选规则只能二选一")
+    case len(d.ApplicationIDs) > 0:
+        seen := map[int64]bool{}
+        for _, id := range d.ApplicationIDs {
+            if seen[id] { continue }
+            seen[id] = true
+            app := l.svc.repo.GetApplication(id)
+            r := BulkResult{ApplicationID: id}
+            if app == nil { r.Error = "申请不存在" }
+            apps, results = append(apps, app), append(results, r)
+        }
+    case d.ProjectID != 0:
+        if !domain.ValidApplicationStatus(d.FromStatus) { return nil, nil, fmt.Errorf("无效状态: %s", d.FromStatus) }
+        if l.svc.repo.GetProject(d.ProjectID) == nil { return nil, nil, errors.New("项目不存在") }
+        for _, a := range l.svc.repo.ListApplications() {
+            if a.ProjectID == d.ProjectID && a.Status == d.FromStatus { apps, results = append(apps, a), append(results, BulkResult{ApplicationID: a.ID}) }
+        }
+    default:
+        return nil, nil, errors.New("缺少申请ID列表或筛选规则")
+    }
+    if len(apps) == 0 { return nil, nil, errors.New("没有符合条件的申请") }
+    if len(apps) > maxBulkDecisions { return nil, nil, fmt.Errorf("单次最多处理%d个申请", maxBulkDecisions) }
+    return apps, results, nil
+}
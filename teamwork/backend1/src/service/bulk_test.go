@@ -0,0 +1,119 @@
+package service
+
+import (
+    "errors"
+    "testing"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+// TransitionApplications applies items one by one and, when atomic and any failed,
+// puts every status and the history back.
+func (m *memLifecycle) TransitionApplications(items []repository.StatusTransition, atomic bool) ([]error, error) {
+    results := make([]error, len(items))
+    before, kept, failed := map[int64]string{}, len(m.history), false
+    for i, it := range items {
+        id := it.Change.ApplicationID
+        if a := m.repo.GetApplication(id); a != nil { if _, ok := before[id]; !ok { before[id] = a.Status } }
+        ok, err := m.TransitionApplication(id, it.From, it.To, it.Change)
+        var placed *repository.PlacedError
+        switch {
+        case errors.Is(err, repository.ErrCapacityFull), errors.As(err, &placed): results[i] = err
+        case err != nil: return nil, err
+        case !ok: results[i] = repository.ErrStaleStatus
+        }
+        if results[i] != nil { failed = true }
+    }
+    if atomic && failed {
+        for id, st := range before { m.repo.apps[id].Status = st }
+        m.history = m.history[:kept]
+        for i := range results { if results[i] == nil { results[i] = repository.ErrBatchAborted } }
+    }
+    return results, nil
+}
+
+func (m *memLifecycle) taken(projectID int64) int {
+    n := 0
+    for _, a := range m.repo.apps {
+        if a.ProjectID == projectID && (a.Status == domain.StatusApproved || a.Status == domain.StatusCompleted) { n += appSeats(a) }
+    }
+    return n
+}
+
+func allowAll(*domain.Application) error { return nil }
+
+// bulkApps adds n submitted applications to p from fresh students.
+func bulkApps(hist *memLifecycle, p *domain.Project, n int) []int64 {
+    var ids []int64
+    for i := 0; i < n; i++ {
+        u, _ := hist.repo.AddUser(&domain.User{Role: domain.RoleStudent})
+        id := hist.repo.id()
+        hist.repo.apps[id] = &domain.Application{ID: id, StudentID: u.ID, ProjectID: p.ID, Status: domain.StatusSubmitted}
+        ids = append(ids, id)
+    }
+    return ids
+}
+
+func TestBulkReportsEachItem(t *testing.T) {
+    l, hist, _, p := newLifecycleTest(t)
+    teacher := hist.repo.GetUser(p.TeacherID)
+    ids := bulkApps(hist, p, 3)
+    denied := ids[2]
+    allow := func(a *domain.Application) error {
+        if a.ID == denied { return errors.New("无权操作") }
+        return nil
+    }
+    res, err := l.Bulk(teacher, BulkDecision{ApplicationIDs: append(ids, 999), Status: domain.StatusRejected, Reason: "名额有限"}, allow)
+    if err != nil { t.Fatal(err) }
+    if len(res) != 4 { t.Fatalf("results = %+v", res) }
+    for i, want := range []bool{true, true, false, false} {
+        if res[i].OK != want { t.Errorf("item %d: %+v", res[i].ApplicationID, res[i]) }
+    }
+    for _, id := range ids[:2] {
+        if hist.repo.apps[id].Status != domain.StatusRejected { t.Errorf("application %d is %s", id, hist.repo.apps[id].Status) }
+        h := hist.ListStatusChanges(id)
+        if len(h) != 1 || h[0].ActorID != teacher.ID || h[0].Reason != "名额有限" { t.Errorf("history of %d = %+v", id, h) }
+    }
+    if hist.repo.apps[denied].Status != domain.StatusSubmitted { t.Error("denied application was changed") }
+}
+
+func TestBulkAtomicRollsBack(t *testing.T) {
+    l, hist, _, p := newLifecycleTest(t)
+    teacher := hist.repo.GetUser(p.TeacherID)
+    p.Capacity = 1
+    ids := bulkApps(hist, p, 2)
+    res, err := l.Bulk(teacher, BulkDecision{ApplicationIDs: ids, Status: domain.StatusApproved, Atomic: true}, allowAll)
+    if err != nil { t.Fatal(err) }
+    if res[0].OK || res[0].Error != repository.ErrBatchAborted.Error() { t.Errorf("first item = %+v", res[0]) }
+    if res[1].Error != repository.ErrCapacityFull.Error() { t.Errorf("second item = %+v", res[1]) }
+    for _, id := range ids {
+        if hist.repo.apps[id].Status != domain.StatusSubmitted || len(hist.ListStatusChanges(id)) != 0 { t.Errorf("application %d kept a change", id) }
+    }
+}
+
+func TestBulkRuleSelectsRemaining(t *testing.T) {
+    l, hist, _, p := newLifecycleTest(t)
+    teacher := hist.repo.GetUser(p.TeacherID)
+    ids := bulkApps(hist, p, 3)
+    hist.repo.apps[ids[0]].Status = domain.StatusApproved
+    res, err := l.Bulk(teacher, BulkDecision{ProjectID: p.ID, FromStatus: domain.StatusSubmitted, Status: domain.StatusRejected}, allowAll)
+    if err != nil { t.Fatal(err) }
+    if len(res) != 2 { t.Fatalf("results = %+v", res) }
+    if hist.repo.apps[ids[0]].Status != domain.StatusApproved { t.Error("approved application was selected") }
+    if _, err := l.Bulk(teacher, BulkDecision{ProjectID: p.ID, FromStatus: domain.StatusSubmitted, Status: domain.StatusRejected}, allowAll); err == nil {
+        t.Error("empty selection accepted")
+    }
+}
+
+func TestBulkApprovalChecksTeamPlacement(t *testing.T) {
+    l, hist, stu, p := newLifecycleTest(t)
+    teacher := hist.repo.GetUser(p.TeacherID)
+    other, _ := hist.repo.AddProject(&domain.Project{Title: "q", TeacherID: p.TeacherID})
+    hist.repo.apps[50] = &domain.Application{ID: 50, StudentID: 99, ProjectID: other.ID, TeamID: 7, Members: []int64{99, stu.ID}, Status: domain.StatusApproved}
+    hist.repo.apps[51] = &domain.Application{ID: 51, StudentID: stu.ID, ProjectID: p.ID, Status: domain.StatusSubmitted}
+    res, err := l.Bulk(teacher, BulkDecision{ApplicationIDs: []int64{51}, Status: domain.StatusApproved}, allowAll)
+    if err != nil { t.Fatal(err) }
+    if res[0].OK || res[0].Error != "stu已通过其他申请被录取" { t.Errorf("result = %+v", res[0]) }
+    if hist.repo.apps[51].Status != domain.StatusSubmitted { t.Error("placed student approved twice") }
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+    "unicode/utf8"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/mailer"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+const (
+    commentEditWindow = 15 * time.Minute
+    maxCommentLen     = 2000
+)
+
+// CommentService keeps the message thread of each application. Who may take part is
+// decided by the policy before these methods are called.
+type CommentService struct {
+    svc      *Service
+    comments repository.CommentRepo
+    mailer   mailer.Mailer
+}
+
+func NewCommentService(s *Service, comments repository.CommentRepo, m mailer.Mailer) *CommentService {
+    return &CommentService{svc: s, comments: comments, mailer: m}
+}
+
+type CommentView struct {
+    *domain.ApplicationComment
+    Author *domain.User `json:"author"`
+    Read   bool         `json:"read"` // someone else has read it
+}
+
+type CommentPage struct {
+    Comments []CommentView         `json:"comments"`
+    Total    int64                 `json:"total"`
+    Page     int                   `json:"page"`
+    PageSize int                   `json:"page_size"`
+    Receipts []*domain.CommentRead `json:"receipts"`
+}
+
+// Thread returns a page of the thread, oldest first, and with markRead marks it read
+// for the viewer. An admin impersonating the viewer passes false so that the read
+// receipts stay the viewer's own.
+func (c *CommentService) Thread(viewer *domain.User, appID int64, page, size int, markRead bool) (*CommentPage, error) {
+    if page <= 0 { page = 1 }
+    if size <= 0 || size > 100 { size = 50 }
+    list, total := c.comments.ListComments(appID, (page-1)*size, size)
+    reads := c.comments.ListReads(appID)
+    out := &CommentPage{Total: total, Page: page, PageSize: size, Receipts: reads, Comments: []CommentView{}}
+    var last int64
+    for _, cm := range list {
+        if cm.DeletedAt != nil { cm.Body = "" }
+        v := CommentView{ApplicationComment: cm, Author: c.svc.repo.GetUser(cm.AuthorID)}
+        for _, r := range reads { if r.UserID != cm.AuthorID && r.LastReadID >= cm.ID { v.Read = true; break } }
+        out.Comments = append(out.Comments, v)
+        last = cm.ID
+    }
+    if last > 0 && markRead {
+        if err := c.comments.MarkRead(appID, viewer.ID, last); err != nil { return nil, err }
+    }
+    return out, nil
+}
+
+func checkCommentBody(body string) (string, error) {
+    body = strings.TrimSpace(body)
+    if body == "" { return "", errors.New("留言不能为空") }
+    if utf8.RuneCountInString(body) > maxCommentLen { return "", fmt.Errorf("留言不能超过%d字", maxCommentLen) }
+    return body, nil
+}
+
+// Post adds a message and mails the other side of the thread.
+func (c *CommentService) Post(author *domain.User, appID int64, body string) (*domain.ApplicationComment, error) {
+    body, err := checkCommentBody(body)
+    if err != nil { return nil, err }
+    app := c.svc.repo.GetApplication(appID)
+    if app == nil { return nil, errors.New("申请不存在") }
+    cm, err := c.comments.AddComment(&domain.ApplicationComment{ApplicationID: appID, AuthorID: author.ID, Body: body})
+    if err != nil { return nil, err }
+    _ = c.comments.MarkRead(appID, author.ID, cm.ID)
+    c.notify(author, app)
+    return cm, nil
+}
+
+func (c *CommentService) notify(author *domain.User, app *domain.Application) {
+    p := c.svc.repo.GetProject(app.ProjectID)
+    if p == nil { return }
+    to := app.StudentID
+    if author.ID == app.StudentID || containsID(app.Members, author.ID) { to = p.TeacherID }
+    if u := c.svc.repo.GetUser(to); u != nil && u.ID != author.ID {
+        sendAsync(c.mailer, u.Email, "新留言："+p.Title, fmt.Sprintf("%s，您好：\n\n%s 在项目《%s》的申请中给您留言，请登录系统查看。\n", u.Name, author.Name, p.Title))
+    }
+}
+
+// Edit changes the author's own message within the edit window.
+func (c *CommentService) Edit(author *domain.User, id int64, body string) (*domain.ApplicationComment, error) {
+    body, err := checkCommentBody(body)
+    if err != nil { return nil, err }
+    cm := c.comments.GetComment(id)
+    if cm == nil || cm.DeletedAt != nil { return nil, errors.New("留言不存在") }
+    if cm.AuthorID != author.ID { return nil, ErrForbidden }
+    if time.Since(cm.CreatedAt) > commentEditWindow { return nil, fmt.Errorf("留言发布%d分钟后不能再修改", int(commentEditWindow.Minutes())) }
+    now := time.Now()
+    cm.Body, cm.EditedAt = body, &now
+    return cm, c.comments.UpdateComment(cm)
+}
+
+// Delete hides a message; authors delete their own, admins any.
+func (c *CommentService) Delete(u *domain.User, id int64) error {
+    cm := c.comments.GetComment(id)
+    if cm == nil || cm.DeletedAt != nil { return errors.New("留言不存在") }
+    if cm.AuthorID != u.ID && u.Role != domain.RoleAdmin { return ErrForbidden }
+    now := time.Now()
+    cm.DeletedAt = &now
+    return c.comments.UpdateComment(cm)
+}
+
+func (c *CommentService) Comment(id int64) *domain.ApplicationComment { return c.comments.GetComment(id) }
+
+// MarkRead marks the whole thread read without fetching it.
+func (c *CommentService) MarkRead(userID, appID int64) error {
+    last := c.comments.LastCommentID(appID)
+    if last == 0 { return nil }
+    return c.comments.MarkRead(appID, userID, last)
+}
+
+// FillUnread sets the viewer's unread count on each view whose thread allowed lets
+// them see.
+func (c *CommentService) FillUnread(viewerID int64, views []domain.ApplicationView, allowed func(*domain.Application) bool) {
+    var ids []int64
+    for _, v := range views { if v.Application != nil && allowed(v.Application) { ids = append(ids, v.Application.ID) } }
+    counts := c.comments.CountUnread(viewerID, ids)
+    for i := range views { if views[i].Application != nil { views[i].Unread = counts[views[i].Application.ID] } }
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+    "testing"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+// memComments keeps one thread's comments and read markers.
+type memComments struct {
+    repository.CommentRepo
+    list  []*domain.ApplicationComment
+    reads map[int64]int64 // user -> last read comment
+}
+
+func (m *memComments) ListComments(appID int64, offset, limit int) ([]*domain.ApplicationComment, int64) {
+    var out []*domain.ApplicationComment
+    for _, c := range m.list { if c.ApplicationID == appID { out = append(out, c) } }
+    total := int64(len(out))
+    if offset > len(out) { offset = len(out) }
+    out = out[offset:]
+    if len(out) > limit { out = out[:limit] }
+    return out, total
+}
+
+func (m *memComments) ListReads(appID int64) []*domain.CommentRead {
+    var out []*domain.CommentRead
+    for u, id := range m.reads { out = append(out, &domain.CommentRead{ApplicationID: appID, UserID: u, LastReadID: id}) }
+    return out
+}
+
+func (m *memComments) MarkRead(appID, userID, lastID int64) error {
+    if lastID > m.reads[userID] { m.reads[userID] = lastID }
+    return nil
+}
+
+func TestThreadMarksReadOnlyWhenAsked(t *testing.T) {
+    repo := newMemRepo()
+    stu, _ := repo.AddUser(&domain.User{Name: "stu", Role: domain.RoleStudent})
+    tea, _ := repo.AddUser(&domain.User{Name: "tea", Role: domain.RoleTeacher})
+    comments := &memComments{reads: map[int64]int64{}, list: []*domain.ApplicationComment{{ID: 1, ApplicationID: 9, AuthorID: tea.ID, Body: "请补充材料"}}}
+    c := NewCommentService(&Service{repo: repo}, comments, nil)
+
+    page, err := c.Thread(stu, 9, 1, 10, false)
+    if err != nil { t.Fatal(err) }
+    if len(page.Comments) != 1 { t.Fatalf("comments = %+v", page.Comments) }
+    if _, ok := comments.reads[stu.ID]; ok { t.Error("impersonated view marked the thread read") }
+
+    if _, err := c.Thread(stu, 9, 1, 10, true); err != nil { t.Fatal(err) }
+    if comments.reads[stu.ID] != 1 { t.Errorf("read marker = %d, want 1", comments.reads[stu.ID]) }
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+// ExternalIdentity is what an external provider (OIDC, LDAP) knows about a user.
+type ExternalIdentity struct {
+    Provider      string
+    Subject       string
+    Email         string
+    Name          string
+    EmailVerified bool
+    Role          domain.Role
+    // RoleMapped means Role came from the provider's groups and overrides the local role.
+    RoleMapped bool
+}
+
+// ErrAccountNotLinked means a local account already uses the email and the provider may
+// not take it over by itself; the owner has to link it while logged in.
+var ErrAccountNotLinked = errors.New("该邮箱已有本地账号，请用原密码登录后在个人设置中绑定")
+
+// externalUsers maps external identities onto domain.User, creating users on first
+// login. Existing accounts with the same email are linked only if linkByEmail is set and
+// the provider says the email is verified.
+type externalUsers struct {
+    svc         *Service
+    links       repository.SSORepo
+    accounts    repository.AccountRepo
+    tokens      *TokenService
+    linkByEmail bool
+}
+
+func (x *externalUsers) resolve(id ExternalIdentity) (*domain.User, error) {
+    if id.Subject == "" { return nil, errors.New("身份提供方未返回用户标识") }
+    var u *domain.User
+    if link := x.links.GetExternalIdentity(id.Provider, id.Subject); link != nil {
+        u = x.svc.repo.GetUser(link.UserID)
+    }
+    if u == nil && id.Email != "" {
+        if existing := x.svc.repo.GetUserByEmail(id.Email); existing != nil {
+            if !x.linkByEmail { return nil, ErrAccountNotLinked }
+            if id.EmailVerified {
+                if err := x.link(existing, id); err != nil { return nil, err }
+                u = existing
+            }
+        }
+    }
+    if u == nil {
+        if id.Email == "" { return nil, errors.New("身份提供方未返回邮箱") }
+        name := id.Name
+        if name == "" { name = id.Email }
+        created, err := x.svc.CreateUser(&domain.User{Name: name, Email: id.Email, Role: id.Role, EmailVerified: id.EmailVerified})
+        if err != nil { return nil, err }
+        if err := x.links.AddExternalIdentity(&domain.ExternalIdentity{Provider: id.Provider, Subject: id.Subject, UserID: created.ID}); err != nil { return nil, err }
+        return created, nil
+    }
+    if !u.EmailVerified && id.EmailVerified {
+        if err := x.accounts.SetEmailVerified(u.ID, true); err != nil { return nil, err }
+        u.EmailVerified = true
+    }
+    // group membership at the provider is authoritative for roles it maps
+    if id.RoleMapped && u.Role != id.Role {
+        if err := x.tokens.UpdateUserRole(u.ID, id.Role); err != nil { return nil, err }
+        u.Role = id.Role
+    }
+    return u, nil
+}
+
+// link records that id belongs to u, refusing a subject already linked to someone else.
+func (x *externalUsers) link(u *domain.User, id ExternalIdentity) error {
+    if cur := x.links.GetExternalIdentity(id.Provider, id.Subject); cur != nil {
+        if cur.UserID != u.ID { return errors.New("该外部账号已绑定其他用户") }
+        return nil
+    }
+    return x.links.AddExternalIdentity(&domain.ExternalIdentity{Provider: id.Provider, Subject: id.Subject, UserID: u.ID})
+}
+
+var roleRank = map[domain.Role]int{domain.RoleStudent: 1, domain.RoleTeacher: 2, domain.RoleAdmin: 3}
+
+// mapGroups picks the most privileged role among the mapped groups, falling back to def.
+func mapGroups(groups []string, mapping map[string]domain.Role, def domain.Role) (domain.Role, bool) {
+    best, mapped := def, false
+    for _, g := range groups {
+        r, ok := mapping[g]
+        if !ok { continue }
+        if !mapped || roleRank[r] > roleRank[best] { best, mapped = r, true }
+    }
+    return best, mapped
+}
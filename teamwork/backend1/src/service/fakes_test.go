@@ -0,0 +1,110 @@
+package service
+
+import (
+    "sync"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+// memRepo is an in-memory core repository for tests. Methods a test needs but that
+// are not implemented here panic through the nil embedded interface.
+type memRepo struct {
+    repository.Repo
+    mu       sync.Mutex
+    users    map[int64]*domain.User
+    projects map[int64]*domain.Project
+    apps     map[int64]*domain.Application
+    nextID   int64
+}
+
+func newMemRepo() *memRepo {
+    return &memRepo{users: map[int64]*domain.User{}, projects: map[int64]*domain.Project{}, apps: map[int64]*domain.Application{}}
+}
+
+func (r *memRepo) id() int64 { r.nextID++; return r.nextID }
+
+func (r *memRepo) AddUser(u *domain.User) (*domain.User, error) {
+    r.mu.Lock(); defer r.mu.Unlock()
+    if u.ID == 0 { u.ID = r.id() }
+    r.users[u.ID] = u
+    return u, nil
+}
+
+func (r *memRepo) GetUser(id int64) *domain.User {
+    r.mu.Lock(); defer r.mu.Unlock()
+    return r.users[id]
+}
+
+func (r *memRepo) UpdateUser(u *domain.User) (*domain.User, error) {
+    r.mu.Lock(); defer r.mu.Unlock()
+    r.users[u.ID] = u
+    return u, nil
+}
+
+func (r *memRepo) ListUsers() []*domain.User {
+    r.mu.Lock(); defer r.mu.Unlock()
+    var out []*domain.User
+    for _, u := range r.users { out = append(out, u) }
+    return out
+}
+
+func (r *memRepo) AddProject(p *domain.Project) (*domain.Project, error) {
+    r.mu.Lock(); defer r.mu.Unlock()
+    if p.ID == 0 { p.ID = r.id() }
+    r.projects[p.ID] = p
+    return p, nil
+}
+
+func (r *memRepo) GetProject(id int64) *domain.Project {
+    r.mu.Lock(); defer r.mu.Unlock()
+    return r.projects[id]
+}
+
+func (r *memRepo) ListProjects() []*domain.Project {
+    r.mu.Lock(); defer r.mu.Unlock()
+    var out []*domain.Project
+    for _, p := range r.projects { out = append(out, p) }
+    return out
+}
+
+func (r *memRepo) UpdateProject(p *domain.Project) (*domain.Project, error) {
+    r.mu.Lock(); defer r.mu.Unlock()
+    r.projects[p.ID] = p
+    return p, nil
+}
+
+func (r *memRepo) DeleteProject(id int64) error {
+    r.mu.Lock(); defer r.mu.Unlock()
+    delete(r.projects, id)
+    return nil
+}
+
+func (r *memRepo) GetApplication(id int64) *domain.Application {
+    r.mu.Lock(); defer r.mu.Unlock()
+    return r.apps[id]
+}
+
+func (r *memRepo) ListApplications() []*domain.Application {
+    r.mu.Lock(); defer r.mu.Unlock()
+    var out []*domain.Application
+    for _, a := range r.apps { out = append(out, a) }
+    return out
+}
+
+func (r *memRepo) GetUserByEmail(email string) *domain.User {
+    r.mu.Lock(); defer r.mu.Unlock()
+    for _, u := range r.users {
+        if u.Email == email { return u }
+    }
+    return nil
+}
+
+func (r *memRepo) ListApplicationsByStudent(studentID int64, status string) []*domain.Application {
+    r.mu.Lock(); defer r.mu.Unlock()
+    var out []*domain.Application
+    for _, a := range r.apps {
+        if a.StudentID == studentID && (status == "" || a.Status == status) { out = append(out, a) }
+    }
+    return out
+}
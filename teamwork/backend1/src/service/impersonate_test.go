@@ -0,0 +1,43 @@
+package service
+
+import (
+    "errors"
+    "testing"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+type memAudit struct {
+    repository.AuditRepo
+    logs []*domain.AuditLog
+    fail bool
+}
+
+func (m *memAudit) AddAuditLog(l *domain.AuditLog) error {
+    if m.fail { return errors.New("db down") }
+    m.logs = append(m.logs, l)
+    return nil
+}
+
+func TestImpersonationStart(t *testing.T) {
+    useTestKeys(t)
+    repo := newMemRepo()
+    admin, _ := repo.AddUser(&domain.User{Name: "adm", Role: domain.RoleAdmin})
+    other, _ := repo.AddUser(&domain.User{Name: "adm2", Role: domain.RoleAdmin})
+    stu, _ := repo.AddUser(&domain.User{Name: "stu", Role: domain.RoleStudent})
+    audit := &memAudit{}
+    imp := NewImpersonationService(&Service{repo: repo}, audit)
+
+    if _, _, _, err := imp.Start(admin, stu.ID, " ", "10.0.0.1"); err == nil { t.Error("started without a reason") }
+    if _, _, _, err := imp.Start(admin, other.ID, "check", "10.0.0.1"); err == nil { t.Error("impersonated another admin") }
+    if _, _, _, err := imp.Start(stu, stu.ID, "check", "10.0.0.1"); err != ErrForbidden { t.Errorf("non-admin: %v", err) }
+
+    tok, cl, u, err := imp.Start(admin, stu.ID, "ticket 42", "10.0.0.1")
+    if err != nil { t.Fatal(err) }
+    if tok == "" || cl.Actor != admin.ID || cl.UserID != stu.ID || u.ID != stu.ID { t.Errorf("token claims %+v", cl) }
+    if len(audit.logs) != 1 || audit.logs[0].Detail != "ticket 42" || audit.logs[0].TokenID != cl.ID { t.Errorf("audit %+v", audit.logs) }
+
+    audit.fail = true
+    if tok, _, _, err := imp.Start(admin, stu.ID, "again", "10.0.0.1"); err == nil || tok != "" { t.Error("token issued without an audit record") }
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/auth"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/ical"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/mailer"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+const (
+    maxSlotsPerRequest = 50
+    maxSlotLength      = 4 * time.Hour
+)
+
+// calendarFeedTTL bounds a feed link that is never rotated or revoked.
+const calendarFeedTTL = 365 * 24 * time.Hour
+
+// InterviewService lets supervisors offer interview slots and applicants book them.
+type InterviewService struct {
+    svc      *Service
+    slots    repository.InterviewRepo
+    life     *LifecycleService
+    accounts repository.AccountRepo
+    mailer   mailer.Mailer
+}
+
+func NewInterviewService(s *Service, slots repository.InterviewRepo, life *LifecycleService, accounts repository.AccountRepo, m mailer.Mailer) *InterviewService {
+    return &InterviewService{svc: s, slots: slots, life: life, accounts: accounts, mailer: m}
+}
+
+// SlotView is a slot with its booking. Student is only filled in for supervisors.
+type SlotView struct {
+    *domain.InterviewSlot
+    Booked  bool         `json:"booked"`
+    Student *domain.User `json:"student,omitempty"`
+}
+
+func (s *InterviewService) Slot(id int64) *domain.InterviewSlot { return s.slots.GetSlot(id) }
+
+// holder returns the application that has booked the slot, if it still awaits its interview.
+func (s *InterviewService) holder(slot *domain.InterviewSlot) *domain.Application {
+    if slot.ApplicationID == 0 { return nil }
+    app := s.svc.repo.GetApplication(slot.ApplicationID)
+    if app == nil || app.Status != domain.StatusInterview { return nil }
+    return app
+}
+
+func overlaps(a, b *domain.InterviewSlot) bool { return a.StartsAt.Before(b.EndsAt) && b.StartsAt.Before(a.EndsAt) }
+
+func (s *InterviewService) AddSlots(actor *domain.User, projectID int64, slots []*domain.InterviewSlot) ([]*domain.InterviewSlot, error) {
+    if len(slots) == 0 { return nil, errors.New("缺少面试时段") }
+    if len(slots) > maxSlotsPerRequest { return nil, fmt.Errorf("一次最多添加%d个时段", maxSlotsPerRequest) }
+    if s.svc.repo.GetProject(projectID) == nil { return nil, errors.New("项目不存在") }
+    existing := s.slots.ListProjectSlots([]int64{projectID})
+    now := time.Now()
+    for i, sl := range slots {
+        if sl.StartsAt.IsZero() || sl.EndsAt.IsZero() { return nil, errors.New("缺少开始或结束时间") }
+        if !sl.StartsAt.Before(sl.EndsAt) || sl.EndsAt.Sub(sl.StartsAt) > maxSlotLength { return nil, fmt.Errorf("时段长度须在0到%d小时之间", int(maxSlotLength.Hours())) }
+        if sl.StartsAt.Before(now) { return nil, errors.New("不能添加已过去的时段") }
+        sl.ID, sl.ProjectID, sl.ApplicationID, sl.CreatedBy = 0, projectID, 0, actor.ID
+        sl.Location = strings.TrimSpace(sl.Location)
+        for _, o := range existing { if overlaps(sl, o) { return nil, fmt.Errorf("时段 %s 与已有时段重叠", sl.StartsAt.Format("2006-01-02 15:04")) } }
+        for _, o := range slots[:i] { if overlaps(sl, o) { return nil, fmt.Errorf("时段 %s 与同批时段重叠", sl.StartsAt.Format("2006-01-02 15:04")) } }
+    }
+    if err := s.slots.AddSlots(slots); err != nil { return nil, err }
+    return slots, nil
+}
+
+// ProjectSlots lists every slot of a project with who booked it, for supervisors.
+func (s *InterviewService) ProjectSlots(projectID int64) []SlotView {
+    var out []SlotView
+    for _, sl := range s.slots.ListProjectSlots([]int64{projectID}) {
+        v := SlotView{InterviewSlot: sl}
+        if app := s.holder(sl); app != nil { v.Booked, v.Student = true, s.svc.repo.GetUser(app.StudentID) }
+        out = append(out, v)
+    }
+    return out
+}
+
+// OpenSlots lists the future slots of a project a student can still pick, plus their own.
+func (s *InterviewService) OpenSlots(studentID, projectID int64) []SlotView {
+    now := time.Now()
+    var out []SlotView
+    for _, sl := range s.slots.ListProjectSlots([]int64{projectID}) {
+        app := s.holder(sl)
+        mine := app != nil && app.StudentID == studentID
+        if !mine && (app != nil || sl.StartsAt.Before(now)) { continue }
+        if !mine { sl.ApplicationID = 0 } // never reveal a stale booking
+        out = append(out, SlotView{InterviewSlot: sl, Booked: mine})
+    }
+    return out
+}
+
+// DeleteSlot removes a slot and tells the student who had booked it.
+func (s *InterviewService) DeleteSlot(id int64) error {
+    sl := s.slots.GetSlot(id)
+    if sl == nil { return errors.New("面试时段不存在") }
+    app := s.holder(sl)
+    if err := s.slots.DeleteSlot(id); err != nil { return err }
+    if app == nil { return nil }
+    if stu, p := s.svc.repo.GetUser(app.StudentID), s.svc.repo.GetProject(app.ProjectID); stu != nil && p != nil {
+        sendAsync(s.mailer, stu.Email, "面试时段已取消："+p.Title, fmt.Sprintf("%s，您好：\n\n您预约的项目《%s》面试（%s）已被取消，请重新选择时段。\n", stu.Name, p.Title, sl.StartsAt.Format("2006-01-02 15:04")))
+    }
+    return nil
+}
+
+// Invite moves the application to interview and asks the student to book a slot.
+func (s *InterviewService) Invite(actor *domain.User, appID int64, message string) (*domain.Application, error) {
+    app, err := s.life.Transition(actor, appID, domain.StatusInterview, "邀请面试")
+    if err != nil { return nil, err }
+    if stu, p := s.svc.repo.GetUser(app.StudentID), s.svc.repo.GetProject(app.ProjectID); stu != nil && p != nil {
+        body := fmt.Sprintf("%s，您好：\n\n您对项目《%s》的申请已进入面试环节，请登录系统选择面试时段。\n", stu.Name, p.Title)
+        if m := strings.TrimSpace(message); m != "" { body += "\n导师留言：" + m + "\n" }
+        sendAsync(s.mailer, stu.Email, "面试邀请："+p.Title, body)
+    }
+    return app, nil
+}
+
+// Book reserves a slot for the student's application, replacing any slot booked
+// before. It refuses slots that clash with the student's other interviews.
+func (s *InterviewService) Book(studentID, appID, slotID int64) (*domain.InterviewSlot, error) {
+    app := s.svc.repo.GetApplication(appID)
+    if app == nil || app.StudentID != studentID { return nil, errors.New("申请不存在") }
+    if app.Status != domain.StatusInterview { return nil, errors.New("该申请当前不需要面试") }
+    sl := s.slots.GetSlot(slotID)
+    if sl == nil || sl.ProjectID != app.ProjectID { return nil, errors.New("面试时段不存在") }
+    if sl.ApplicationID == app.ID { return sl, nil }
+    if sl.StartsAt.Before(time.Now()) { return nil, errors.New("该时段已过去") }
+    var others []int64
+    for _, a := range s.svc.studentApplications(studentID, domain.StatusInterview) {
+        if a.ID != app.ID { others = append(others, a.ID) }
+    }
+    clash, err := s.slots.BookSlot(sl.ID, app.ID, studentID, others)
+    if err != nil { return nil, err }
+    if clash != nil {
+        title := ""
+        if p := s.svc.repo.GetProject(clash.ProjectID); p != nil { title = p.Title }
+        return nil, fmt.Errorf("与项目《%s》的面试时间冲突", title)
+    }
+    sl.ApplicationID = app.ID
+    if stu, p := s.svc.repo.GetUser(studentID), s.svc.repo.GetProject(app.ProjectID); stu != nil && p != nil {
+        if t := s.svc.repo.GetUser(p.TeacherID); t != nil {
+            sendAsync(s.mailer, t.Email, "面试预约："+p.Title, fmt.Sprintf("%s，您好：\n\n%s 预约了项目《%s》的面试：%s %s。\n", t.Name, stu.Name, p.Title, sl.StartsAt.Format("2006-01-02 15:04"), sl.Location))
+        }
+    }
+    return sl, nil
+}
+
+// Calendar returns the user's booked interviews: their own as a student, those of the
+// projects they supervise otherwise.
+func (s *InterviewService) Calendar(u *domain.User) []ical.Event {
+    var slots []*domain.InterviewSlot
+    if u.Role == domain.RoleStudent {
+        var ids []int64
+        for _, a := range s.svc.studentApplications(u.ID, domain.StatusInterview) { ids = append(ids, a.ID) }
+        slots = s.slots.ListApplicationSlots(ids)
+    } else {
+        var pids []int64
+        for _, p := range s.svc.repo.ListProjects() {
+            if p.TeacherID == u.ID || containsID(p.CoSupervisors, u.ID) { pids = append(pids, p.ID) }
+        }
+        slots = s.slots.ListProjectSlots(pids)
+    }
+    var out []ical.Event
+    for _, sl := range slots {
+        app := s.holder(sl)
+        p := s.svc.repo.GetProject(sl.ProjectID)
+        if app == nil || p == nil { continue }
+        ev := ical.Event{UID: fmt.Sprintf("interview-%d-%d@sc-exp", sl.ID, app.ID), Start: sl.StartsAt, End: sl.EndsAt, Location: sl.Location, Summary: "面试：" + p.Title}
+        if u.Role != domain.RoleStudent {
+            if stu := s.svc.repo.GetUser(app.StudentID); stu != nil { ev.Summary, ev.Description = fmt.Sprintf("面试：%s（%s）", stu.Name, p.Title), stu.Email }
+        }
+        out = append(out, ev)
+    }
+    return out
+}
+
+// NewFeedToken issues the user's calendar feed token. Calendar clients cannot send a
+// session, so the token goes into the feed URL; issuing one revokes the previous one.
+func (s *InterviewService) NewFeedToken(userID int64) (string, error) {
+    if err := s.RevokeFeedToken(userID); err != nil { return "", err }
+    raw := auth.NewTokenID() + auth.NewTokenID()
+    t := &domain.UserToken{UserID: userID, Purpose: domain.TokenPurposeCalendarFeed, TokenHash: hashToken(raw), ExpiresAt: time.Now().Add(calendarFeedTTL)}
+    if _, err := s.accounts.AddUserToken(t); err != nil { return "", err }
+    return raw, nil
+}
+
+func (s *InterviewService) RevokeFeedToken(userID int64) error {
+    return s.accounts.InvalidateUserTokens(userID, domain.TokenPurposeCalendarFeed)
+}
+
+// FeedUser returns the owner of a live feed token.
+func (s *InterviewService) FeedUser(raw string) (*domain.User, error) {
+    if raw == "" { return nil, errors.New("日历链接无效") }
+    t := s.accounts.GetUserTokenByHash(hashToken(raw))
+    if t == nil || t.Purpose != domain.TokenPurposeCalendarFeed || t.UsedAt != nil || time.Now().After(t.ExpiresAt) { return nil, errors.New("日历链接无效") }
+    u := s.svc.repo.GetUser(t.UserID)
+    if u == nil { return nil, errors.New("日历链接无效") }
+    return u, nil
+}
+
+func containsID(ids []int64, id int64) bool {
+    for _, v := range ids { if v == id { return true } }
+    return false
+}
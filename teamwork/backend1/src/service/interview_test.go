@@ -0,0 +1,113 @@
+package service
+
+import (
+    "errors"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+// memInterviews holds slots in memory; one mutex around BookSlot stands in for the
+// gorm repository's lock on the student.
+type memInterviews struct {
+    repository.InterviewRepo
+    mu    sync.Mutex
+    repo  *memRepo
+    slots []*domain.InterviewSlot
+}
+
+func (m *memInterviews) GetSlot(id int64) *domain.InterviewSlot {
+    m.mu.Lock(); defer m.mu.Unlock()
+    for _, s := range m.slots { if s.ID == id { cp := *s; return &cp } }
+    return nil
+}
+
+func (m *memInterviews) ListApplicationSlots(appIDs []int64) []*domain.InterviewSlot {
+    m.mu.Lock(); defer m.mu.Unlock()
+    var out []*domain.InterviewSlot
+    for _, s := range m.slots { if containsID(appIDs, s.ApplicationID) { cp := *s; out = append(out, &cp) } }
+    return out
+}
+
+func (m *memInterviews) BookSlot(slotID, appID, studentID int64, others []int64) (*domain.InterviewSlot, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    var sl *domain.InterviewSlot
+    for _, s := range m.slots { if s.ID == slotID { sl = s } }
+    for _, o := range m.slots {
+        if containsID(others, o.ApplicationID) && overlaps(sl, o) { cp := *o; return &cp, nil }
+    }
+    if h := m.repo.GetApplication(sl.ApplicationID); sl.ApplicationID != 0 && sl.ApplicationID != appID && h != nil && h.Status == domain.StatusInterview {
+        return nil, repository.ErrSlotTaken
+    }
+    for _, s := range m.slots { if s.ApplicationID == appID { s.ApplicationID = 0 } }
+    sl.ApplicationID = appID
+    return nil, nil
+}
+
+func newInterviewTest(t *testing.T) (*InterviewService, *memInterviews, *domain.User, *domain.Project) {
+    l, hist, stu, p := newLifecycleTest(t)
+    slots := &memInterviews{repo: hist.repo}
+    return NewInterviewService(l.svc, slots, l, &memAccounts{users: hist.repo}, nil), slots, stu, p
+}
+
+// inInterview adds an application of stu to a new project with one slot starting at.
+func inInterview(s *InterviewService, slots *memInterviews, stu *domain.User, teacherID int64, at time.Time) (*domain.Application, *domain.InterviewSlot) {
+    repo := slots.repo
+    p, _ := repo.AddProject(&domain.Project{Title: "p", TeacherID: teacherID})
+    app := &domain.Application{ID: repo.id(), StudentID: stu.ID, ProjectID: p.ID, Status: domain.StatusInterview}
+    repo.apps[app.ID] = app
+    sl := &domain.InterviewSlot{ID: repo.id(), ProjectID: p.ID, StartsAt: at, EndsAt: at.Add(30 * time.Minute)}
+    slots.slots = append(slots.slots, sl)
+    return app, sl
+}
+
+func TestBookRefusesOverlappingInterviews(t *testing.T) {
+    s, slots, stu, p := newInterviewTest(t)
+    at := time.Now().Add(24 * time.Hour)
+    a1, s1 := inInterview(s, slots, stu, p.TeacherID, at)
+    a2, s2 := inInterview(s, slots, stu, p.TeacherID, at.Add(15*time.Minute))
+    if _, err := s.Book(stu.ID, a1.ID, s1.ID); err != nil { t.Fatal(err) }
+    if _, err := s.Book(stu.ID, a2.ID, s2.ID); err == nil { t.Fatal("overlapping interview booked") }
+}
+
+func TestConcurrentBookingsCannotOverlap(t *testing.T) {
+    s, slots, stu, p := newInterviewTest(t)
+    at := time.Now().Add(24 * time.Hour)
+    a1, s1 := inInterview(s, slots, stu, p.TeacherID, at)
+    a2, s2 := inInterview(s, slots, stu, p.TeacherID, at.Add(15*time.Minute))
+    var wg sync.WaitGroup
+    errs := make([]error, 2)
+    for i, b := range [][2]int64{{a1.ID, s1.ID}, {a2.ID, s2.ID}} {
+        wg.Add(1)
+        go func() { defer wg.Done(); _, errs[i] = s.Book(stu.ID, b[0], b[1]) }()
+    }
+    wg.Wait()
+    if (errs[0] == nil) == (errs[1] == nil) { t.Fatalf("want exactly one booking, got errors %v", errs) }
+}
+
+func TestBookReportsTakenSlot(t *testing.T) {
+    s, slots, stu, p := newInterviewTest(t)
+    other, _ := slots.repo.AddUser(&domain.User{Role: domain.RoleStudent})
+    _, sl := inInterview(s, slots, stu, p.TeacherID, time.Now().Add(time.Hour))
+    theirs := &domain.Application{ID: slots.repo.id(), StudentID: other.ID, ProjectID: sl.ProjectID, Status: domain.StatusInterview}
+    slots.repo.apps[theirs.ID] = theirs
+    if _, err := s.Book(other.ID, theirs.ID, sl.ID); err != nil { t.Fatal(err) }
+    mine := slots.repo.ListApplicationsByStudent(stu.ID, domain.StatusInterview)[0]
+    if _, err := s.Book(stu.ID, mine.ID, sl.ID); !errors.Is(err, repository.ErrSlotTaken) { t.Errorf("err = %v, want ErrSlotTaken", err) }
+}
+
+func TestFeedTokenIsRevocable(t *testing.T) {
+    s, _, stu, _ := newInterviewTest(t)
+    first, err := s.NewFeedToken(stu.ID)
+    if err != nil { t.Fatal(err) }
+    if u, err := s.FeedUser(first); err != nil || u.ID != stu.ID { t.Fatalf("FeedUser = %v, %v", u, err) }
+    second, _ := s.NewFeedToken(stu.ID)
+    if _, err := s.FeedUser(first); err == nil { t.Error("rotated token still works") }
+    if _, err := s.FeedUser(second); err != nil { t.Errorf("new token: %v", err) }
+    if err := s.RevokeFeedToken(stu.ID); err != nil { t.Fatal(err) }
+    if _, err := s.FeedUser(second); err == nil { t.Error("revoked token still works") }
+    if _, err := s.FeedUser(""); err == nil { t.Error("empty token accepted") }
+}
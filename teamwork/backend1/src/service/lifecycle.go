@@ -0,0 +1,326 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "log"
+    "strings"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/mailer"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+var (
+    staffRoles    = []domain.Role{domain.RoleTeacher, domain.RoleAdmin}
+    applicantRole = []domain.Role{domain.RoleStudent, domain.RoleAdmin}
+    systemRole    = []domain.Role{domain.RoleSystem}
+)
+
+// transitions lists, per current status, the statuses an application may move to and
+// which roles may move it there. Whether a teacher supervises the project is checked
+// by the policy before a transition is attempted.
+var transitions = map[string]map[string][]domain.Role{
+    domain.StatusSubmitted: {
+        domain.StatusInterview: staffRoles, domain.StatusWaitlisted: staffRoles, domain.StatusApproved: staffRoles,
+        domain.StatusRejected: staffRoles, domain.StatusWithdrawn: applicantRole, domain.StatusExpired: systemRole,
+    },
+    domain.StatusInterview: {
+        domain.StatusWaitlisted: staffRoles, domain.StatusApproved: staffRoles, domain.StatusRejected: staffRoles,
+        domain.StatusWithdrawn: applicantRole, domain.StatusExpired: systemRole,
+    },
+    domain.StatusWaitlisted: {
+        domain.StatusInterview: staffRoles, domain.StatusApproved: staffRoles, domain.StatusRejected: staffRoles,
+        domain.StatusWithdrawn: applicantRole, domain.StatusExpired: systemRole,
+    },
+    domain.StatusApproved: {
+        domain.StatusCompleted: staffRoles, domain.StatusWithdrawn: applicantRole,
+    },
+    domain.StatusRejected: {
+        domain.StatusSubmitted: {domain.RoleAdmin}, // reopen a mistaken rejection
+    },
+}
+
+// AllowedTransitions returns the statuses role may move an application in status from to.
+func AllowedTransitions(from string, role domain.Role) []string {
+    var out []string
+    for _, to := range domain.ApplicationStatuses {
+        for _, r := range transitions[from][to] { if r == role { out = append(out, to); break } }
+    }
+    return out
+}
+
+func roleMayMove(from, to string, role domain.Role) bool {
+    for _, r := range transitions[from][to] { if r == role { return true } }
+    return false
+}
+
+// ReapplyRules decide when a withdrawn or rejected application may be submitted again.
+// A resubmission reuses the application row, so its history shows every attempt.
+type ReapplyRules struct {
+    AfterWithdrawn bool
+    AfterRejected  bool
+    Cooldown       time.Duration
+    MaxSubmissions int // 0 = unlimited
+}
+
+// LifecycleService moves applications through their statuses and keeps the history.
+type LifecycleService struct {
+    svc     *Service
+    history repository.LifecycleRepo
+    rounds  repository.RoundRepo
+    teams   repository.TeamRepo
+    mailer  mailer.Mailer
+    reapply ReapplyRules
+}
+
+func NewLifecycleService(s *Service, history repository.LifecycleRepo, rounds repository.RoundRepo, teams repository.TeamRepo, m mailer.Mailer, reapply ReapplyRules) *LifecycleService {
+    return &LifecycleService{svc: s, history: history, rounds: rounds, teams: teams, mailer: m, reapply: reapply}
+}
+
+// admit returns the open round a new submission belongs to, enforcing the per-student
+// limit for the submitter and every team member. Without any rounds configured
+// applications are accepted at any time and the round is nil. A fresh application is
+// counted again when it is inserted, see roundLimit.
+func (l *LifecycleService) admit(a *domain.Application) (*domain.Round, error) {
+    rd, err := l.round()
+    if err != nil || rd == nil { return nil, err }
+    if rd.MaxApplications > 0 {
+        for _, id := range roundStudents(a) {
+            if l.rounds.CountRoundApplications(id, rd.ID) >= rd.MaxApplications { return nil, roundLimitError(rd) }
+        }
+    }
+    return rd, nil
+}
+
+// round returns the round open now, or nil when no rounds are configured.
+func (l *LifecycleService) round() (*domain.Round, error) {
+    all := l.rounds.ListRounds()
+    if len(all) == 0 { return nil, nil }
+    rd := openRound(all, time.Now())
+    if rd == nil { return nil, errors.New("当前不在申请轮次开放时间内") }
+    return rd, nil
+}
+
+func roundLimitError(rd *domain.Round) error { return fmt.Errorf("本轮最多可申请%d个项目", rd.MaxApplications) }
+
+// roundStudents are the students an application counts against: the submitter and the
+// team members.
+func roundStudents(a *domain.Application) []int64 {
+    ids := []int64{a.StudentID}
+    for _, id := range a.Members { if id != a.StudentID { ids = append(ids, id) } }
+    return ids
+}
+
+// roundLimit is the limit the insert of a checks under a lock on the round.
+func roundLimit(rd *domain.Round, a *domain.Application) *repository.RoundLimit {
+    if rd == nil { return nil }
+    return &repository.RoundLimit{RoundID: rd.ID, Max: rd.MaxApplications, Students: roundStudents(a)}
+}
+
+// Submit creates the application, or resubmits an earlier one when ReapplyRules allow,
+// and records the history entry.
+func (l *LifecycleService) Submit(actor *domain.User, a *domain.Application) (*domain.Application, error) {
+    a.Members, a.Seats = nil, 0
+    if a.TeamID != 0 {
+        if err := l.teamSubmission(a); err != nil { return nil, err }
+    } else if other := l.applying(a.ProjectID, []int64{a.StudentID}, 0); other != nil && other.TeamID != 0 {
+        return nil, errors.New("已通过团队申请该项目")
+    }
+    if prev := l.existing(a.StudentID, a.ProjectID); prev != nil {
+        if prev.TeamID != a.TeamID { return nil, errors.New("已提交过该项目申请") }
+        return l.resubmit(actor, prev, a)
+    }
+    rd, err := l.admit(a)
+    if err != nil { return nil, err }
+    if rd != nil { a.RoundID = rd.ID }
+    if err := l.svc.checkApply(a); err != nil { return nil, err }
+    created, err := l.history.CreateApplication(a, &domain.ApplicationStatusChange{To: a.Status, ActorID: actor.ID, ActorRole: actor.Role}, roundLimit(rd, a))
+    if errors.Is(err, repository.ErrRoundLimit) { return nil, roundLimitError(rd) }
+    if err != nil { return nil, err }
+    l.waitlistIfFull(created)
+    return created, nil
+}
+
+// teamSubmission checks that the leader submits for a complete team and snapshots its
+// active members into the application.
+func (l *LifecycleService) teamSubmission(a *domain.Application) error {
+    team := l.teams.GetTeam(a.TeamID)
+    if team == nil { return errors.New("团队不存在") }
+    if team.LeaderID != a.StudentID { return errors.New("只有队长可以提交团队申请") }
+    p := l.svc.repo.GetProject(a.ProjectID)
+    if p == nil { return errors.New("项目不存在") }
+    var ids []int64
+    for _, m := range l.teams.ListMembers(team.ID) {
+        if m.Status != domain.TeamMemberActive { continue }
+        u := l.svc.repo.GetUser(m.UserID)
+        if u == nil { continue }
+        if !u.EmailVerified { return fmt.Errorf("成员%s尚未验证邮箱", u.Name) }
+        ids = append(ids, u.ID)
+    }
+    if other := l.applying(a.ProjectID, ids, team.ID); other != nil { return errors.New("有成员已单独或随其他团队申请该项目") }
+    a.Members, a.Seats = ids, 1
+    if p.CountTeamMembers { a.Seats = len(ids) }
+    return nil
+}
+
+// applying returns an open application to the project that involves any of the users,
+// ignoring those of team exceptTeam.
+func (l *LifecycleService) applying(projectID int64, userIDs []int64, exceptTeam int64) *domain.Application {
+    for _, a := range l.svc.repo.ListApplications() {
+        if a.ProjectID != projectID || !openStatuses[a.Status] || (exceptTeam != 0 && a.TeamID == exceptTeam) { continue }
+        for _, id := range userIDs {
+            if a.StudentID == id || containsID(a.Members, id) { return a }
+        }
+    }
+    return nil
+}
+
+// placedError names the student of a repository.PlacedError; the repository checks every
+// approval against the students' other projects inside its transaction.
+func (l *LifecycleService) placedError(err error) error {
+    var pe *repository.PlacedError
+    if !errors.As(err, &pe) { return err }
+    if u := l.svc.repo.GetUser(pe.StudentID); u != nil { return fmt.Errorf("%s已通过其他申请被录取", u.Name) }
+    return err
+}
+
+func appSeats(a *domain.Application) int {
+    if a.Seats > 0 { return a.Seats }
+    return 1
+}
+
+// system records an automatic status change.
+func (l *LifecycleService) system(app *domain.Application, to, reason string) (bool, error) {
+    change := &domain.ApplicationStatusChange{ApplicationID: app.ID, From: app.Status, To: to, ActorRole: domain.RoleSystem, Reason: reason}
+    ok, err := l.history.TransitionApplication(app.ID, app.Status, to, change)
+    if ok { app.Status = to }
+    return ok, err
+}
+
+// waitlistIfFull moves a fresh submission to the waitlist when its project is full.
+func (l *LifecycleService) waitlistIfFull(app *domain.Application) {
+    p := l.svc.repo.GetProject(app.ProjectID)
+    if p == nil || !p.AutoWaitlist || p.Capacity <= 0 || l.history.CountTaken(p.ID)+appSeats(app) <= p.Capacity { return }
+    if _, err := l.system(app, domain.StatusWaitlisted, "名额已满，自动进入候补"); err != nil { log.Printf("waitlist application %d: %v", app.ID, err) }
+}
+
+// promote approves the longest-waiting applicant after a seat frees up.
+func (l *LifecycleService) promote(projectID int64) {
+    p := l.svc.repo.GetProject(projectID)
+    if p == nil || !p.AutoWaitlist || p.Capacity <= 0 { return }
+    // retry a few times in case a teacher decides on the same applicant concurrently
+    for i := 0; i < 3; i++ {
+        next := l.history.NextWaitlisted(projectID)
+        if next == nil { return }
+        ok, err := l.system(next, domain.StatusApproved, "候补递补")
+        var placed *repository.PlacedError
+        if errors.As(err, &placed) {
+            // the applicant got a seat elsewhere meanwhile; give the place to the next one
+            if _, err := l.system(next, domain.StatusRejected, "已通过其他申请被录取"); err != nil { log.Printf("reject placed application %d: %v", next.ID, err); return }
+            continue
+        }
+        if err != nil {
+            if !errors.Is(err, repository.ErrCapacityFull) { log.Printf("promote application %d: %v", next.ID, err) }
+            return
+        }
+        if !ok { continue }
+        if stu := l.svc.repo.GetUser(next.StudentID); stu != nil {
+            sendAsync(l.mailer, stu.Email, "候补申请已通过："+p.Title, fmt.Sprintf("%s，您好：\n\n项目《%s》有名额空出，您的候补申请已自动通过。\n", stu.Name, p.Title))
+        }
+        return
+    }
+}
+
+// Transition changes the status of appID to to on behalf of actor.
+func (l *LifecycleService) Transition(actor *domain.User, appID int64, to, reason string) (*domain.Application, error) {
+    to = strings.TrimSpace(to)
+    if !domain.ValidApplicationStatus(to) { return nil, fmt.Errorf("无效状态: %s", to) }
+    app := l.svc.repo.GetApplication(appID)
+    if app == nil { return nil, errors.New("申请不存在") }
+    if app.Status == to { return app, nil }
+    if !roleMayMove(app.Status, to, actor.Role) { return nil, fmt.Errorf("不能将申请从%s改为%s", app.Status, to) }
+    change := &domain.ApplicationStatusChange{ApplicationID: app.ID, From: app.Status, To: to, ActorID: actor.ID, ActorRole: actor.Role, Reason: strings.TrimSpace(reason)}
+    ok, err := l.history.TransitionApplication(app.ID, app.Status, to, change)
+    if err != nil { return nil, l.placedError(err) }
+    if !ok { return nil, errors.New("申请状态已被他人修改，请刷新后重试") }
+    app.Status = to
+    return app, nil
+}
+
+func (l *LifecycleService) existing(studentID, projectID int64) *domain.Application {
+    for _, a := range l.svc.repo.ListApplicationsByStudent(studentID, "") { if a.ProjectID == projectID { return a } }
+    return nil
+}
+
+// resubmit reopens app with the answers of the new submission sub.
+func (l *LifecycleService) resubmit(actor *domain.User, app, sub *domain.Application) (*domain.Application, error) {
+    switch {
+    case app.Status == domain.StatusWithdrawn && l.reapply.AfterWithdrawn:
+    case app.Status == domain.StatusRejected && l.reapply.AfterRejected:
+    default:
+        return nil, errors.New("已提交过该项目申请")
+    }
+    // the project and its form may have changed since the first attempt
+    sub.StudentID, sub.ProjectID = app.StudentID, app.ProjectID
+    if err := l.svc.checkSubmission(sub); err != nil { return nil, err }
+    hist := l.history.ListStatusChanges(app.ID)
+    submissions := 0
+    for _, h := range hist { if h.To == domain.StatusSubmitted { submissions++ } }
+    if l.reapply.MaxSubmissions > 0 && submissions >= l.reapply.MaxSubmissions { return nil, errors.New("该项目的申请次数已达上限") }
+    if len(hist) > 0 && l.reapply.Cooldown > 0 {
+        if wait := time.Until(hist[len(hist)-1].CreatedAt.Add(l.reapply.Cooldown)); wait > 0 {
+            return nil, fmt.Errorf("请在%d小时后再重新申请", int(wait.Hours())+1)
+        }
+    }
+    // the round limit is only checked inside the update, where the application itself
+    // is not counted again
+    rd, err := l.round()
+    if err != nil { return nil, err }
+    next := *app
+    next.CoverLetter, next.Answers, next.RoundID = sub.CoverLetter, sub.Answers, 0
+    if app.TeamID != 0 { next.Members, next.Seats = sub.Members, sub.Seats }
+    if rd != nil { next.RoundID = rd.ID }
+    limit := roundLimit(rd, &next)
+    if limit != nil { limit.Except = app.ID }
+    change := &domain.ApplicationStatusChange{ApplicationID: app.ID, From: app.Status, To: domain.StatusSubmitted, ActorID: actor.ID, ActorRole: actor.Role, Reason: "重新申请"}
+    ok, err := l.history.ResubmitApplication(&next, app.Status, change, limit)
+    if errors.Is(err, repository.ErrRoundLimit) { return nil, roundLimitError(rd) }
+    if err != nil { return nil, err }
+    if !ok { return nil, errors.New("申请状态已被他人修改，请刷新后重试") }
+    next.Status = domain.StatusSubmitted
+    l.waitlistIfFull(&next)
+    return &next, nil
+}
+
+// Withdraw lets the applicant, or an admin for them, take the application back and tells
+// the project owner who did it. The applicant hears about it when an admin withdrew.
+func (l *LifecycleService) Withdraw(actor *domain.User, appID int64, reason string) (*domain.Application, error) {
+    prev := l.svc.repo.GetApplication(appID)
+    if prev == nil { return nil, errors.New("申请不存在") }
+    if prev.TeamID != 0 && actor.Role == domain.RoleStudent && actor.ID != prev.StudentID { return nil, errors.New("只有队长可以撤回团队申请") }
+    wasApproved := prev.Status == domain.StatusApproved
+    app, err := l.Transition(actor, appID, domain.StatusWithdrawn, reason)
+    if err != nil { return nil, err }
+    if wasApproved { l.promote(app.ProjectID) }
+    proj := l.svc.repo.GetProject(app.ProjectID)
+    stu := l.svc.repo.GetUser(app.StudentID)
+    if proj == nil || stu == nil { return app, nil }
+    what := fmt.Sprintf("%s 撤回了对项目《%s》的申请。\n", stu.Name, proj.Title)
+    if actor.ID != stu.ID {
+        what = fmt.Sprintf("管理员 %s 撤回了 %s 对项目《%s》的申请。\n", actor.Name, stu.Name, proj.Title)
+    }
+    if r := strings.TrimSpace(reason); r != "" { what += "撤回原因：" + r + "\n" }
+    if t := l.svc.repo.GetUser(proj.TeacherID); t != nil {
+        sendAsync(l.mailer, t.Email, "申请已撤回："+proj.Title, fmt.Sprintf("%s，您好：\n\n%s", t.Name, what))
+    }
+    if actor.ID != stu.ID {
+        sendAsync(l.mailer, stu.Email, "申请已撤回："+proj.Title, fmt.Sprintf("%s，您好：\n\n%s", stu.Name, what))
+    }
+    return app, nil
+}
+
+func (l *LifecycleService) History(appID int64) []*domain.ApplicationStatusChange {
+    return l.history.ListStatusChanges(appID)
+}
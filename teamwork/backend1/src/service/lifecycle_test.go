@@ -0,0 +1,156 @@
+package service
+
+import (
+    "errors"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+// memLifecycle keeps applications in a memRepo and mirrors the gorm repository's
+// status-guarded transitions and capacity check.
+type memLifecycle struct {
+    repository.LifecycleRepo
+    repo       *memRepo
+    history    []*domain.ApplicationStatusChange
+    failCreate bool
+    // beforeCreate runs as CreateApplication starts, e.g. to slip in a concurrent insert
+    beforeCreate func()
+}
+
+func (m *memLifecycle) CreateApplication(a *domain.Application, change *domain.ApplicationStatusChange, limit *repository.RoundLimit) (*domain.Application, error) {
+    if m.failCreate { return nil, errors.New("db down") }
+    if m.beforeCreate != nil { m.beforeCreate() }
+    m.repo.mu.Lock(); defer m.repo.mu.Unlock()
+    for _, o := range m.repo.apps { if o.StudentID == a.StudentID && o.ProjectID == a.ProjectID { return nil, repository.ErrDuplicateApplication } }
+    if m.overLimit(limit) { return nil, repository.ErrRoundLimit }
+    a.ID = m.repo.id()
+    m.repo.apps[a.ID] = a
+    change.ApplicationID = a.ID
+    m.history = append(m.history, change)
+    return a, nil
+}
+
+func (m *memLifecycle) TransitionApplication(appID int64, from, to string, change *domain.ApplicationStatusChange) (bool, error) {
+    m.repo.mu.Lock(); defer m.repo.mu.Unlock()
+    a := m.repo.apps[appID]
+    if a == nil || a.Status != from { return false, nil }
+    if p := m.repo.projects[a.ProjectID]; to == domain.StatusApproved && p != nil && p.Capacity > 0 && m.taken(p.ID)+appSeats(a) > p.Capacity {
+        return false, repository.ErrCapacityFull
+    }
+    if to == domain.StatusApproved {
+        if id := m.placed(a); id != 0 { return false, &repository.PlacedError{StudentID: id} }
+    }
+    a.Status = to
+    m.history = append(m.history, change)
+    return true, nil
+}
+
+func (m *memLifecycle) ListStatusChanges(appID int64) []*domain.ApplicationStatusChange {
+    var out []*domain.ApplicationStatusChange
+    for _, c := range m.history { if c.ApplicationID == appID { out = append(out, c) } }
+    return out
+}
+
+// newLifecycleTest returns a lifecycle service with a verified student and a project.
+func newLifecycleTest(t *testing.T) (*LifecycleService, *memLifecycle, *domain.User, *domain.Project) {
+    repo := newMemRepo()
+    stu, _ := repo.AddUser(&domain.User{Name: "stu", Email: "stu@example.edu", Role: domain.RoleStudent, EmailVerified: true})
+    teacher, _ := repo.AddUser(&domain.User{Name: "tea", Email: "tea@example.edu", Role: domain.RoleTeacher, EmailVerified: true})
+    p, _ := repo.AddProject(&domain.Project{Title: "p", TeacherID: teacher.ID})
+    hist := &memLifecycle{repo: repo}
+    return NewLifecycleService(&Service{repo: repo}, hist, &memRounds{repo: repo}, nil, nil, ReapplyRules{}), hist, stu, p
+}
+
+func TestSubmitRecordsHistory(t *testing.T) {
+    l, hist, stu, p := newLifecycleTest(t)
+    app, err := l.Submit(stu, &domain.Application{StudentID: stu.ID, ProjectID: p.ID})
+    if err != nil { t.Fatal(err) }
+    h := l.History(app.ID)
+    if len(h) != 1 || h[0].To != domain.StatusSubmitted || h[0].ActorID != stu.ID { t.Fatalf("history %+v", h) }
+    if len(hist.history) != 1 { t.Errorf("%d history rows, want 1", len(hist.history)) }
+}
+
+func TestSubmitFailsWithoutHistory(t *testing.T) {
+    l, hist, stu, p := newLifecycleTest(t)
+    hist.failCreate = true
+    if _, err := l.Submit(stu, &domain.Application{StudentID: stu.ID, ProjectID: p.ID}); err == nil { t.Fatal("submission reported success without being stored") }
+    if n := len(hist.repo.apps); n != 0 { t.Errorf("%d applications stored", n) }
+}
+
+func TestConcurrentDuplicateSubmissionRefused(t *testing.T) {
+    l, hist, stu, p := newLifecycleTest(t)
+    // another request stores the same application between the check and the insert
+    hist.beforeCreate = func() {
+        hist.repo.mu.Lock(); defer hist.repo.mu.Unlock()
+        id := hist.repo.id()
+        hist.repo.apps[id] = &domain.Application{ID: id, StudentID: stu.ID, ProjectID: p.ID, Status: domain.StatusSubmitted}
+    }
+    if _, err := l.Submit(stu, &domain.Application{StudentID: stu.ID, ProjectID: p.ID}); !errors.Is(err, repository.ErrDuplicateApplication) { t.Fatalf("err = %v, want ErrDuplicateApplication", err) }
+    if len(hist.history) != 0 { t.Errorf("%d history rows written for the refused application", len(hist.history)) }
+}
+
+func TestResubmitChecksProject(t *testing.T) {
+    l, _, stu, p := newLifecycleTest(t)
+    l.reapply = ReapplyRules{AfterWithdrawn: true}
+    app, err := l.Submit(stu, &domain.Application{StudentID: stu.ID, ProjectID: p.ID})
+    if err != nil { t.Fatal(err) }
+    if _, err := l.Withdraw(stu, app.ID, ""); err != nil { t.Fatal(err) }
+    p.Archived = true
+    if _, err := l.Submit(stu, &domain.Application{StudentID: stu.ID, ProjectID: p.ID}); err == nil { t.Fatal("resubmitted to an archived project") }
+    p.Archived = false
+    if _, err := l.Submit(stu, &domain.Application{StudentID: stu.ID, ProjectID: p.ID}); err != nil { t.Fatalf("resubmission: %v", err) }
+}
+
+func TestResubmitChecksRoundLimitInsideTheUpdate(t *testing.T) {
+    l, hist, stu, p := newLifecycleTest(t)
+    l.reapply = ReapplyRules{AfterWithdrawn: true, AfterRejected: true}
+    rounds := l.rounds.(*memRounds)
+    rounds.rounds = []*domain.Round{{ID: 1, Name: "r", OpensAt: time.Now().Add(-time.Hour), ClosesAt: time.Now().Add(time.Hour), MaxApplications: 1}}
+    app, err := l.Submit(stu, &domain.Application{StudentID: stu.ID, ProjectID: p.ID, CoverLetter: "第一次"})
+    if err != nil { t.Fatal(err) }
+
+    // a rejected application in the round does not count against its own resubmission
+    hist.repo.apps[app.ID].Status = domain.StatusRejected
+    if _, err := l.Submit(stu, &domain.Application{StudentID: stu.ID, ProjectID: p.ID, CoverLetter: "第二次"}); err != nil { t.Fatalf("resubmission: %v", err) }
+    if got := hist.repo.apps[app.ID]; got.Status != domain.StatusSubmitted || got.CoverLetter != "第二次" { t.Errorf("after resubmission %s %q", got.Status, got.CoverLetter) }
+
+    // another application filled the round meanwhile; the stale count does not let it through
+    other, _ := hist.repo.AddProject(&domain.Project{Title: "q", TeacherID: p.TeacherID})
+    hist.repo.apps[99] = &domain.Application{ID: 99, StudentID: stu.ID, ProjectID: other.ID, RoundID: 1, Status: domain.StatusSubmitted}
+    hist.repo.apps[app.ID].Status = domain.StatusWithdrawn
+    rounds.staleCount = true
+    if _, err := l.Submit(stu, &domain.Application{StudentID: stu.ID, ProjectID: p.ID, CoverLetter: "第三次"}); err == nil { t.Fatal("resubmission passed the round limit") }
+    if got := hist.repo.apps[app.ID]; got.Status != domain.StatusWithdrawn || got.CoverLetter != "第二次" { t.Errorf("refused resubmission left %s %q", got.Status, got.CoverLetter) }
+}
+
+func TestAdminWithdrawalSaysWhoWithdrew(t *testing.T) {
+    l, hist, stu, p := newLifecycleTest(t)
+    mails := make(chanMailer, 2)
+    l.mailer = mails
+    admin, _ := hist.repo.AddUser(&domain.User{Name: "adm", Email: "adm@example.edu", Role: domain.RoleAdmin})
+    app, err := l.Submit(stu, &domain.Application{StudentID: stu.ID, ProjectID: p.ID})
+    if err != nil { t.Fatal(err) }
+    if _, err := l.Withdraw(admin, app.ID, "重复申请"); err != nil { t.Fatal(err) }
+    got := map[string]string{}
+    for i := 0; i < 2; i++ { m := mails.next(t); got[m.To] = m.Body }
+    for _, to := range []string{"tea@example.edu", stu.Email} {
+        if !strings.Contains(got[to], "管理员 adm 撤回了 stu") { t.Errorf("mail to %s: %q", to, got[to]) }
+    }
+}
+
+func TestPromotePassesOverPlacedApplicant(t *testing.T) {
+    l, hist, stu, p := newLifecycleTest(t)
+    p.Capacity, p.AutoWaitlist = 1, true
+    other, _ := hist.repo.AddProject(&domain.Project{Title: "q", TeacherID: p.TeacherID})
+    next, _ := hist.repo.AddUser(&domain.User{Name: "next", Role: domain.RoleStudent})
+    hist.repo.apps[50] = &domain.Application{ID: 50, StudentID: 99, ProjectID: other.ID, TeamID: 7, Members: []int64{99, stu.ID}, Status: domain.StatusApproved}
+    hist.repo.apps[51] = &domain.Application{ID: 51, StudentID: stu.ID, ProjectID: p.ID, Status: domain.StatusWaitlisted}
+    hist.repo.apps[52] = &domain.Application{ID: 52, StudentID: next.ID, ProjectID: p.ID, Status: domain.StatusWaitlisted}
+    l.promote(p.ID)
+    if got := hist.repo.apps[51].Status; got != domain.StatusRejected { t.Errorf("placed applicant is %s, want rejected", got) }
+    if got := hist.repo.apps[52].Status; got != domain.StatusApproved { t.Errorf("next applicant is %s, want approved", got) }
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+const (
+    defaultLLMBaseURL = "https://api.deepseek.com"
+    defaultLLMModel   = "deepseek-chat"
+    // llmAttempts is how often a reply that fails validation is asked for again before
+    // the fallback chain takes over.
+    llmAttempts = 2
+)
+
+// LLMMatcher asks an OpenAI compatible chat model to rate the projects. The model must
+// answer with JSON that names every project and every requirement exactly once; any
+// other answer is an error, so that the registry falls back to an offline matcher.
+type LLMMatcher struct {
+    BaseURL string
+    APIKey  string
+    Model   string
+    Client  *http.Client
+    tax     *Taxonomy
+}
+
+func NewLLMMatcher(baseURL, apiKey, model string) *LLMMatcher {
+    if baseURL == "" { baseURL = defaultLLMBaseURL }
+    if model == "" { model = defaultLLMModel }
+    return &LLMMatcher{BaseURL: strings.TrimRight(baseURL, "/"), APIKey: apiKey, Model: model, Client: &http.Client{Timeout: 20 * time.Second}}
+}
+
+// WeighsRequirements is true: the model sees the requirement details and the reply is
+// penalized for unmet mandatory requirements in results.
+func (m *LLMMatcher) WeighsRequirements() bool { return true }
+
+// UseTaxonomy sets the taxonomy the requirements in a reply are resolved with.
+func (m *LLMMatcher) UseTaxonomy(t *Taxonomy) { m.tax = t }
+
+func (m *LLMMatcher) version() string { return "v1@" + m.Model }
+
+func (m *LLMMatcher) Healthy() error {
+    if m.APIKey == "" { return errors.New("未配置大模型API密钥") }
+    return nil
+}
+
+func (m *LLMMatcher) Match(student *domain.User, projects []*domain.Project) []domain.MatchResult {
+    res, _ := m.TryMatch(student, projects)
+    return res
+}
+
+type llmProject struct {
+    ID           int64                `json:"id"`
+    Title        string               `json:"title"`
+    Description  string               `json:"description"`
+    Tags         []string             `json:"tags,omitempty"`
+    Requirements []domain.Requirement `json:"requirements"`
+}
+
+type llmInput struct {
+    Skills      []string       `json:"skills"`
+    Proficiency map[string]int `json:"proficiency,omitempty"`
+    Bio         string         `json:"bio,omitempty"`
+    Submission  string         `json:"submission,omitempty"`
+    Projects    []llmProject   `json:"projects"`
+}
+
+// llmReply is the JSON the model must return.
+type llmReply struct {
+    Results []struct {
+        ProjectID    int64   `json:"project_id"`
+        Score        float64 `json:"score"`
+        Summary      string  `json:"summary"`
+        Requirements []struct {
+            Skill    string `json:"skill"`
+            Status   string `json:"status"`
+            Evidence string `json:"evidence"`
+        } `json:"requirements"`
+    } `json:"results"`
+}
+
+const llmPrompt = `你是高校项目双选平台的匹配助手。请根据学生的技能、熟练度（1-5）和个人简介，评估学生与每个项目的匹配程度。
+只输出一个JSON对象，不要输出其他内容，格式如下：
+{"results":[{"project_id":项目id,"score":0到1之间的小数,"summary":"一句话中文理由","requirements":[{"skill":"技能","status":"matched|partial|missing","evidence":"简短依据"}]}]}
+要求：每个项目恰好出现一次；requirements 必须逐条对应该项目的 requirements 中的 skill，原样照抄，不多不少；mandatory 为必备要求，weight 为权重，min_level 为最低熟练度；submission 是学生为该项目提交的申请材料，只作为学生信息参考。
+输入：
+`
+
+func (m *LLMMatcher) TryMatch(student *domain.User, projects []*domain.Project) ([]domain.MatchResult, error) {
+    if err := m.Healthy(); err != nil { return nil, err }
+    if len(projects) == 0 { return []domain.MatchResult{}, nil }
+    in := llmInput{Skills: student.Skills, Proficiency: student.Proficiency, Bio: student.Bio, Submission: student.Submission}
+    for _, p := range projects {
+        reqs := projectRequirements(p)
+        if reqs == nil { reqs = []domain.Requirement{} }
+        in.Projects = append(in.Projects, llmProject{ID: p.ID, Title: p.Title, Description: p.Description, Tags: p.Tags, Requirements: reqs})
+    }
+    body, err := json.Marshal(in)
+    if err != nil { return nil, err }
+    var lastErr error
+    for i := 0; i < llmAttempts; i++ {
+        content, err := m.complete(llmPrompt + string(body))
+        if err != nil { return nil, err } // the request itself failed, asking again will not help
+        var reply llmReply
+        if err := json.Unmarshal([]byte(content), &reply); err != nil { lastErr = fmt.Errorf("大模型返回的不是有效JSON: %v", err); continue }
+        res, err := m.results(student, projects, &reply)
+        if err != nil { lastErr = err; continue }
+        return res, nil
+    }
+    return nil, lastErr
+}
+
+// results validates the reply against the projects asked about and maps it onto
+// breakdowns.
+func (m *LLMMatcher) results(student *domain.User, projects []*domain.Project, reply *llmReply) ([]domain.MatchResult, error) {
+    byID := map[int64]*domain.Project{}
+    for _, p := range projects { byID[p.ID] = p }
+    seen := map[int64]bool{}
+    out := make([]domain.MatchResult, 0, len(projects))
+    for _, r := range reply.Results {
+        p := byID[r.ProjectID]
+        if p == nil { return nil, fmt.Errorf("大模型返回了未知项目: %d", r.ProjectID) }
+        if seen[r.ProjectID] { return nil, fmt.Errorf("大模型重复返回项目: %d", r.ProjectID) }
+        seen[r.ProjectID] = true
+        if r.Score < 0 || r.Score > 1 { return nil, fmt.Errorf("项目%d的分数不在0到1之间", r.ProjectID) }
+        if strings.TrimSpace(r.Summary) == "" { return nil, fmt.Errorf("项目%d缺少匹配理由", r.ProjectID) }
+        reqs := projectRequirements(p)
+        if len(r.Requirements) != len(reqs) { return nil, fmt.Errorf("项目%d的技能要求条数不符", r.ProjectID) }
+        // the model may answer with the stored name or, once an alias changed, the canonical one
+        local := map[string]reqCheck{}
+        for _, c := range evaluate(m.tax, student, reqs).Checks { local[skillKey(c.Skill)] = c; local[m.tax.Canonical(c.Skill)] = c }
+        answered := map[string]bool{}
+        b := &domain.MatchBreakdown{Version: m.version(), Requirements: []domain.RequirementMatch{}}
+        var unmet []string
+        for _, rr := range r.Requirements {
+            c, ok := local[skillKey(rr.Skill)]
+            if !ok { c, ok = local[m.tax.Canonical(rr.Skill)] }
+            if !ok { return nil, fmt.Errorf("项目%d没有技能要求: %s", r.ProjectID, rr.Skill) }
+            if answered[c.Skill] { return nil, fmt.Errorf("项目%d重复返回技能要求: %s", r.ProjectID, rr.Skill) }
+            answered[c.Skill] = true
+            credit := 0.0
+            switch rr.Status {
+            case domain.RequirementMatched: credit = 1
+            case domain.RequirementPartial: credit = 0.5
+            case domain.RequirementMissing:
+            default: return nil, fmt.Errorf("项目%d的技能要求状态无效: %s", r.ProjectID, rr.Status)
+            }
+            if c.Mandatory && rr.Status != domain.RequirementMatched { unmet = append(unmet, c.Skill) }
+            b.Requirements = append(b.Requirements, domain.RequirementMatch{Skill: c.Skill, Mandatory: c.Mandatory, Weight: weightOf(c.Requirement), MinLevel: c.MinLevel, Status: rr.Status, Credit: credit, By: c.By, Level: c.Level, Unrated: c.Unrated, Evidence: strings.TrimSpace(rr.Evidence)})
+        }
+        b.Components = []domain.ScoreComponent{component("llm", 1, r.Score)}
+        score, reason := reqEval{Unmet: unmet}.penalize(round2(r.Score), strings.TrimSpace(r.Summary), b)
+        out = append(out, domain.MatchResult{Project: p, Score: score, Reason: reason, Breakdown: b})
+    }
+    if len(out) != len(projects) { return nil, fmt.Errorf("大模型只返回了%d个项目中的%d个", len(projects), len(out)) }
+    sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+    return out, nil
+}
+
+// complete sends one chat completion in JSON mode and returns the message content.
+func (m *LLMMatcher) complete(prompt string) (string, error) {
+    body, err := json.Marshal(map[string]any{
+        "model":           m.Model,
+        "temperature":     0,
+        "response_format": map[string]string{"type": "json_object"},
+        "messages":        []map[string]string{{"role": "user", "content": prompt}},
+    })
+    if err != nil { return "", err }
+    req, err := http.NewRequest(http.MethodPost, m.BaseURL+"/chat/completions", bytes.NewReader(body))
+    if err != nil { return "", err }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("Authorization", "Bearer "+m.APIKey)
+    resp, err := m.Client.Do(req)
+    if err != nil { return "", err }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK { return "", fmt.Errorf("大模型接口返回 %d", resp.StatusCode) }
+    var cr struct {
+        Choices []struct {
+            Message struct{ Content string `json:"content"` } `json:"message"`
+        } `json:"choices"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&cr); err != nil { return "", err }
+    if len(cr.Choices) == 0 { return "", errors.New("大模型没有返回结果") }
+    return cr.Choices[0].Message.Content, nil
+}
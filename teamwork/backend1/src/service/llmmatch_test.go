@@ -0,0 +1,66 @@
+package service
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+// fakeLLM answers every chat completion with reply and keeps the last prompt.
+func fakeLLM(t *testing.T, reply string) (*LLMMatcher, *string) {
+    var prompt string
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var req struct {
+            Messages []struct{ Content string `json:"content"` } `json:"messages"`
+        }
+        _ = json.NewDecoder(r.Body).Decode(&req)
+        prompt = req.Messages[0].Content
+        _ = json.NewEncoder(w).Encode(map[string]any{"choices": []any{map[string]any{"message": map[string]string{"content": reply}}}})
+    }))
+    t.Cleanup(srv.Close)
+    return NewLLMMatcher(srv.URL, "key", ""), &prompt
+}
+
+func TestRegisteredLLMMatcherIsPenalizedOnce(t *testing.T) {
+    llm, prompt := fakeLLM(t, `{"results":[{"project_id":1,"score":0.8,"summary":"方向吻合","requirements":[
+        {"skill":"go","status":"matched","evidence":"简历"},{"skill":"rust","status":"partial","evidence":"课程"}]}]}`)
+    reg, err := NewMatcherRegistry(&Service{repo: newMemRepo()}, MatcherOptions{LLM: llm})
+    if err != nil { t.Fatal(err) }
+    m, err := reg.Get(MatcherLLM)
+    if err != nil { t.Fatal(err) }
+    p := &domain.Project{ID: 1, Title: "p", Description: "编译器", Requirements: []string{"go", "rust"},
+        RequirementDetails: []domain.Requirement{{Skill: "go"}, {Skill: "rust", Mandatory: true}}}
+
+    res := m.Match(&domain.User{Skills: []string{"go"}}, []*domain.Project{p})
+    if len(res) != 1 { t.Fatalf("results = %+v", res) }
+    r := res[0]
+    if r.Score != 0.4 { t.Errorf("score = %v, want 0.8 halved once", r.Score) }
+    if !strings.HasPrefix(r.Reason, "未满足必备要求：rust；方向吻合") { t.Errorf("reason = %q", r.Reason) }
+    b := r.Breakdown
+    if len(b.UnmetMandatory) != 1 || b.UnmetMandatory[0] != "rust" { t.Errorf("unmet = %v", b.UnmetMandatory) }
+    if len(b.Components) != 2 || b.Components[0].Name != "llm" || b.Components[0].Weight != 1 || b.Components[1].Name != "mandatory_penalty" { t.Errorf("components = %+v", b.Components) }
+    if strings.Contains(*prompt, "技能要求：") { t.Error("requirements were spelled out in the description as well") }
+}
+
+func TestLLMReplyMayUseStoredOrCanonicalNames(t *testing.T) {
+    // the project was saved before js became an alias of javascript
+    p := &domain.Project{ID: 1, Requirements: []string{"js", "go"}}
+    tax := NewTaxonomy([]*domain.Skill{{ID: 1, Name: "javascript", Aliases: []string{"js"}}})
+    for _, skill := range []string{"js", "JS", "javascript"} {
+        llm, _ := fakeLLM(t, `{"results":[{"project_id":1,"score":0.5,"summary":"一般","requirements":[
+            {"skill":"`+skill+`","status":"matched"},{"skill":"go","status":"missing"}]}]}`)
+        llm.UseTaxonomy(tax)
+        res, err := llm.TryMatch(&domain.User{Skills: []string{"javascript"}}, []*domain.Project{p})
+        if err != nil { t.Errorf("%s: %v", skill, err); continue }
+        if row := res[0].Breakdown.Requirements[0]; row.Skill != "js" || row.Status != domain.RequirementMatched { t.Errorf("%s: row = %+v", skill, row) }
+    }
+
+    llm, _ := fakeLLM(t, `{"results":[{"project_id":1,"score":0.5,"summary":"一般","requirements":[
+        {"skill":"js","status":"matched"},{"skill":"javascript","status":"matched"}]}]}`)
+    llm.UseTaxonomy(tax)
+    if _, err := llm.TryMatch(&domain.User{}, []*domain.Project{p}); err == nil { t.Error("a requirement answered twice was accepted") }
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+// failureWindow is how long a failed attempt counts; quieter keys start over.
+const failureWindow = time.Hour
+
+type lockPolicy struct {
+    threshold int           // failures before the first lockout
+    base      time.Duration // first lockout, doubled on each further failure
+    max       time.Duration
+}
+
+var (
+    accountPolicy = lockPolicy{threshold: 5, base: 30 * time.Second, max: 15 * time.Minute}
+    ipPolicy      = lockPolicy{threshold: 20, base: time.Minute, max: time.Hour}
+)
+
+func (p lockPolicy) lockFor(failures int) time.Duration {
+    if failures < p.threshold { return 0 }
+    d := p.base
+    for i := p.threshold; i < failures && d < p.max; i++ { d *= 2 }
+    if d > p.max { d = p.max }
+    return d
+}
+
+// LockedError tells the caller when the next login attempt is allowed.
+type LockedError struct{ Until time.Time }
+
+func (e *LockedError) Error() string {
+    return fmt.Sprintf("尝试次数过多，请在%d秒后重试", int(time.Until(e.Until).Seconds())+1)
+}
+
+// LockoutService wraps the configured Authenticator with per-account and per-IP failure counters.
+type LockoutService struct {
+    authn Authenticator
+    locks repository.LockoutRepo
+}
+
+func NewLockoutService(authn Authenticator, locks repository.LockoutRepo) *LockoutService {
+    return &LockoutService{authn: authn, locks: locks}
+}
+
+func accountKey(email string) string { return "account:" + strings.ToLower(strings.TrimSpace(email)) }
+func ipKey(ip string) string         { return "ip:" + ip }
+
+func (l *LockoutService) Login(email, password, ip string) (*domain.User, error) {
+    var u *domain.User
+    err := l.Guard(email, ip, func() (err error) {
+        u, err = l.authn.Authenticate(email, password)
+        return err
+    })
+    if err != nil { return nil, err }
+    return u, nil
+}
+
+// Guard runs a password check for email under the account and IP lockouts. Only
+// ErrBadCredentials counts as a failure.
+func (l *LockoutService) Guard(email, ip string, check func() error) error {
+    now := time.Now()
+    keys := []string{accountKey(email), ipKey(ip)}
+    for _, k := range keys {
+        if t := l.locks.GetLoginThrottle(k); t != nil && t.LockedUntil != nil && t.LockedUntil.After(now) {
+            return &LockedError{Until: *t.LockedUntil}
+        }
+    }
+    err := check()
+    if err == nil {
+        // only the account counter is cleared; one valid login must not reset an attacking IP
+        _ = l.locks.DeleteLoginThrottle(keys[0])
+        return nil
+    }
+    // a directory outage or an unlinked account says nothing about the password, so it
+    // must not lock anyone out
+    if !errors.Is(err, ErrBadCredentials) { return err }
+    for i, p := range []lockPolicy{accountPolicy, ipPolicy} {
+        t, rerr := l.locks.RecordLoginFailure(keys[i], now, now.Add(-failureWindow))
+        if rerr != nil { continue }
+        if d := p.lockFor(t.Failures); d > 0 { _ = l.locks.SetLoginLockedUntil(keys[i], now.Add(d)) }
+    }
+    return err
+}
+
+func (l *LockoutService) ListLocked() []*domain.LoginThrottle { return l.locks.ListLockedThrottles(time.Now()) }
+
+// Clear removes a lockout; key is either a full key ("ip:1.2.3.4") or an email.
+func (l *LockoutService) Clear(key string) error {
+    if !strings.HasPrefix(key, "account:") && !strings.HasPrefix(key, "ip:") { key = accountKey(key) }
+    return l.locks.DeleteLoginThrottle(key)
+}
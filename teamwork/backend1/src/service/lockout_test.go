@@ -0,0 +1,111 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+// memLockouts is an in-memory repository.LockoutRepo.
+type memLockouts struct {
+    mu   sync.Mutex
+    rows map[string]*domain.LoginThrottle
+}
+
+func newMemLockouts() *memLockouts { return &memLockouts{rows: map[string]*domain.LoginThrottle{}} }
+
+func (m *memLockouts) GetLoginThrottle(key string) *domain.LoginThrottle {
+    m.mu.Lock(); defer m.mu.Unlock()
+    if t := m.rows[key]; t != nil { cp := *t; return &cp }
+    return nil
+}
+
+func (m *memLockouts) RecordLoginFailure(key string, now, resetBefore time.Time) (*domain.LoginThrottle, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    t := m.rows[key]
+    if t == nil || t.LastFailure.Before(resetBefore) { t = &domain.LoginThrottle{Key: key}; m.rows[key] = t }
+    t.Failures++
+    t.LastFailure = now
+    cp := *t
+    return &cp, nil
+}
+
+func (m *memLockouts) SetLoginLockedUntil(key string, until time.Time) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    if t := m.rows[key]; t != nil { t.LockedUntil = &until }
+    return nil
+}
+
+func (m *memLockouts) ListLockedThrottles(now time.Time) []*domain.LoginThrottle {
+    m.mu.Lock(); defer m.mu.Unlock()
+    var out []*domain.LoginThrottle
+    for _, t := range m.rows {
+        if t.LockedUntil != nil && t.LockedUntil.After(now) { cp := *t; out = append(out, &cp) }
+    }
+    return out
+}
+
+func (m *memLockouts) DeleteLoginThrottle(key string) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    delete(m.rows, key)
+    return nil
+}
+
+// authFunc adapts a function to Authenticator.
+type authFunc func(email, password string) (*domain.User, error)
+
+func (f authFunc) Authenticate(email, password string) (*domain.User, error) { return f(email, password) }
+
+var okUser = &domain.User{ID: 1, Email: "ann@example.com"}
+
+func passwordIs(pw string) authFunc {
+    return func(email, password string) (*domain.User, error) {
+        if password != pw { return nil, ErrBadCredentials }
+        return okUser, nil
+    }
+}
+
+func isLocked(err error) bool {
+    var l *LockedError
+    return errors.As(err, &l)
+}
+
+func TestAccountLocksAfterThreshold(t *testing.T) {
+    ls := NewLockoutService(passwordIs("right"), newMemLockouts())
+    for i := 0; i < accountPolicy.threshold; i++ {
+        if _, err := ls.Login("ann@example.com", "wrong", "10.0.0.1"); isLocked(err) { t.Fatalf("locked after %d failures", i) }
+    }
+    // the correct password no longer helps until the lock expires
+    if _, err := ls.Login("ANN@example.com ", "right", "10.0.0.2"); !isLocked(err) { t.Fatalf("want lockout, got %v", err) }
+    if err := ls.Clear("ann@example.com"); err != nil { t.Fatal(err) }
+    if _, err := ls.Login("ann@example.com", "right", "10.0.0.2"); err != nil { t.Fatalf("login after clearing: %v", err) }
+}
+
+func TestIPLocksAcrossAccounts(t *testing.T) {
+    ls := NewLockoutService(passwordIs("right"), newMemLockouts())
+    for i := 0; i < ipPolicy.threshold; i++ {
+        _, _ = ls.Login(fmt.Sprintf("user%d@example.com", i), "wrong", "10.0.0.9")
+    }
+    if _, err := ls.Login("ann@example.com", "right", "10.0.0.9"); !isLocked(err) { t.Fatalf("want IP lockout, got %v", err) }
+    if _, err := ls.Login("ann@example.com", "right", "10.0.0.10"); err != nil { t.Fatalf("other address locked too: %v", err) }
+}
+
+func TestDirectoryOutageDoesNotLock(t *testing.T) {
+    down := authFunc(func(string, string) (*domain.User, error) { return nil, ErrAuthUnavailable })
+    locks := newMemLockouts()
+    ls := NewLockoutService(down, locks)
+    for i := 0; i < 2*accountPolicy.threshold; i++ { _, _ = ls.Login("ann@example.com", "x", "10.0.0.1") }
+    if len(locks.rows) != 0 { t.Errorf("outage recorded as failures: %v", locks.rows) }
+}
+
+func TestLockDoublesUpToMax(t *testing.T) {
+    p := accountPolicy
+    if d := p.lockFor(p.threshold - 1); d != 0 { t.Errorf("locked below threshold: %v", d) }
+    if d := p.lockFor(p.threshold); d != p.base { t.Errorf("first lock %v, want %v", d, p.base) }
+    if d := p.lockFor(p.threshold + 1); d != 2*p.base { t.Errorf("second lock %v, want %v", d, 2*p.base) }
+    if d := p.lockFor(p.threshold + 100); d != p.max { t.Errorf("lock %v exceeds max %v", d, p.max) }
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+    "fmt"
+    "os"
+    "sort"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+const (
+    MatcherLLM    = "llm"    // the matcher the service was built with
+    MatcherText   = "tfidf"  // BM25 over the project text, offline
+    MatcherSkills = "skills" // requirement coverage through the skill taxonomy
+    MatcherSimple = "simple" // keyword overlap, no external calls
+)
+
+// MatcherOptions pick the default matcher and the order in which matchers stand in for
+// one that fails.
+type MatcherOptions struct {
+    Default  string
+    Fallback []string       // e.g. llm, tfidf, simple
+    LLM      domain.Matcher // replaces the llm matcher the service was built with when set
+}
+
+// MatcherRegistry resolves matchers by name for the scoring endpoints.
+type MatcherRegistry struct {
+    matchers map[string]domain.Matcher
+    tax      *Taxonomy
+    def      string
+    fallback []string
+}
+
+type MatcherInfo struct {
+    Name     string   `json:"name"`
+    Default  bool     `json:"default"`
+    Healthy  bool     `json:"healthy"`
+    Error    string   `json:"error,omitempty"`
+    Fallback []string `json:"fallback"` // tried in order when this one fails
+}
+
+// NewMatcherRegistry registers the built-in matchers and checks opts against them.
+// SC_LLM_LIST_DISABLE=1 still makes the keyword matcher the default.
+func NewMatcherRegistry(s *Service, opts MatcherOptions) (*MatcherRegistry, error) {
+    r := &MatcherRegistry{matchers: map[string]domain.Matcher{}, tax: s.taxonomy, def: opts.Default}
+    r.Register(MatcherLLM, s.matcher)
+    if opts.LLM != nil { r.Register(MatcherLLM, opts.LLM) }
+    r.Register(MatcherText, NewTextMatcher(s.taxonomy, s.repo.ListProjects()))
+    r.Register(MatcherSkills, NewSkillMatcher(s.taxonomy))
+    r.Register(MatcherSimple, SimpleMatcher{})
+    if r.def == "" { r.def = MatcherLLM }
+    if os.Getenv("SC_LLM_LIST_DISABLE") == "1" { r.def = MatcherSimple }
+    if r.matchers[r.def] == nil { return nil, fmt.Errorf("未知匹配器: %s", r.def) }
+    for _, name := range opts.Fallback {
+        if r.matchers[name] == nil { return nil, fmt.Errorf("未知匹配器: %s", name) }
+        if !contains(r.fallback, name) { r.fallback = append(r.fallback, name) }
+    }
+    return r, nil
+}
+
+// TaxonomyUser is implemented by matchers built outside the registry that resolve
+// skill names themselves; Register hands them the registry's taxonomy.
+type TaxonomyUser interface {
+    UseTaxonomy(t *Taxonomy)
+}
+
+// Register adds or replaces a matcher; a nil matcher is ignored. Matchers that do not
+// weigh requirement details themselves are wrapped so that they do, and every result
+// gets a breakdown.
+func (r *MatcherRegistry) Register(name string, m domain.Matcher) {
+    if m == nil { return }
+    if tu, ok := m.(TaxonomyUser); ok { tu.UseTaxonomy(r.tax) }
+    r.matchers[name] = wrapMatcher(name, r.tax, m)
+}
+
+// wrapMatcher gives m the requirement weighing and breakdowns of a registered matcher.
+func wrapMatcher(name string, tax *Taxonomy, m domain.Matcher) domain.Matcher {
+    return explain(name, tax, withRequirements(name, tax, m))
+}
+
+// matcherName is the name a matcher from the registry was registered under, or "" for
+// any other matcher.
+func matcherName(m domain.Matcher) string {
+    switch m := m.(type) {
+    case explainedMatcher:
+        return m.name
+    case fallbackMatcher:
+        if len(m) > 0 { return matcherName(m[0]) }
+    }
+    return ""
+}
+
+func (r *MatcherRegistry) Default() string { return r.def }
+
+// ProjectIndexer is implemented by matchers that keep their own copy of the projects.
+type ProjectIndexer interface {
+    ProjectChanged(p *domain.Project)
+    ProjectRemoved(id int64)
+}
+
+// ProjectChanged passes a created or edited project on to the matchers that index them.
+func (r *MatcherRegistry) ProjectChanged(p *domain.Project) {
+    for _, m := range r.matchers { if pi, ok := m.(ProjectIndexer); ok { pi.ProjectChanged(p) } }
+}
+
+func (r *MatcherRegistry) ProjectRemoved(id int64) {
+    for _, m := range r.matchers { if pi, ok := m.(ProjectIndexer); ok { pi.ProjectRemoved(id) } }
+}
+
+// chain is name followed by the fallback matchers after it, or the whole fallback list
+// when name is not part of it.
+func (r *MatcherRegistry) chain(name string) []string {
+    out := []string{name}
+    rest := r.fallback
+    for i, n := range r.fallback { if n == name { rest = r.fallback[i+1:]; break } }
+    for _, n := range rest { if n != name { out = append(out, n) } }
+    return out
+}
+
+// Get returns the named matcher, or the default for "", wrapped in its fallback chain.
+func (r *MatcherRegistry) Get(name string) (domain.Matcher, error) {
+    if name == "" { name = r.def }
+    if r.matchers[name] == nil { return nil, fmt.Errorf("未知匹配器: %s", name) }
+    names := r.chain(name)
+    if len(names) == 1 { return r.matchers[name], nil }
+    fm := fallbackMatcher{}
+    for _, n := range names { fm = append(fm, r.matchers[n]) }
+    return fm, nil
+}
+
+// List reports every matcher with its health, default first. Health errors may carry
+// provider details, so they are only filled in with withErrors.
+func (r *MatcherRegistry) List(withErrors bool) []MatcherInfo {
+    var out []MatcherInfo
+    for name, m := range r.matchers {
+        info := MatcherInfo{Name: name, Default: name == r.def, Healthy: true, Fallback: r.chain(name)[1:]}
+        if hc, ok := m.(domain.HealthChecker); ok {
+            if err := hc.Healthy(); err != nil {
+                info.Healthy = false
+                if withErrors { info.Error = err.Error() }
+            }
+        }
+        out = append(out, info)
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].Default != out[j].Default { return out[i].Default }
+        return out[i].Name < out[j].Name
+    })
+    return out
+}
+
+// fallbackMatcher tries each matcher in turn. One counts as failed when it returns an
+// error or, for a non-empty project list, no results at all.
+type fallbackMatcher []domain.Matcher
+
+func (f fallbackMatcher) TryMatch(student *domain.User, projects []*domain.Project) ([]domain.MatchResult, error) {
+    var last error
+    for _, m := range f {
+        if hc, ok := m.(domain.HealthChecker); ok {
+            if err := hc.Healthy(); err != nil { last = err; continue }
+        }
+        res, err := tryMatch(m, student, projects)
+        if err == nil && (len(res) > 0 || len(projects) == 0) { return res, nil }
+        if err != nil { last = err }
+    }
+    if last == nil { last = fmt.Errorf("没有可用的匹配结果") }
+    return nil, last
+}
+
+func (f fallbackMatcher) Match(student *domain.User, projects []*domain.Project) []domain.MatchResult {
+    res, _ := f.TryMatch(student, projects)
+    return res
+}
+
+func tryMatch(m domain.Matcher, student *domain.User, projects []*domain.Project) ([]domain.MatchResult, error) {
+    if fm, ok := m.(domain.FallibleMatcher); ok { return fm.TryMatch(student, projects) }
+    return m.Match(student, projects), nil
+}
+
+// match rates one application with m. Teams are scored on their combined skills and
+// the applicant carries the cover letter and answers as its Submission.
+func (s *Service) match(m domain.Matcher, a *domain.Application, stu *domain.User, proj *domain.Project) domain.MatchResult {
+    u := s.applicant(a, stu)
+    if sub := submission(a); sub != "" { cp := *u; cp.Submission = sub; u = &cp }
+    res := m.Match(u, []*domain.Project{proj})
+    if len(res) == 0 { return domain.MatchResult{Project: proj} }
+    return res[0]
+}
+
+func (s *Service) score(m domain.Matcher, a *domain.Application, stu *domain.User, proj *domain.Project) (float64, string) {
+    r := s.match(m, a, stu, proj)
+    return r.Score, r.Reason
+}
+
+// UseMatchers makes reg resolve the matcher of requests that do not pick one and keeps
+// its indexes current with every project.
+func (s *Service) UseMatchers(reg *MatcherRegistry) { s.matchers = reg; s.indexer = reg }
+
+// matcherOr returns m, or else the registry's default matcher with its fallbacks.
+// Without a registry the service's own matcher is wrapped as the registry would.
+func (s *Service) matcherOr(m domain.Matcher) domain.Matcher {
+    if m != nil { return m }
+    if s.matchers != nil {
+        if d, err := s.matchers.Get(""); err == nil { return d }
+    }
+    return wrapMatcher(MatcherLLM, s.taxonomy, s.matcher)
+}
+
+// prefilter is the keyword matcher that a positive topK narrows projects down with.
+func (s *Service) prefilter() domain.Matcher {
+    if s.matchers != nil && s.matchers.matchers[MatcherSimple] != nil { return s.matchers.matchers[MatcherSimple] }
+    return wrapMatcher(MatcherSimple, s.taxonomy, SimpleMatcher{})
+}
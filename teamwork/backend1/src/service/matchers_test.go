@@ -0,0 +1,79 @@
+package service
+
+import (
+    "errors"
+    "testing"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+// downMatcher is a matcher whose provider is unreachable.
+type downMatcher struct{ seenMatcher }
+
+func (downMatcher) Healthy() error { return errors.New("dial tcp 10.0.0.7:443: connection refused") }
+
+func TestListHidesHealthErrorsUnlessAsked(t *testing.T) {
+    reg, err := NewMatcherRegistry(&Service{repo: newMemRepo(), matcher: &seenMatcher{}}, MatcherOptions{})
+    if err != nil { t.Fatal(err) }
+    reg.Register(MatcherLLM, &downMatcher{})
+    find := func(list []MatcherInfo) MatcherInfo {
+        for _, m := range list { if m.Name == MatcherLLM { return m } }
+        t.Fatal("llm matcher not listed")
+        return MatcherInfo{}
+    }
+    if m := find(reg.List(false)); m.Healthy || m.Error != "" { t.Errorf("student view = %+v", m) }
+    if m := find(reg.List(true)); m.Healthy || m.Error == "" { t.Errorf("admin view = %+v", m) }
+}
+
+// weigher claims to weigh requirement details, or not.
+type weigher struct {
+    seenMatcher
+    weighs bool
+}
+
+func (w *weigher) WeighsRequirements() bool { return w.weighs }
+
+func TestRegistryWrapsMatchersThatDoNotWeighRequirements(t *testing.T) {
+    for _, weighs := range []bool{true, false} {
+        m := &weigher{weighs: weighs}
+        _, wrapped := withRequirements("x", nil, m).(requirementMatcher)
+        if wrapped == weighs { t.Errorf("WeighsRequirements() = %v: wrapped = %v", weighs, wrapped) }
+    }
+}
+
+// countingMatcher scores every project the same and counts how often it ran.
+type countingMatcher struct{ calls int }
+
+func (m *countingMatcher) Match(student *domain.User, projects []*domain.Project) []domain.MatchResult {
+    m.calls++
+    var out []domain.MatchResult
+    for _, p := range projects { out = append(out, domain.MatchResult{Project: p, Score: 0.5}) }
+    return out
+}
+
+func TestDefaultMatcherComesFromTheRegistry(t *testing.T) {
+    svc := &Service{repo: newMemRepo(), matcher: &seenMatcher{}}
+    if n := matcherName(svc.matcherOr(nil)); n != MatcherLLM { t.Errorf("without registry = %q, want the wrapped service matcher", n) }
+    reg, err := NewMatcherRegistry(svc, MatcherOptions{Default: MatcherSkills, Fallback: []string{MatcherSimple}})
+    if err != nil { t.Fatal(err) }
+    svc.UseMatchers(reg)
+    if n := matcherName(svc.matcherOr(nil)); n != MatcherSkills { t.Errorf("with registry = %q, want %q", n, MatcherSkills) }
+}
+
+func TestTopKPrefiltersWithTheRegisteredSimpleMatcher(t *testing.T) {
+    repo := newMemRepo()
+    for i := 0; i < 3; i++ { _, _ = repo.AddProject(&domain.Project{Title: "p", Requirements: []string{"go"}}) }
+    svc := &Service{repo: repo, matcher: &seenMatcher{}}
+    reg, err := NewMatcherRegistry(svc, MatcherOptions{Default: MatcherSkills})
+    if err != nil { t.Fatal(err) }
+    simple := &countingMatcher{}
+    reg.Register(MatcherSimple, simple)
+    svc.UseMatchers(reg)
+    stu := &domain.User{Skills: []string{"go"}}
+
+    skills, _ := reg.Get(MatcherSkills)
+    if res := svc.matchProjects(stu, skills, 2); len(res) != 2 || simple.calls != 1 { t.Errorf("skills top 2: %d results, prefilter ran %d times", len(res), simple.calls) }
+    // the registered simple matcher is wrapped, yet still recognized and not run twice
+    m, _ := reg.Get(MatcherSimple)
+    if res := svc.matchProjects(stu, m, 2); len(res) != 3 || simple.calls != 2 { t.Errorf("simple top 2: %d results, simple ran %d times in all", len(res), simple.calls) }
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/auth"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+const (
+    defaultPATDays = 90
+    maxPATDays     = 365
+    maxPATsPerUser = 20
+)
+
+// PersonalTokenService manages personal access tokens and the service accounts that
+// scripts use instead of a person's login.
+type PersonalTokenService struct {
+    svc  *Service
+    pats repository.PATRepo
+}
+
+func NewPersonalTokenService(s *Service, pats repository.PATRepo) *PersonalTokenService {
+    return &PersonalTokenService{svc: s, pats: pats}
+}
+
+// Create issues a token for userID. The plain token is returned only here.
+func (p *PersonalTokenService) Create(userID int64, name string, scopes []string, days int) (string, *domain.PersonalToken, error) {
+    name = strings.TrimSpace(name)
+    if name == "" || len(scopes) == 0 { return "", nil, errors.New("缺少名称或权限范围") }
+    if days == 0 { days = defaultPATDays }
+    if days < 0 || days > maxPATDays { return "", nil, fmt.Errorf("有效期须在1到%d天之间", maxPATDays) }
+    seen := map[string]bool{}
+    var clean []string
+    for _, sc := range scopes {
+        if !auth.ValidScope(sc) { return "", nil, fmt.Errorf("未知权限范围: %s", sc) }
+        if !seen[sc] { seen[sc] = true; clean = append(clean, sc) }
+    }
+    active := 0
+    for _, t := range p.pats.ListPersonalTokens(userID) { if t.RevokedAt == nil && time.Now().Before(t.ExpiresAt) { active++ } }
+    if active >= maxPATsPerUser { return "", nil, errors.New("有效令牌数量已达上限") }
+    token, hash := auth.NewPersonalToken()
+    t, err := p.pats.AddPersonalToken(&domain.PersonalToken{UserID: userID, Name: name, Prefix: token[:len(auth.PATPrefix)+8], TokenHash: hash, Scopes: clean, ExpiresAt: time.Now().AddDate(0, 0, days)})
+    if err != nil { return "", nil, err }
+    return token, t, nil
+}
+
+func (p *PersonalTokenService) List(userID int64) []*domain.PersonalToken { return p.pats.ListPersonalTokens(userID) }
+
+func (p *PersonalTokenService) Revoke(userID, id int64) error {
+    ok, err := p.pats.RevokePersonalToken(userID, id)
+    if err != nil { return err }
+    if !ok { return errors.New("令牌不存在或已吊销") }
+    return nil
+}
+
+// CreateServiceAccount adds a user without a password; it can only act through tokens
+// an admin issues for it.
+func (p *PersonalTokenService) CreateServiceAccount(name string, role domain.Role) (*domain.User, error) {
+    name = strings.TrimSpace(name)
+    if name == "" { return nil, errors.New("缺少名称") }
+    if role == "" { role = domain.RoleStudent }
+    if role != domain.RoleStudent && role != domain.RoleTeacher && role != domain.RoleAdmin { return nil, errors.New("无效角色") }
+    // the reserved .invalid domain keeps the address unique and unable to receive mail
+    email := fmt.Sprintf("svc-%s@service.invalid", auth.NewTokenID()[:12])
+    return p.svc.repo.AddUser(&domain.User{Name: name, Email: email, Role: role, ServiceAccount: true, EmailVerified: true})
+}
+
+func (p *PersonalTokenService) ServiceAccounts() []*domain.User {
+    out := []*domain.User{}
+    for _, u := range p.svc.repo.ListUsers() { if u.ServiceAccount { out = append(out, u) } }
+    return out
+}
+
+func (p *PersonalTokenService) serviceAccount(id int64) (*domain.User, error) {
+    u := p.svc.repo.GetUser(id)
+    if u == nil || !u.ServiceAccount { return nil, errors.New("服务账号不存在") }
+    return u, nil
+}
+
+func (p *PersonalTokenService) CreateServiceToken(accountID int64, name string, scopes []string, days int) (string, *domain.PersonalToken, error) {
+    if _, err := p.serviceAccount(accountID); err != nil { return "", nil, err }
+    return p.Create(accountID, name, scopes, days)
+}
+
+func (p *PersonalTokenService) ServiceTokens(accountID int64) ([]*domain.PersonalToken, error) {
+    if _, err := p.serviceAccount(accountID); err != nil { return nil, err }
+    return p.List(accountID), nil
+}
+
+func (p *PersonalTokenService) RevokeServiceToken(accountID, id int64) error {
+    if _, err := p.serviceAccount(accountID); err != nil { return err }
+    return p.Revoke(accountID, id)
+}
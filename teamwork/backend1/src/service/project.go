@@ -2,16 +2,23 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
 
 	"github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
 )
 
 func (s *Service) CreateProject(p *domain.Project) (*domain.Project, error) {
+    if err := checkRequirements(s.taxonomy, p); err != nil { return nil, err }
     if p.TeacherID == 0 || p.Title == "" || p.Description == "" || len(p.Requirements) == 0 { return nil, errors.New("缺少必填字段") }
-    p.Requirements = normalize(p.Requirements)
+    if p.Capacity < 0 { return nil, errors.New("名额不能为负数") }
+    if err := checkQuestions(p.Questions); err != nil { return nil, err }
+    p.Requirements = s.canonicalSkills(p.Requirements)
     p.Tags = normalize(p.Tags)
-    return s.repo.AddProject(p)
+    created, err := s.repo.AddProject(p)
+    if err != nil { return nil, err }
+    s.projectChanged(created)
+    return created, nil
 }
 
 func (s *Service) ListProjects(teacherID string) []*domain.Project {
@@ -24,11 +31,39 @@ func (s *Service) ListProjects(teacherID string) []*domain.Project {
 }
 
 func (s *Service) UpdateProject(p *domain.Project) (*domain.Project, error) {
+    if err := checkRequirements(s.taxonomy, p); err != nil { return nil, err }
     if p.ID == 0 || p.Title == "" || p.Description == "" || len(p.Requirements) == 0 { return nil, errors.New("缺少必填字段") }
-    p.Requirements = normalize(p.Requirements)
+    if p.Capacity < 0 { return nil, errors.New("名额不能为负数") }
+    if n := s.seatsTaken(p.ID); p.Capacity > 0 && p.Capacity < n { return nil, fmt.Errorf("名额不能少于已录取的%d个", n) }
+    if err := checkQuestions(p.Questions); err != nil { return nil, err }
+    p.Requirements = s.canonicalSkills(p.Requirements)
     p.Tags = normalize(p.Tags)
-    return s.repo.UpdateProject(p)
+    out, err := s.repo.UpdateProject(p)
+    if err != nil { return nil, err }
+    s.projectChanged(out)
+    return out, nil
 }
 
-func (s *Service) DeleteProject(id int64) error { return s.repo.DeleteProject(id) }
+// seatsTaken counts the seats held by approved and completed applications of a project.
+func (s *Service) seatsTaken(projectID int64) int {
+    n := 0
+    for _, a := range s.repo.ListApplications() {
+        if a.ProjectID == projectID && (a.Status == domain.StatusApproved || a.Status == domain.StatusCompleted) { n += appSeats(a) }
+    }
+    return n
+}
+
+func (s *Service) DeleteProject(id int64) error {
+    if err := s.repo.DeleteProject(id); err != nil { return err }
+    if s.indexer != nil { s.indexer.ProjectRemoved(id) }
+    return nil
+}
 func (s *Service) SetProjectArchived(id int64, archived bool) error { return s.repo.SetProjectArchived(id, archived) }
+
+// UseProjectIndexer keeps idx, usually the matcher registry, current with every project
+// created, edited or deleted through the service.
+func (s *Service) UseProjectIndexer(idx ProjectIndexer) { s.indexer = idx }
+
+func (s *Service) projectChanged(p *domain.Project) {
+    if s.indexer != nil { s.indexer.ProjectChanged(p) }
+}
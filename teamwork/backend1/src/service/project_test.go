@@ -0,0 +1,57 @@
+package service
+
+import (
+    "testing"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+func TestUpdateProjectKeepsCapacityAboveSeatsTaken(t *testing.T) {
+    repo := newMemRepo()
+    svc := &Service{repo: repo}
+    p, _ := repo.AddProject(&domain.Project{TeacherID: 1, Title: "p", Description: "d", Requirements: []string{"go"}, Capacity: 5})
+    repo.apps[100] = &domain.Application{ID: 100, ProjectID: p.ID, Status: domain.StatusApproved, TeamID: 7, Seats: 2}
+    repo.apps[101] = &domain.Application{ID: 101, ProjectID: p.ID, Status: domain.StatusCompleted}
+    repo.apps[102] = &domain.Application{ID: 102, ProjectID: p.ID, Status: domain.StatusWaitlisted}
+
+    for capacity, ok := range map[int]bool{2: false, 3: true, 0: true} {
+        upd := *p
+        upd.Capacity = capacity
+        if _, err := svc.UpdateProject(&upd); (err == nil) != ok { t.Errorf("capacity %d: err %v, want ok=%v", capacity, err, ok) }
+    }
+}
+
+// memIndexer records the projects it is told about.
+type memIndexer struct{ changed, removed []int64 }
+
+func (m *memIndexer) ProjectChanged(p *domain.Project) { m.changed = append(m.changed, p.ID) }
+
+func (m *memIndexer) ProjectRemoved(id int64) { m.removed = append(m.removed, id) }
+
+func TestProjectChangesReachTheIndex(t *testing.T) {
+    repo := newMemRepo()
+    svc := &Service{repo: repo}
+    idx := &memIndexer{}
+    svc.UseProjectIndexer(idx)
+
+    p, err := svc.CreateProject(&domain.Project{TeacherID: 1, Title: "p", Description: "d", Requirements: []string{"rust"}})
+    if err != nil { t.Fatal(err) }
+    upd := *p
+    upd.Description = "d2"
+    if _, err := svc.UpdateProject(&upd); err != nil { t.Fatal(err) }
+    if _, err := svc.UpdateProject(&domain.Project{ID: p.ID}); err == nil { t.Fatal("invalid update accepted") }
+    if err := svc.DeleteProject(p.ID); err != nil { t.Fatal(err) }
+    if len(idx.changed) != 2 || idx.changed[0] != p.ID || idx.changed[1] != p.ID { t.Errorf("changed = %v, want the project twice", idx.changed) }
+    if len(idx.removed) != 1 || idx.removed[0] != p.ID { t.Errorf("removed = %v", idx.removed) }
+}
+
+func TestRegistryKeepsTextIndexCurrent(t *testing.T) {
+    repo := newMemRepo()
+    reg, err := NewMatcherRegistry(&Service{repo: repo, matcher: &seenMatcher{}}, MatcherOptions{Default: MatcherText})
+    if err != nil { t.Fatal(err) }
+    svc := &Service{repo: repo}
+    svc.UseProjectIndexer(reg)
+    p, _ := svc.CreateProject(&domain.Project{TeacherID: 1, Title: "编译器", Description: "rust compiler", Requirements: []string{"rust"}})
+    tfidf, _ := reg.Get(MatcherText)
+    if res := tfidf.Match(&domain.User{Skills: []string{"rust"}}, []*domain.Project{p}); res[0].Score == 0 { t.Error("created project not indexed") }
+}
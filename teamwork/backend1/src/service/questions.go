@@ -0,0 +1,91 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "net/url"
+    "strings"
+    "unicode/utf8"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+const (
+    maxQuestions      = 20
+    defaultAnswerLen  = 2000
+    maxCoverLetterLen = 5000
+)
+
+// checkQuestions validates a project's application form.
+func checkQuestions(qs []domain.Question) error {
+    if len(qs) > maxQuestions { return fmt.Errorf("最多设置%d个问题", maxQuestions) }
+    seen := map[string]bool{}
+    for i := range qs {
+        q := &qs[i]
+        q.ID, q.Label = strings.TrimSpace(q.ID), strings.TrimSpace(q.Label)
+        if q.ID == "" || q.Label == "" { return errors.New("问题缺少ID或标题") }
+        if seen[q.ID] { return fmt.Errorf("问题ID重复: %s", q.ID) }
+        seen[q.ID] = true
+        if q.MaxLength < 0 { return fmt.Errorf("问题「%s」的长度上限不能为负数", q.Label) }
+        switch q.Type {
+        case domain.QuestionText, domain.QuestionFile:
+            q.Options = nil
+        case domain.QuestionChoice:
+            if len(q.Options) == 0 { return fmt.Errorf("选择题「%s」缺少选项", q.Label) }
+        default:
+            return fmt.Errorf("问题「%s」的类型无效: %s", q.Label, q.Type)
+        }
+    }
+    return nil
+}
+
+// checkAnswers validates a submission against the project's form and rewrites its
+// answers in form order with their labels filled in.
+func checkAnswers(p *domain.Project, a *domain.Application) error {
+    a.CoverLetter = strings.TrimSpace(a.CoverLetter)
+    if p.RequireCoverLetter && a.CoverLetter == "" { return errors.New("该项目要求填写申请信") }
+    if utf8.RuneCountInString(a.CoverLetter) > maxCoverLetterLen { return fmt.Errorf("申请信不能超过%d字", maxCoverLetterLen) }
+    known := map[string]bool{}
+    for _, q := range p.Questions { known[q.ID] = true }
+    given := map[string]string{}
+    for _, ans := range a.Answers {
+        if !known[ans.QuestionID] { return fmt.Errorf("未知问题: %s", ans.QuestionID) }
+        if _, dup := given[ans.QuestionID]; dup { return fmt.Errorf("问题%s重复作答", ans.QuestionID) }
+        given[ans.QuestionID] = strings.TrimSpace(ans.Value)
+    }
+    var out []domain.Answer
+    for _, q := range p.Questions {
+        v := given[q.ID]
+        if v == "" {
+            if q.Required { return fmt.Errorf("请回答「%s」", q.Label) }
+            continue
+        }
+        switch q.Type {
+        case domain.QuestionText:
+            limit := q.MaxLength
+            if limit == 0 { limit = defaultAnswerLen }
+            if utf8.RuneCountInString(v) > limit { return fmt.Errorf("「%s」不能超过%d字", q.Label, limit) }
+        case domain.QuestionChoice:
+            if !contains(q.Options, v) { return fmt.Errorf("「%s」的选项无效", q.Label) }
+        case domain.QuestionFile:
+            if u, err := url.Parse(v); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" { return fmt.Errorf("「%s」需要填写文件链接", q.Label) }
+        }
+        out = append(out, domain.Answer{QuestionID: q.ID, Label: q.Label, Value: v})
+    }
+    a.Answers = out
+    return nil
+}
+
+func contains(list []string, v string) bool {
+    for _, s := range list { if s == v { return true } }
+    return false
+}
+
+// submission renders the applicant's cover letter and answers for matchers, each item
+// under its own 【label】 line so that it cannot run into the next one.
+func submission(a *domain.Application) string {
+    var b strings.Builder
+    if a.CoverLetter != "" { b.WriteString("【申请信】\n" + a.CoverLetter + "\n") }
+    for _, ans := range a.Answers { b.WriteString("【" + ans.Label + "】\n" + ans.Value + "\n") }
+    return b.String()
+}
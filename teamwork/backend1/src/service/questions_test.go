@@ -0,0 +1,44 @@
+package service
+
+import (
+    "strings"
+    "testing"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+// seenMatcher records what it was asked to rate.
+type seenMatcher struct {
+    student  *domain.User
+    projects []*domain.Project
+}
+
+func (m *seenMatcher) Match(student *domain.User, projects []*domain.Project) []domain.MatchResult {
+    m.student, m.projects = student, projects
+    return []domain.MatchResult{{Project: projects[0], Score: 0.5}}
+}
+
+func TestMatchPassesSubmissionBesideProject(t *testing.T) {
+    repo := newMemRepo()
+    stu, _ := repo.AddUser(&domain.User{Name: "stu", Skills: []string{"go"}})
+    p := &domain.Project{ID: 1, Title: "p", Description: "原描述"}
+    a := &domain.Application{StudentID: stu.ID, ProjectID: p.ID, CoverLetter: "我想参加", Answers: []domain.Answer{{QuestionID: "q1", Label: "经历", Value: "做过编译器"}}}
+    m := &seenMatcher{}
+    (&Service{repo: repo}).match(m, a, stu, p)
+
+    if m.projects[0] != p || p.Description != "原描述" { t.Errorf("project changed: %q", m.projects[0].Description) }
+    want := "【申请信】\n我想参加\n【经历】\n做过编译器\n"
+    if m.student.Submission != want { t.Errorf("submission = %q, want %q", m.student.Submission, want) }
+    if stu.Submission != "" { t.Error("submission written onto the stored user") }
+}
+
+func TestTextMatcherReadsSubmission(t *testing.T) {
+    p := &domain.Project{ID: 1, Title: "编译器", Description: "compiler backend"}
+    m := NewTextMatcher(nil, []*domain.Project{p})
+    plain := &domain.User{Skills: []string{"python"}}
+    with := &domain.User{Skills: []string{"python"}, Submission: "【经历】\ncompiler backend\n"}
+    if a, b := m.Match(plain, []*domain.Project{p})[0].Score, m.Match(with, []*domain.Project{p})[0].Score; b <= a {
+        t.Errorf("score with submission %v, without %v", b, a)
+    }
+    if !strings.Contains(p.Description, "compiler") || strings.Contains(p.Description, "【") { t.Errorf("project changed: %q", p.Description) }
+}
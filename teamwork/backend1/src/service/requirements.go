@@ -0,0 +1,207 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+const (
+    maxRequirementWeight = 10
+    maxLevel             = 5
+    // mandatoryPenalty scales the score of a project whose mandatory requirements are
+    // not all met.
+    mandatoryPenalty = 0.5
+)
+
+// checkRequirements cleans a project's requirement details and, when there are any,
+// makes Requirements list their skills.
+func checkRequirements(tax *Taxonomy, p *domain.Project) error {
+    if len(p.RequirementDetails) == 0 { p.RequirementDetails = nil; return nil }
+    seen := map[string]bool{}
+    var out []domain.Requirement
+    var names []string
+    for _, r := range p.RequirementDetails {
+        sk := tax.Normalize(normalize([]string{r.Skill}))
+        if len(sk) == 0 { return errors.New("技能要求缺少技能名称") }
+        r.Skill = sk[0]
+        if seen[r.Skill] { return fmt.Errorf("技能要求重复: %s", r.Skill) }
+        seen[r.Skill] = true
+        if r.Weight < 0 || r.Weight > maxRequirementWeight { return fmt.Errorf("「%s」的权重须在0到%d之间", r.Skill, maxRequirementWeight) }
+        if r.MinLevel < 0 || r.MinLevel > maxLevel { return fmt.Errorf("「%s」的最低熟练度须在0到%d之间", r.Skill, maxLevel) }
+        out = append(out, r)
+        names = append(names, r.Skill)
+    }
+    p.RequirementDetails, p.Requirements = out, names
+    return nil
+}
+
+// KeepRequirementDetails carries the details of a project over to an edit that only
+// sent the plain requirement list: listed skills keep their details, new ones are
+// optional.
+func (s *Service) KeepRequirementDetails(cur []domain.Requirement, names []string) []domain.Requirement {
+    if len(cur) == 0 { return nil }
+    byName := map[string]domain.Requirement{}
+    for _, r := range cur { byName[r.Skill] = r }
+    var out []domain.Requirement
+    for _, n := range s.canonicalSkills(names) {
+        r, ok := byName[n]
+        if !ok { r = domain.Requirement{Skill: n} }
+        out = append(out, r)
+    }
+    return out
+}
+
+// projectRequirements returns the detailed requirements, or the plain list as optional
+// requirements of weight 1.
+func projectRequirements(p *domain.Project) []domain.Requirement {
+    if len(p.RequirementDetails) > 0 { return p.RequirementDetails }
+    var out []domain.Requirement
+    for _, s := range p.Requirements { out = append(out, domain.Requirement{Skill: s}) }
+    return out
+}
+
+func weightOf(r domain.Requirement) float64 {
+    if r.Weight == 0 { return 1 }
+    return r.Weight
+}
+
+// cleanProficiency keeps the ratings of the listed skills under their canonical names.
+func cleanProficiency(tax *Taxonomy, skills []string, prof map[string]int) (map[string]int, error) {
+    if len(prof) == 0 { return nil, nil }
+    out := map[string]int{}
+    for k, v := range prof {
+        sk := tax.Normalize(normalize([]string{k}))
+        if len(sk) == 0 || !contains(skills, sk[0]) { continue }
+        if v < 1 || v > maxLevel { return nil, fmt.Errorf("「%s」的熟练度须在1到%d之间", k, maxLevel) }
+        out[sk[0]] = v
+    }
+    if len(out) == 0 { return nil, nil }
+    return out, nil
+}
+
+// reqCheck is how one requirement fares against a student.
+type reqCheck struct {
+    domain.Requirement
+    Credit  float64 // 0..1 after the level shortfall
+    By      string  // the student's skill that earned the credit
+    Level   int     // the student's level in By, 0 when not rated
+    Unrated bool    // By has no rating although the requirement asks for a level
+    Met     bool    // covered at the minimum level; mandatory requirements must be met
+}
+
+type reqEval struct {
+    Checks   []reqCheck
+    Coverage float64  // weighted share of the requirements covered
+    Unmet    []string // mandatory requirements that are not met
+}
+
+// evaluate checks the student's skills against the requirements.
+func evaluate(tax *Taxonomy, stu *domain.User, reqs []domain.Requirement) reqEval {
+    have := tax.Normalize(stu.Skills)
+    var ev reqEval
+    total, got := 0.0, 0.0
+    for _, r := range reqs {
+        c := reqCheck{Requirement: r}
+        for _, h := range have {
+            if cr := tax.Credit(h, r.Skill); cr > c.Credit { c.Credit, c.By = cr, h }
+        }
+        c.Met = c.Credit > 0
+        if c.Met && r.MinLevel > 0 {
+            // an unrated skill proves no level, so it earns nothing towards one
+            c.Level = stu.Proficiency[c.By]
+            if c.Level == 0 { c.Unrated = true }
+            if c.Level < r.MinLevel { c.Credit *= float64(c.Level) / float64(r.MinLevel); c.Met = false }
+        }
+        if r.Mandatory && !c.Met { ev.Unmet = append(ev.Unmet, r.Skill) }
+        total += weightOf(r)
+        got += weightOf(r) * c.Credit
+        ev.Checks = append(ev.Checks, c)
+    }
+    if total > 0 { ev.Coverage = got / total }
+    return ev
+}
+
+// penalize applies the mandatory penalty and names the unmet requirements first in
+// the reason. A breakdown, if given, records both.
+func (ev reqEval) penalize(score float64, reason string, b *domain.MatchBreakdown) (float64, string) {
+    if b != nil { b.UnmetMandatory = ev.Unmet }
+    if len(ev.Unmet) == 0 { return score, reason }
+    prefix := "未满足必备要求：" + strings.Join(ev.Unmet, "、")
+    if reason != "" { prefix += "；" + reason }
+    out := round2(score * mandatoryPenalty)
+    if b != nil { b.Components = append(b.Components, domain.ScoreComponent{Name: "mandatory_penalty", Weight: mandatoryPenalty, Score: score, Contribution: round2(out - score)}) }
+    return out, prefix
+}
+
+// describeRequirements spells out the weights for matchers that read the description.
+func describeRequirements(p *domain.Project) *domain.Project {
+    if len(p.RequirementDetails) == 0 { return p }
+    var parts []string
+    for _, r := range p.RequirementDetails {
+        var attrs []string
+        if r.Mandatory { attrs = append(attrs, "必备") } else { attrs = append(attrs, "加分项") }
+        attrs = append(attrs, fmt.Sprintf("权重%g", weightOf(r)))
+        if r.MinLevel > 0 { attrs = append(attrs, fmt.Sprintf("熟练度至少%d/%d", r.MinLevel, maxLevel)) }
+        parts = append(parts, fmt.Sprintf("%s（%s）", r.Skill, strings.Join(attrs, "，")))
+    }
+    cp := *p
+    cp.Description = p.Description + "\n\n技能要求：" + strings.Join(parts, "；")
+    return &cp
+}
+
+// requirementMatcher makes a matcher that only knows the plain requirement list honor
+// the details: it reads them in the description, its score is averaged with the
+// weighted coverage and unmet mandatory requirements are penalized. Projects without
+// details are passed through unchanged.
+type requirementMatcher struct {
+    name  string
+    inner domain.Matcher
+    tax   *Taxonomy
+}
+
+func withRequirements(name string, tax *Taxonomy, m domain.Matcher) domain.Matcher {
+    if rw, ok := m.(domain.RequirementWeigher); ok && rw.WeighsRequirements() { return m }
+    return requirementMatcher{name: name, inner: m, tax: tax}
+}
+
+func (m requirementMatcher) WeighsRequirements() bool { return true }
+
+func (m requirementMatcher) Healthy() error {
+    if hc, ok := m.inner.(domain.HealthChecker); ok { return hc.Healthy() }
+    return nil
+}
+
+func (m requirementMatcher) Match(student *domain.User, projects []*domain.Project) []domain.MatchResult {
+    res, _ := m.TryMatch(student, projects)
+    return res
+}
+
+func (m requirementMatcher) TryMatch(student *domain.User, projects []*domain.Project) ([]domain.MatchResult, error) {
+    described := make([]*domain.Project, len(projects))
+    orig := map[int64]*domain.Project{}
+    for i, p := range projects { described[i] = describeRequirements(p); orig[p.ID] = p }
+    res, err := tryMatch(m.inner, student, described)
+    if err != nil { return nil, err }
+    for i := range res {
+        if res[i].Project == nil { continue }
+        p := orig[res[i].Project.ID]
+        if p == nil { continue } // a project the matcher made up
+        res[i].Project = p
+        if len(p.RequirementDetails) == 0 { continue }
+        ev := evaluate(m.tax, student, p.RequirementDetails)
+        b := res[i].Breakdown
+        if b == nil {
+            b = newBreakdown(defaultVersion, ev, component(m.name, 1, res[i].Score))
+        }
+        // the matcher's own parts now count half
+        for k := range b.Components { b.Components[k].Weight, b.Components[k].Contribution = b.Components[k].Weight/2, round2(b.Components[k].Contribution/2) }
+        b.Components = append(b.Components, component("requirements", 0.5, ev.Coverage))
+        res[i].Breakdown = b
+        res[i].Score = round2((res[i].Score + ev.Coverage) / 2)
+        res[i].Score, res[i].Reason = ev.penalize(res[i].Score, res[i].Reason, b)
+    }
+    return res, nil
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+    "testing"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+func TestUnratedSkillEarnsNoLevelCredit(t *testing.T) {
+    p := &domain.Project{ID: 1, Requirements: []string{"go"}, RequirementDetails: []domain.Requirement{{Skill: "go", Mandatory: true, MinLevel: 3}}}
+    m := NewSkillMatcher(nil)
+
+    res := m.Match(&domain.User{Skills: []string{"go"}}, []*domain.Project{p})[0]
+    row := res.Breakdown.Requirements[0]
+    if res.Score != 0 || row.Credit != 0 || !row.Unrated || row.Status != domain.RequirementMissing { t.Errorf("unrated: score %v, row %+v", res.Score, row) }
+    if len(res.Breakdown.UnmetMandatory) != 1 { t.Errorf("unmet = %v", res.Breakdown.UnmetMandatory) }
+
+    res = m.Match(&domain.User{Skills: []string{"go"}, Proficiency: map[string]int{"go": 3}}, []*domain.Project{p})[0]
+    if row := res.Breakdown.Requirements[0]; res.Score != 1 || row.Unrated { t.Errorf("rated: score %v, row %+v", res.Score, row) }
+
+    // without a minimum level the rating does not matter
+    p.RequirementDetails[0].MinLevel = 0
+    res = m.Match(&domain.User{Skills: []string{"go"}}, []*domain.Project{p})[0]
+    if row := res.Breakdown.Requirements[0]; res.Score != 1 || row.Unrated { t.Errorf("no level: score %v, row %+v", res.Score, row) }
+}
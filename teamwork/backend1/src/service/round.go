@@ -0,0 +1,106 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "strings"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+// RoundService manages selection rounds and closes them when their window ends.
+type RoundService struct {
+    rounds repository.RoundRepo
+    life   *LifecycleService
+}
+
+func NewRoundService(rounds repository.RoundRepo, life *LifecycleService) *RoundService {
+    return &RoundService{rounds: rounds, life: life}
+}
+
+func (r *RoundService) List() []*domain.Round { return r.rounds.ListRounds() }
+
+// Current returns the round open now, or nil.
+func (r *RoundService) Current() *domain.Round { return openRound(r.rounds.ListRounds(), time.Now()) }
+
+func openRound(rounds []*domain.Round, now time.Time) *domain.Round {
+    for _, rd := range rounds {
+        if !rd.Closed && !now.Before(rd.OpensAt) && now.Before(rd.ClosesAt) { return rd }
+    }
+    return nil
+}
+
+func (r *RoundService) Create(rd *domain.Round) (*domain.Round, error) {
+    rd.ID, rd.Closed = 0, false
+    if err := r.validate(rd); err != nil { return nil, err }
+    return r.rounds.AddRound(rd)
+}
+
+func (r *RoundService) Update(rd *domain.Round) (*domain.Round, error) {
+    cur := r.rounds.GetRound(rd.ID)
+    if cur == nil { return nil, errors.New("轮次不存在") }
+    if cur.Closed { return nil, errors.New("轮次已结束，不能修改") }
+    rd.Closed = false
+    if err := r.validate(rd); err != nil { return nil, err }
+    return rd, r.rounds.UpdateRound(rd)
+}
+
+func (r *RoundService) validate(rd *domain.Round) error {
+    rd.Name = strings.TrimSpace(rd.Name)
+    if rd.Name == "" || rd.OpensAt.IsZero() || rd.ClosesAt.IsZero() { return errors.New("缺少必填字段") }
+    if !rd.OpensAt.Before(rd.ClosesAt) { return errors.New("截止时间必须晚于开始时间") }
+    if rd.MaxApplications < 0 { return errors.New("申请数量上限不能为负数") }
+    for _, o := range r.rounds.ListRounds() {
+        if o.ID != rd.ID && rd.OpensAt.Before(o.ClosesAt) && o.OpensAt.Before(rd.ClosesAt) { return fmt.Errorf("与轮次「%s」时间重叠", o.Name) }
+    }
+    return nil
+}
+
+// Close ends a round now instead of waiting for its deadline.
+func (r *RoundService) Close(id int64) error {
+    rd := r.rounds.GetRound(id)
+    if rd == nil { return errors.New("轮次不存在") }
+    if rd.Closed { return nil }
+    if now := time.Now(); rd.ClosesAt.After(now) {
+        rd.ClosesAt = now
+        if rd.OpensAt.After(now) { rd.OpensAt = now }
+        if err := r.rounds.UpdateRound(rd); err != nil { return err }
+    }
+    return r.close(rd)
+}
+
+// CloseLoop closes rounds whose deadline has passed until ctx is done. Every instance may
+// run it: expiring is guarded by the application status and closing by CloseRound.
+func (r *RoundService) CloseLoop(ctx context.Context, interval time.Duration) {
+    t := time.NewTicker(interval)
+    defer t.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case now := <-t.C:
+            for _, rd := range r.rounds.ListRounds() {
+                if rd.Closed || now.Before(rd.ClosesAt) { continue }
+                if err := r.close(rd); err != nil { log.Printf("close round %d: %v", rd.ID, err) }
+            }
+        }
+    }
+}
+
+// expiring are the statuses of applications still undecided when their round closes.
+var expiring = []string{domain.StatusSubmitted, domain.StatusInterview, domain.StatusWaitlisted}
+
+// close expires the round's undecided applications, then marks it closed. A failure
+// leaves the round open, so the next run picks up where this one stopped.
+func (r *RoundService) close(rd *domain.Round) error {
+    for _, app := range r.rounds.ListRoundApplications(rd.ID, expiring) {
+        if _, err := r.life.system(app, domain.StatusExpired, "轮次已截止"); err != nil { return err }
+    }
+    if _, err := r.rounds.CloseRound(rd.ID); err != nil { return err }
+    rd.Closed = true
+    return nil
+}
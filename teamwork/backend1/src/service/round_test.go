@@ -0,0 +1,135 @@
+package service
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+// overLimit mirrors the repository's round limit check; the caller holds the lock.
+func (m *memLifecycle) overLimit(limit *repository.RoundLimit) bool {
+    if limit == nil || limit.Max <= 0 { return false }
+    for _, id := range limit.Students {
+        n := 0
+        for _, o := range m.repo.apps {
+            if o.ID != limit.Except && o.RoundID == limit.RoundID && o.Status != domain.StatusWithdrawn && (o.StudentID == id || containsID(o.Members, id)) { n++ }
+        }
+        if n >= limit.Max { return true }
+    }
+    return false
+}
+
+// ResubmitApplication writes nothing when the limit check fails or the status moved on.
+func (m *memLifecycle) ResubmitApplication(app *domain.Application, from string, change *domain.ApplicationStatusChange, limit *repository.RoundLimit) (bool, error) {
+    m.repo.mu.Lock(); defer m.repo.mu.Unlock()
+    if m.overLimit(limit) { return false, repository.ErrRoundLimit }
+    a := m.repo.apps[app.ID]
+    if a == nil || a.Status != from { return false, nil }
+    a.Status, a.CoverLetter, a.Answers, a.Members, a.Seats, a.RoundID = domain.StatusSubmitted, app.CoverLetter, app.Answers, app.Members, app.Seats, app.RoundID
+    m.history = append(m.history, change)
+    return true, nil
+}
+
+// memRounds has no rounds unless a test adds some. Its counts come from the
+// applications of repo; staleCount makes CountRoundApplications see none, like a read
+// racing a concurrent submission.
+type memRounds struct {
+    repository.RoundRepo
+    repo       *memRepo
+    rounds     []*domain.Round
+    staleCount bool
+}
+
+func (m *memRounds) ListRounds() []*domain.Round { return m.rounds }
+
+func (m *memRounds) UpdateRound(rd *domain.Round) error { return nil }
+
+func (m *memRounds) CountRoundApplications(studentID, roundID int64) int {
+    if m.staleCount { return 0 }
+    return len(m.roundApps(roundID, func(a *domain.Application) bool { return (a.StudentID == studentID || containsID(a.Members, studentID)) && a.Status != domain.StatusWithdrawn }))
+}
+
+func (m *memRounds) ListRoundApplications(roundID int64, statuses []string) []*domain.Application {
+    return m.roundApps(roundID, func(a *domain.Application) bool {
+        for _, s := range statuses { if a.Status == s { return true } }
+        return false
+    })
+}
+
+func (m *memRounds) roundApps(roundID int64, keep func(*domain.Application) bool) []*domain.Application {
+    var out []*domain.Application
+    for _, a := range m.repo.ListApplications() {
+        if a.RoundID == roundID && keep(a) { cp := *a; out = append(out, &cp) }
+    }
+    return out
+}
+
+func (m *memRounds) CloseRound(id int64) (bool, error) {
+    for _, rd := range m.rounds {
+        if rd.ID == id && !rd.Closed { rd.Closed = true; return true, nil }
+    }
+    return false, nil
+}
+
+func TestRoundLimitHoldsAgainstStaleCount(t *testing.T) {
+    l, hist, stu, p := newLifecycleTest(t)
+    rounds := l.rounds.(*memRounds)
+    rounds.rounds = []*domain.Round{{ID: 1, Name: "r", OpensAt: time.Now().Add(-time.Hour), ClosesAt: time.Now().Add(time.Hour), MaxApplications: 1}}
+    rounds.staleCount = true
+    other, _ := hist.repo.AddProject(&domain.Project{Title: "q", TeacherID: p.TeacherID})
+    if _, err := l.Submit(stu, &domain.Application{StudentID: stu.ID, ProjectID: p.ID}); err != nil { t.Fatal(err) }
+    if _, err := l.Submit(stu, &domain.Application{StudentID: stu.ID, ProjectID: other.ID}); err == nil { t.Fatal("second application passed the round limit") }
+}
+
+func TestCloseExpiresUndecidedApplications(t *testing.T) {
+    l, hist, stu, p := newLifecycleTest(t)
+    rounds := l.rounds.(*memRounds)
+    rd := &domain.Round{ID: 1, Name: "r", OpensAt: time.Now().Add(-2 * time.Hour), ClosesAt: time.Now().Add(-time.Hour)}
+    rounds.rounds = []*domain.Round{rd}
+    status := map[int64]string{}
+    for i, s := range []string{domain.StatusSubmitted, domain.StatusInterview, domain.StatusWaitlisted, domain.StatusApproved} {
+        id := int64(100 + i)
+        hist.repo.apps[id] = &domain.Application{ID: id, StudentID: stu.ID, ProjectID: p.ID, RoundID: rd.ID, Status: s}
+        status[id] = s
+    }
+    rs := NewRoundService(rounds, l)
+    if err := rs.close(rd); err != nil { t.Fatal(err) }
+    if !rd.Closed { t.Error("round not closed") }
+    for id, before := range status {
+        want := domain.StatusExpired
+        if before == domain.StatusApproved { want = before }
+        if got := hist.repo.apps[id].Status; got != want { t.Errorf("%s application is %s, want %s", before, got, want) }
+    }
+}
+
+func TestCloseLoopStopsWithContext(t *testing.T) {
+    l, _, _, _ := newLifecycleTest(t)
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan struct{})
+    go func() { NewRoundService(l.rounds, l).CloseLoop(ctx, time.Millisecond); close(done) }()
+    cancel()
+    select {
+    case <-done:
+    case <-time.After(2 * time.Second): t.Fatal("CloseLoop kept running after cancel")
+    }
+}
+
+func TestTeamMembersCountAgainstRoundLimit(t *testing.T) {
+    l, hist, stu, p := newLifecycleTest(t)
+    rounds := l.rounds.(*memRounds)
+    rd := &domain.Round{ID: 1, Name: "r", OpensAt: time.Now().Add(-time.Hour), ClosesAt: time.Now().Add(time.Hour), MaxApplications: 1}
+    rounds.rounds = []*domain.Round{rd}
+    lead, _ := hist.repo.AddUser(&domain.User{Name: "lead", Role: domain.RoleStudent})
+    other, _ := hist.repo.AddProject(&domain.Project{Title: "q", TeacherID: p.TeacherID})
+    // stu already used the round's one application as a team member
+    hist.repo.apps[50] = &domain.Application{ID: 50, StudentID: lead.ID, ProjectID: other.ID, RoundID: rd.ID, TeamID: 7, Members: []int64{lead.ID, stu.ID}, Status: domain.StatusSubmitted}
+    if _, err := l.admit(&domain.Application{StudentID: stu.ID, ProjectID: p.ID}); err == nil { t.Error("admit let a team member past the limit") }
+
+    rounds.staleCount = true
+    team := &domain.Application{StudentID: 99, ProjectID: p.ID, TeamID: 8, Members: []int64{99, stu.ID}}
+    if _, err := l.admit(team); err != nil { t.Fatal(err) }
+    if _, err := hist.CreateApplication(team, &domain.ApplicationStatusChange{}, roundLimit(rd, team)); err != repository.ErrRoundLimit { t.Errorf("insert err = %v, want ErrRoundLimit", err) }
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+)
+
+// SkillMatcher scores the weighted share of a project's requirements the student's
+// skills cover. Through the taxonomy aliases count as the same skill and related
+// skills earn partial credit, e.g. react towards frontend; ratings below a required
+// level earn proportionally less.
+type SkillMatcher struct {
+    tax *Taxonomy
+}
+
+func NewSkillMatcher(tax *Taxonomy) SkillMatcher { return SkillMatcher{tax: tax} }
+
+func (SkillMatcher) WeighsRequirements() bool { return true }
+
+func (m SkillMatcher) Match(student *domain.User, projects []*domain.Project) []domain.MatchResult {
+    out := make([]domain.MatchResult, 0, len(projects))
+    for _, p := range projects {
+        reqs := projectRequirements(p)
+        ev := evaluate(m.tax, student, reqs)
+        b := newBreakdown(skillMatcherVersion, ev, component("requirements", 1, ev.Coverage))
+        if len(reqs) == 0 { out = append(out, domain.MatchResult{Project: p, Reason: "项目未列出技能要求", Breakdown: b}); continue }
+        var full, partial, missing []string
+        for _, c := range ev.Checks {
+            switch {
+            case c.Credit == 1: full = append(full, c.Skill)
+            case c.Credit > 0 && c.By != c.Skill: partial = append(partial, fmt.Sprintf("%s（%s）", c.Skill, c.By))
+            case c.Credit > 0: partial = append(partial, fmt.Sprintf("%s（熟练度低于%d）", c.Skill, c.MinLevel))
+            case c.Unrated: missing = append(missing, fmt.Sprintf("%s（未填写熟练度）", c.Skill))
+            default: missing = append(missing, c.Skill)
+            }
+        }
+        score, reason := ev.penalize(round2(ev.Coverage), skillReason(full, partial, missing), b)
+        out = append(out, domain.MatchResult{Project: p, Score: score, Reason: reason, Breakdown: b})
+    }
+    sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+    return out
+}
+
+func skillReason(full, partial, missing []string) string {
+    var parts []string
+    if len(full) > 0 { parts = append(parts, "满足："+strings.Join(full, "、")) }
+    if len(partial) > 0 { parts = append(parts, "部分满足："+strings.Join(partial, "、")) }
+    if len(missing) > 0 { parts = append(parts, "缺少："+strings.Join(missing, "、")) }
+    return strings.Join(parts, "；")
+}
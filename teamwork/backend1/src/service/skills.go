@@ -0,0 +1,353 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "maps"
+    "slices"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+    "unicode/utf8"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+const (
+    maxSkillNameLen = 64
+    maxSkillAliases = 20
+    maxSuggestions  = 20
+)
+
+// Taxonomy resolves skill aliases to canonical names and knows which skill is part of
+// which. Canonical names are lowercased like every stored skill. A nil Taxonomy knows
+// no skills and only cleans the names.
+type Taxonomy struct {
+    mu      sync.RWMutex
+    canon   map[string]string   // name or alias key -> canonical name
+    parent  map[string]string   // canonical name -> canonical name of the parent
+    labels  map[string][]string // canonical name -> display name and aliases
+    display map[string]string   // canonical name -> name as entered
+}
+
+func NewTaxonomy(skills []*domain.Skill) *Taxonomy {
+    t := &Taxonomy{}
+    t.Load(skills)
+    return t
+}
+
+func skillKey(s string) string { return strings.Join(strings.Fields(strings.ToLower(s)), " ") }
+
+// Load replaces the taxonomy. A name always wins over another skill's alias.
+func (t *Taxonomy) Load(skills []*domain.Skill) {
+    canon, parent, labels, display := map[string]string{}, map[string]string{}, map[string][]string{}, map[string]string{}
+    byID := map[int64]string{}
+    for _, s := range skills {
+        k := skillKey(s.Name)
+        byID[s.ID], canon[k], display[k] = k, k, s.Name
+        labels[k] = append(labels[k], s.Name)
+    }
+    for _, s := range skills {
+        k := byID[s.ID]
+        for _, a := range s.Aliases {
+            ak := skillKey(a)
+            if ak == "" { continue }
+            if _, taken := canon[ak]; !taken { canon[ak] = k }
+            labels[k] = append(labels[k], a)
+        }
+        if p := byID[s.ParentID]; p != "" && p != k { parent[k] = p }
+    }
+    t.mu.Lock()
+    t.canon, t.parent, t.labels, t.display = canon, parent, labels, display
+    t.mu.Unlock()
+}
+
+func (t *Taxonomy) canonical(skill string) string {
+    k := skillKey(skill)
+    if t == nil { return k }
+    if c, ok := t.canon[k]; ok { return c }
+    return k
+}
+
+// Canonical returns the canonical name of a skill, or the cleaned input if the skill
+// is not in the taxonomy.
+func (t *Taxonomy) Canonical(skill string) string {
+    if t == nil { t = &Taxonomy{} }
+    t.mu.RLock()
+    defer t.mu.RUnlock()
+    return t.canonical(skill)
+}
+
+// Normalize maps every skill to its canonical name and drops duplicates and blanks.
+func (t *Taxonomy) Normalize(list []string) []string {
+    if t == nil { t = &Taxonomy{} }
+    t.mu.RLock()
+    defer t.mu.RUnlock()
+    seen := map[string]bool{}
+    var out []string
+    for _, s := range list {
+        c := t.canonical(s)
+        if c != "" && !seen[c] { seen[c] = true; out = append(out, c) }
+    }
+    return out
+}
+
+// ancestors lists the broader skills of k, nearest first.
+func (t *Taxonomy) ancestors(k string) []string {
+    var out []string
+    for p := t.parent[k]; p != "" && len(out) < 16; p = t.parent[p] { out = append(out, p) }
+    return out
+}
+
+// Credit is how far the skill have satisfies the requirement want: 1 for the same
+// skill, half per level when have is narrower (react for frontend), and a quarter
+// when have is the direct parent of want.
+func (t *Taxonomy) Credit(have, want string) float64 {
+    if t == nil { t = &Taxonomy{} }
+    t.mu.RLock()
+    defer t.mu.RUnlock()
+    h, w := t.canonical(have), t.canonical(want)
+    if h == "" || w == "" { return 0 }
+    if h == w { return 1 }
+    c := 1.0
+    for _, a := range t.ancestors(h) {
+        c /= 2
+        if a == w { return c }
+    }
+    if t.parent[w] == h { return 0.25 }
+    return 0
+}
+
+// Weights of the words Expand returns. Aliases stay below 1 so that a skill with many
+// aliases does not count as many skills.
+const (
+    aliasWeight  = 0.9
+    parentWeight = 0.5
+)
+
+// Expand returns the words a skill may appear as in free text: its canonical name with
+// weight 1, its aliases and those of its parent with lower weights.
+func (t *Taxonomy) Expand(skill string) map[string]float64 {
+    if t == nil { t = &Taxonomy{} }
+    t.mu.RLock()
+    defer t.mu.RUnlock()
+    k := t.canonical(skill)
+    out := map[string]float64{k: 1}
+    for _, l := range t.labels[k] { if out[l] == 0 { out[l] = aliasWeight } }
+    if p := t.parent[k]; p != "" {
+        for _, l := range append([]string{p}, t.labels[p]...) { if out[l] == 0 { out[l] = parentWeight } }
+    }
+    return out
+}
+
+type SkillSuggestion struct {
+    Skill   string `json:"skill"`             // canonical name to store
+    Label   string `json:"label"`             // name as entered by the admin
+    Matched string `json:"matched,omitempty"` // the alias that matched, if not the name
+    Parent  string `json:"parent,omitempty"`
+}
+
+// Suggest finds skills whose name or an alias contains q, prefix matches first.
+func (t *Taxonomy) Suggest(q string, limit int) []SkillSuggestion {
+    if t == nil { t = &Taxonomy{} }
+    q = skillKey(q)
+    if limit <= 0 || limit > maxSuggestions { limit = 10 }
+    t.mu.RLock()
+    defer t.mu.RUnlock()
+    type hit struct { s SkillSuggestion; prefix bool }
+    best := map[string]hit{}
+    for k, labels := range t.labels {
+        for _, l := range labels {
+            lk := skillKey(l)
+            if q != "" && !strings.Contains(lk, q) { continue }
+            h := hit{s: SkillSuggestion{Skill: k, Label: t.display[k], Parent: t.display[t.parent[k]]}, prefix: strings.HasPrefix(lk, q)}
+            if lk != k { h.s.Matched = l }
+            if old, ok := best[k]; !ok || (h.prefix && !old.prefix) || (h.prefix == old.prefix && h.s.Matched == "") { best[k] = h }
+        }
+    }
+    hits := make([]hit, 0, len(best))
+    for _, h := range best { hits = append(hits, h) }
+    sort.Slice(hits, func(i, j int) bool {
+        if hits[i].prefix != hits[j].prefix { return hits[i].prefix }
+        return hits[i].s.Skill < hits[j].s.Skill
+    })
+    out := []SkillSuggestion{}
+    for i := 0; i < len(hits) && i < limit; i++ { out = append(out, hits[i].s) }
+    return out
+}
+
+// UseTaxonomy installs the taxonomy that saving users and projects and the matchers
+// resolve skills with, usually the one kept current by a SkillService.
+func (s *Service) UseTaxonomy(t *Taxonomy) { s.taxonomy = t }
+
+func (s *Service) Taxonomy() *Taxonomy { return s.taxonomy }
+
+// canonicalSkills cleans a skill list and rewrites aliases to canonical names.
+func (s *Service) canonicalSkills(list []string) []string { return s.taxonomy.Normalize(normalize(list)) }
+
+// SkillService lets admins maintain the skill taxonomy.
+type SkillService struct {
+    svc    *Service
+    skills repository.SkillRepo
+    tax    *Taxonomy
+
+    mu        sync.Mutex
+    rewriting bool // a background rewrite is running
+    again     bool // the taxonomy changed during it
+    rewrites  sync.WaitGroup
+}
+
+// NewSkillService loads the skills into the service's taxonomy, installing one if the
+// service has none yet.
+func NewSkillService(s *Service, r repository.SkillRepo) *SkillService {
+    tax := s.Taxonomy()
+    if tax == nil { tax = NewTaxonomy(nil); s.UseTaxonomy(tax) }
+    sk := &SkillService{svc: s, skills: r, tax: tax}
+    sk.reload()
+    return sk
+}
+
+func (s *SkillService) Taxonomy() *Taxonomy { return s.tax }
+
+func (s *SkillService) List() []*domain.Skill { return s.skills.ListSkills() }
+
+func (s *SkillService) Suggest(q string, limit int) []SkillSuggestion { return s.tax.Suggest(q, limit) }
+
+// check cleans sk and rejects names or aliases used by another skill and parents that
+// would form a cycle.
+func (s *SkillService) check(sk *domain.Skill) error {
+    sk.Name = strings.Join(strings.Fields(sk.Name), " ")
+    if sk.Name == "" { return errors.New("缺少技能名称") }
+    if utf8.RuneCountInString(sk.Name) > maxSkillNameLen { return fmt.Errorf("技能名称不能超过%d字", maxSkillNameLen) }
+    seen := map[string]bool{skillKey(sk.Name): true}
+    var aliases []string
+    for _, a := range sk.Aliases {
+        a = strings.Join(strings.Fields(a), " ")
+        if a == "" || seen[skillKey(a)] { continue }
+        if utf8.RuneCountInString(a) > maxSkillNameLen { return fmt.Errorf("别名不能超过%d字", maxSkillNameLen) }
+        seen[skillKey(a)] = true
+        aliases = append(aliases, a)
+    }
+    if len(aliases) > maxSkillAliases { return fmt.Errorf("每个技能最多%d个别名", maxSkillAliases) }
+    sk.Aliases = aliases
+    all := s.skills.ListSkills()
+    byID := map[int64]*domain.Skill{}
+    for _, o := range all {
+        byID[o.ID] = o
+        if o.ID == sk.ID { continue }
+        for _, l := range append([]string{o.Name}, o.Aliases...) {
+            if seen[skillKey(l)] { return fmt.Errorf("「%s」已被技能「%s」使用", l, o.Name) }
+        }
+    }
+    for p, n := sk.ParentID, 0; p != 0; n++ {
+        parent := byID[p]
+        if parent == nil { return errors.New("上级技能不存在") }
+        if parent.ID == sk.ID || n > len(all) { return errors.New("上级技能不能是自身或其下级") }
+        p = parent.ParentID
+    }
+    return nil
+}
+
+func (s *SkillService) reload() { s.tax.Load(s.skills.ListSkills()) }
+
+// ReloadLoop reloads the taxonomy from the database so that edits made on another
+// instance reach this one.
+func (s *SkillService) ReloadLoop(ctx context.Context, interval time.Duration) {
+    t := time.NewTicker(interval)
+    defer t.Stop()
+    for {
+        select {
+        case <-ctx.Done(): return
+        case <-t.C: s.reload()
+        }
+    }
+}
+
+// recanonicalize rewrites the skills saved on users and projects under their current
+// canonical names, e.g. after a skill was renamed or got an alias that users had
+// already entered. Two skills that became one keep the details of the first. Only the
+// skill columns are written, and only while they are unchanged; a row edited in the
+// meantime was saved under the new taxonomy already.
+func (s *SkillService) recanonicalize() error {
+    for _, u := range s.svc.repo.ListUsers() {
+        skills := s.tax.Normalize(u.Skills)
+        prof, err := cleanProficiency(s.tax, skills, u.Proficiency)
+        if err != nil { return err }
+        if slices.Equal(skills, u.Skills) && maps.Equal(prof, u.Proficiency) { continue }
+        if _, err := s.skills.RenameUserSkills(u.ID, u.Skills, skills, u.Proficiency, prof); err != nil { return err }
+    }
+    for _, p := range s.svc.repo.ListProjects() {
+        reqs, details := s.tax.Normalize(p.Requirements), []domain.Requirement(nil)
+        seen := map[string]bool{}
+        for _, r := range p.RequirementDetails {
+            r.Skill = s.tax.Canonical(r.Skill)
+            if seen[r.Skill] { continue }
+            seen[r.Skill] = true
+            details = append(details, r)
+        }
+        if slices.Equal(reqs, p.Requirements) && slices.Equal(details, p.RequirementDetails) { continue }
+        ok, err := s.skills.RenameProjectSkills(p.ID, p.Requirements, reqs, p.RequirementDetails, details)
+        if err != nil { return err }
+        if !ok { continue }
+        next := *p
+        next.Requirements, next.RequirementDetails = reqs, details
+        s.svc.projectChanged(&next)
+    }
+    return nil
+}
+
+// changed reloads the taxonomy after an edit and rewrites the saved skills it affects
+// in the background; an edit made during a rewrite runs it once more afterwards.
+func (s *SkillService) changed() {
+    s.reload()
+    s.mu.Lock(); defer s.mu.Unlock()
+    if s.rewriting { s.again = true; return }
+    s.rewriting = true
+    s.rewrites.Add(1)
+    go s.rewrite()
+}
+
+func (s *SkillService) rewrite() {
+    defer s.rewrites.Done()
+    for {
+        if err := s.recanonicalize(); err != nil { log.Printf("rewrite saved skills: %v", err) }
+        s.mu.Lock()
+        if !s.again { s.rewriting = false; s.mu.Unlock(); return }
+        s.again = false
+        s.mu.Unlock()
+    }
+}
+
+func (s *SkillService) Create(sk *domain.Skill) (*domain.Skill, error) {
+    sk.ID = 0
+    if err := s.check(sk); err != nil { return nil, err }
+    out, err := s.skills.AddSkill(sk)
+    if err != nil { return nil, err }
+    s.changed()
+    return out, nil
+}
+
+func (s *SkillService) Update(sk *domain.Skill) (*domain.Skill, error) {
+    cur := s.skills.GetSkill(sk.ID)
+    if cur == nil { return nil, errors.New("技能不存在") }
+    // a renamed skill keeps its old name as an alias so that saved skills still resolve
+    if skillKey(cur.Name) != skillKey(sk.Name) && !contains(sk.Aliases, cur.Name) { sk.Aliases = append(sk.Aliases, cur.Name) }
+    if err := s.check(sk); err != nil { return nil, err }
+    sk.CreatedAt = cur.CreatedAt
+    if err := s.skills.UpdateSkill(sk); err != nil { return nil, err }
+    s.changed()
+    return sk, nil
+}
+
+// Delete removes a skill; its children move up to its parent. Skills already saved
+// under its name are left as they are.
+func (s *SkillService) Delete(id int64) error {
+    if s.skills.GetSkill(id) == nil { return errors.New("技能不存在") }
+    if err := s.skills.DeleteSkill(id); err != nil { return err }
+    s.reload()
+    return nil
+}
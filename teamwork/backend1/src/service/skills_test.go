@@ -0,0 +1,170 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "maps"
+    "slices"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+// memSkills is an in-memory skill taxonomy; the lock lets a test edit it as another
+// instance would while a reload loop reads it. Renames go to the users and projects of
+// repo, after beforeRename if set.
+type memSkills struct {
+    repository.SkillRepo
+    mu           sync.Mutex
+    skills       []*domain.Skill
+    nextID       int64
+    repo         *memRepo
+    beforeRename func()
+}
+
+func (m *memSkills) ListSkills() []*domain.Skill {
+    m.mu.Lock(); defer m.mu.Unlock()
+    return append([]*domain.Skill(nil), m.skills...)
+}
+
+func (m *memSkills) GetSkill(id int64) *domain.Skill {
+    m.mu.Lock(); defer m.mu.Unlock()
+    for _, s := range m.skills { if s.ID == id { cp := *s; return &cp } }
+    return nil
+}
+
+func (m *memSkills) AddSkill(s *domain.Skill) (*domain.Skill, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    m.nextID++
+    s.ID = m.nextID
+    m.skills = append(m.skills, s)
+    return s, nil
+}
+
+func (m *memSkills) UpdateSkill(s *domain.Skill) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    for i, o := range m.skills { if o.ID == s.ID { m.skills[i] = s; return nil } }
+    return errors.New("not found")
+}
+
+func (m *memSkills) RenameUserSkills(userID int64, old, skills []string, oldProf, prof map[string]int) (bool, error) {
+    if m.beforeRename != nil { m.beforeRename() }
+    m.repo.mu.Lock(); defer m.repo.mu.Unlock()
+    u := m.repo.users[userID]
+    if u == nil || !slices.Equal(u.Skills, old) || !maps.Equal(u.Proficiency, oldProf) { return false, nil }
+    cp := *u
+    cp.Skills, cp.Proficiency = skills, prof
+    m.repo.users[userID] = &cp
+    return true, nil
+}
+
+func (m *memSkills) RenameProjectSkills(projectID int64, old, reqs []string, oldDetails, details []domain.Requirement) (bool, error) {
+    if m.beforeRename != nil { m.beforeRename() }
+    m.repo.mu.Lock(); defer m.repo.mu.Unlock()
+    p := m.repo.projects[projectID]
+    if p == nil || !slices.Equal(p.Requirements, old) || !slices.Equal(p.RequirementDetails, oldDetails) { return false, nil }
+    cp := *p
+    cp.Requirements, cp.RequirementDetails = reqs, details
+    m.repo.projects[projectID] = &cp
+    return true, nil
+}
+
+func TestNewAliasRewritesSavedSkills(t *testing.T) {
+    repo := newMemRepo()
+    svc := &Service{repo: repo}
+    idx := &memIndexer{}
+    svc.UseProjectIndexer(idx)
+    stu, _ := repo.AddUser(&domain.User{Role: domain.RoleStudent, Skills: []string{"js", "go"}, Proficiency: map[string]int{"js": 4}})
+    p, _ := repo.AddProject(&domain.Project{Requirements: []string{"js", "go"}, RequirementDetails: []domain.Requirement{{Skill: "js", Mandatory: true}, {Skill: "go"}}})
+    other, _ := repo.AddProject(&domain.Project{Requirements: []string{"rust"}})
+    skills := NewSkillService(svc, &memSkills{repo: repo})
+
+    if _, err := skills.Create(&domain.Skill{Name: "JavaScript", Aliases: []string{"js"}}); err != nil { t.Fatal(err) }
+    skills.rewrites.Wait()
+    u := repo.GetUser(stu.ID)
+    if len(u.Skills) != 2 || u.Skills[0] != "javascript" || u.Proficiency["javascript"] != 4 || len(u.Proficiency) != 1 { t.Errorf("user = %v %v", u.Skills, u.Proficiency) }
+    got := repo.GetProject(p.ID)
+    if got.Requirements[0] != "javascript" || got.RequirementDetails[0].Skill != "javascript" || !got.RequirementDetails[0].Mandatory { t.Errorf("project = %v %+v", got.Requirements, got.RequirementDetails) }
+    if len(idx.changed) != 1 || idx.changed[0] != p.ID { t.Errorf("indexed %v, want only project %d and not %d", idx.changed, p.ID, other.ID) }
+
+    // a rename keeps the old name as an alias and moves the saved skills along
+    sk := skills.List()[0]
+    if _, err := skills.Update(&domain.Skill{ID: sk.ID, Name: "ECMAScript", Aliases: sk.Aliases}); err != nil { t.Fatal(err) }
+    skills.rewrites.Wait()
+    if u := repo.GetUser(stu.ID); u.Skills[0] != "ecmascript" || u.Proficiency["ecmascript"] != 4 { t.Errorf("after rename user = %v %v", u.Skills, u.Proficiency) }
+    if got := repo.GetProject(p.ID); got.RequirementDetails[0].Skill != "ecmascript" { t.Errorf("after rename project = %+v", got.RequirementDetails) }
+}
+
+func TestMergedSkillsKeepTheFirstRequirement(t *testing.T) {
+    repo := newMemRepo()
+    svc := &Service{repo: repo}
+    p, _ := repo.AddProject(&domain.Project{Requirements: []string{"golang", "go"}, RequirementDetails: []domain.Requirement{{Skill: "golang", Weight: 3}, {Skill: "go", Mandatory: true}}})
+    skills := NewSkillService(svc, &memSkills{repo: repo})
+    if _, err := skills.Create(&domain.Skill{Name: "go", Aliases: []string{"golang"}}); err != nil { t.Fatal(err) }
+    skills.rewrites.Wait()
+    got := repo.GetProject(p.ID)
+    if len(got.Requirements) != 1 || len(got.RequirementDetails) != 1 || got.RequirementDetails[0].Weight != 3 { t.Errorf("project = %v %+v", got.Requirements, got.RequirementDetails) }
+}
+
+func TestMatchersUseTheServiceTaxonomy(t *testing.T) {
+    student := &domain.User{Skills: []string{"golang"}}
+    project := &domain.Project{ID: 1, Requirements: []string{"go"}}
+    score := func(svc *Service) float64 {
+        reg, err := NewMatcherRegistry(svc, MatcherOptions{Default: MatcherSkills})
+        if err != nil { t.Fatal(err) }
+        m, err := reg.Get(MatcherSkills)
+        if err != nil { t.Fatal(err) }
+        return m.Match(student, []*domain.Project{project})[0].Score
+    }
+    plain := &Service{repo: newMemRepo()}
+    aware := &Service{repo: newMemRepo()}
+    aware.UseTaxonomy(NewTaxonomy([]*domain.Skill{{ID: 1, Name: "go", Aliases: []string{"golang"}}}))
+    if s := score(plain); s != 0 { t.Errorf("without taxonomy score = %v, want 0", s) }
+    if s := score(aware); s != 1 { t.Errorf("with taxonomy score = %v, want 1", s) }
+
+    llm := NewLLMMatcher("", "key", "")
+    reg, err := NewMatcherRegistry(aware, MatcherOptions{Default: MatcherSkills})
+    if err != nil { t.Fatal(err) }
+    reg.Register(MatcherLLM, llm)
+    if llm.tax != aware.Taxonomy() { t.Error("registered LLM matcher did not get the taxonomy") }
+}
+
+func TestReloadLoopPicksUpOtherInstances(t *testing.T) {
+    store := &memSkills{}
+    skills := NewSkillService(&Service{repo: newMemRepo()}, store)
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    go skills.ReloadLoop(ctx, time.Millisecond)
+
+    // another instance adds the skill straight to the database
+    _, _ = store.AddSkill(&domain.Skill{Name: "kubernetes", Aliases: []string{"k8s"}})
+    deadline := time.Now().Add(2 * time.Second)
+    for skills.Taxonomy().Canonical("k8s") != "kubernetes" {
+        if time.Now().After(deadline) { t.Fatal("taxonomy was not reloaded") }
+        time.Sleep(time.Millisecond)
+    }
+}
+
+func TestRewriteKeepsConcurrentEdits(t *testing.T) {
+    repo := newMemRepo()
+    renamed, _ := repo.AddUser(&domain.User{Name: "old name", Role: domain.RoleStudent, Skills: []string{"js"}})
+    edited, _ := repo.AddUser(&domain.User{Role: domain.RoleStudent, Skills: []string{"js"}})
+    store := &memSkills{repo: repo}
+    store.beforeRename = func() {
+        store.beforeRename = nil
+        // while the rewrite runs, one user renames themselves and the other edits their skills
+        r, e := *repo.GetUser(renamed.ID), *repo.GetUser(edited.ID)
+        r.Name, e.Skills = "new name", []string{"rust"}
+        repo.UpdateUser(&r)
+        repo.UpdateUser(&e)
+    }
+    skills := NewSkillService(&Service{repo: repo}, store)
+    if _, err := skills.Create(&domain.Skill{Name: "javascript", Aliases: []string{"js"}}); err != nil { t.Fatal(err) }
+    skills.rewrites.Wait()
+
+    if u := repo.GetUser(renamed.ID); u.Name != "new name" || !slices.Equal(u.Skills, []string{"javascript"}) { t.Errorf("renamed user = %q %v", u.Name, u.Skills) }
+    if u := repo.GetUser(edited.ID); !slices.Equal(u.Skills, []string{"rust"}) { t.Errorf("edited skills overwritten: %v", u.Skills) }
+}
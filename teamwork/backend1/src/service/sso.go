@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/auth"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+    ssoProvider = "oidc"
+    ssoStateTTL = 10 * time.Minute
+)
+
+// SSOOptions maps IdP claims onto local users.
+type SSOOptions struct {
+    GroupsClaim string
+    RoleMapping map[string]domain.Role
+    DefaultRole domain.Role
+    FrontendURL string // the browser is sent here with the tokens after the callback
+}
+
+// ErrSSOState means the callback does not belong to a login this browser started.
+var ErrSSOState = errors.New("登录请求无效或已过期")
+
+// SSOService logs users in through the configured OpenID Connect provider. Users are
+// created on first login; local password accounts keep working and get linked when the
+// IdP vouches for the same email. The local 2FA policy applies to SSO logins as well.
+type SSOService struct {
+    provider *auth.OIDCProvider
+    sso      repository.SSORepo
+    users    *externalUsers
+    mfa      *TwoFactorService
+    opts     SSOOptions
+}
+
+func NewSSOService(s *Service, p *auth.OIDCProvider, sso repository.SSORepo, accounts repository.AccountRepo, ts *TokenService, mfa *TwoFactorService, opts SSOOptions) *SSOService {
+    if opts.GroupsClaim == "" { opts.GroupsClaim = "groups" }
+    if opts.DefaultRole == "" { opts.DefaultRole = domain.RoleStudent }
+    return &SSOService{provider: p, sso: sso, users: &externalUsers{svc: s, links: sso, accounts: accounts, tokens: ts, linkByEmail: true}, mfa: mfa, opts: opts}
+}
+
+// StateTTL is how long a started login may take; the browser binding cookie lives as long.
+func (s *SSOService) StateTTL() time.Duration { return ssoStateTTL }
+
+func (s *SSOService) Enabled() bool { return s.provider != nil }
+
+func (s *SSOService) FrontendURL() string { return s.opts.FrontendURL }
+
+// Begin returns the IdP authorization URL to redirect the browser to, and the state the
+// caller must bind to the browser so that the callback can be checked against it.
+func (s *SSOService) Begin(ctx context.Context) (authURL, state string, err error) {
+    if !s.Enabled() { return "", "", errors.New("未启用单点登录") }
+    state, nonce := auth.NewTokenID(), auth.NewTokenID()
+    verifier, challenge := auth.NewPKCEVerifier()
+    if err := s.sso.SaveLoginState(&domain.SSOLoginState{State: state, Verifier: verifier, Nonce: nonce, ExpiresAt: time.Now().Add(ssoStateTTL)}); err != nil { return "", "", err }
+    authURL, err = s.provider.AuthURL(ctx, state, nonce, challenge)
+    return authURL, state, err
+}
+
+// Callback finishes a login. browserState is the state bound to the browser by Begin; a
+// callback arriving in a browser that did not start the login is rejected, so nobody can
+// be logged in to someone else's account by following a crafted link. The result is a
+// token pair or, under the 2FA policy, a challenge for the second step.
+func (s *SSOService) Callback(ctx context.Context, state, browserState, code string) (*LoginResult, *domain.User, error) {
+    if !s.Enabled() { return nil, nil, errors.New("未启用单点登录") }
+    if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 { return nil, nil, ErrSSOState }
+    st := s.sso.TakeLoginState(state)
+    if st == nil || time.Now().After(st.ExpiresAt) { return nil, nil, ErrSSOState }
+    claims, err := s.provider.Exchange(ctx, code, st.Verifier, st.Nonce)
+    if err != nil { return nil, nil, err }
+    u, err := s.users.resolve(s.identity(claims))
+    if err != nil { return nil, nil, err }
+    res, err := s.mfa.BeginLogin(u)
+    if err != nil { return nil, nil, err }
+    return res, u, nil
+}
+
+func (s *SSOService) identity(claims jwt.MapClaims) ExternalIdentity {
+    id := ExternalIdentity{Provider: ssoProvider}
+    id.Subject, _ = claims["sub"].(string)
+    id.Email, _ = claims["email"].(string)
+    id.Name, _ = claims["name"].(string)
+    id.EmailVerified, _ = claims["email_verified"].(bool)
+    var groups []string
+    switch g := claims[s.opts.GroupsClaim].(type) {
+    case string:
+        groups = []string{g}
+    case []any:
+        for _, v := range g { if str, ok := v.(string); ok { groups = append(groups, str) } }
+    }
+    id.Role, id.RoleMapped = mapGroups(groups, s.opts.RoleMapping, s.opts.DefaultRole)
+    return id
+}
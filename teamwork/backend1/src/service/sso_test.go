@@ -0,0 +1,101 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "testing"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/auth"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/oidcmock"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+type memSSO struct {
+    repository.SSORepo
+    states map[string]*domain.SSOLoginState
+    links  map[string]*domain.ExternalIdentity
+}
+
+func newMemSSO() *memSSO {
+    return &memSSO{states: map[string]*domain.SSOLoginState{}, links: map[string]*domain.ExternalIdentity{}}
+}
+
+func (m *memSSO) SaveLoginState(s *domain.SSOLoginState) error { m.states[s.State] = s; return nil }
+
+func (m *memSSO) TakeLoginState(state string) *domain.SSOLoginState {
+    s := m.states[state]
+    delete(m.states, state)
+    return s
+}
+
+func (m *memSSO) GetExternalIdentity(provider, subject string) *domain.ExternalIdentity {
+    return m.links[provider+"|"+subject]
+}
+
+func (m *memSSO) AddExternalIdentity(e *domain.ExternalIdentity) error {
+    m.links[e.Provider+"|"+e.Subject] = e
+    return nil
+}
+
+type ssoTest struct {
+    sso *SSOService
+    mfa *TwoFactorService
+}
+
+func newSSOTest(t *testing.T) *ssoTest {
+    useTestKeys(t)
+    idp := oidcmock.New(oidcmock.User{Subject: "s1", Email: "a@example.edu", Name: "A"})
+    srv := httptest.NewServer(idp)
+    t.Cleanup(srv.Close)
+    idp.SetIssuer(srv.URL)
+    p := auth.NewOIDCProvider(auth.OIDCConfig{Issuer: srv.URL, ClientID: "app", RedirectURL: "http://app.test/api/auth/oidc/callback"})
+    svc := &Service{repo: newMemRepo()}
+    ts := NewTokenService(svc, newMemTokens())
+    mfa := NewTwoFactorService(svc, newMemTwoFactor(), ts, nil)
+    return &ssoTest{sso: NewSSOService(svc, p, newMemSSO(), &memAccounts{users: svc.repo.(*memRepo)}, ts, mfa, SSOOptions{}), mfa: mfa}
+}
+
+// authorize runs the browser's round trip through the IdP and returns the callback query.
+func (st *ssoTest) authorize(t *testing.T) (state string, q url.Values) {
+    authURL, state, err := st.sso.Begin(context.Background())
+    if err != nil { t.Fatal(err) }
+    client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+    resp, err := client.Get(authURL)
+    if err != nil { t.Fatal(err) }
+    resp.Body.Close()
+    back, err := url.Parse(resp.Header.Get("Location"))
+    if err != nil { t.Fatal(err) }
+    return state, back.Query()
+}
+
+func TestSSOCallbackIssuesTokens(t *testing.T) {
+    st := newSSOTest(t)
+    state, q := st.authorize(t)
+    res, u, err := st.sso.Callback(context.Background(), q.Get("state"), state, q.Get("code"))
+    if err != nil { t.Fatal(err) }
+    if res.TokenPair == nil || u.Email != "a@example.edu" { t.Fatalf("unexpected login result %+v for %+v", res, u) }
+}
+
+func TestSSOCallbackRejectsOtherBrowser(t *testing.T) {
+    st := newSSOTest(t)
+    _, q := st.authorize(t)
+    // a link carrying someone else's state and code, opened without the binding cookie
+    for _, browserState := range []string{"", "other"} {
+        if _, _, err := st.sso.Callback(context.Background(), q.Get("state"), browserState, q.Get("code")); !errors.Is(err, ErrSSOState) {
+            t.Errorf("browser state %q: got %v, want ErrSSOState", browserState, err)
+        }
+    }
+}
+
+func TestSSOCallbackAppliesTwoFactorPolicy(t *testing.T) {
+    st := newSSOTest(t)
+    if err := st.mfa.SetPolicy(domain.RoleStudent, true); err != nil { t.Fatal(err) }
+    state, q := st.authorize(t)
+    res, _, err := st.sso.Callback(context.Background(), q.Get("state"), state, q.Get("code"))
+    if err != nil { t.Fatal(err) }
+    if res.TokenPair != nil || res.MFA != "enroll" || res.ChallengeToken == "" { t.Fatalf("SSO login skipped the 2FA policy: %+v", res) }
+}
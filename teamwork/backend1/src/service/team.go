@@ -0,0 +1,211 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/mailer"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+const maxTeamSize = 8
+
+// openStatuses are the application statuses that still tie a team together.
+var openStatuses = map[string]bool{domain.StatusSubmitted: true, domain.StatusInterview: true, domain.StatusWaitlisted: true, domain.StatusApproved: true}
+
+// TeamService lets students form teams that apply to projects together.
+type TeamService struct {
+    svc    *Service
+    teams  repository.TeamRepo
+    mailer mailer.Mailer
+}
+
+func NewTeamService(s *Service, teams repository.TeamRepo, m mailer.Mailer) *TeamService {
+    s.UseTeams(teams)
+    return &TeamService{svc: s, teams: teams, mailer: m}
+}
+
+// UseTeams lets the service find the team applications a student is a member of.
+func (s *Service) UseTeams(r repository.TeamRepo) { s.teams = r }
+
+type TeamMemberView struct {
+    *domain.TeamMember
+    User *domain.User `json:"user"`
+}
+
+type TeamView struct {
+    *domain.Team
+    Members      []TeamMemberView      `json:"members"`
+    Skills       []string              `json:"skills"` // combined skills of the active members
+    Applications []*domain.Application `json:"applications"`
+}
+
+func (t *TeamService) view(team *domain.Team) *TeamView {
+    v := &TeamView{Team: team, Applications: t.teams.ListTeamApplications(team.ID)}
+    var active []*domain.User
+    for _, m := range t.teams.ListMembers(team.ID) {
+        u := t.svc.repo.GetUser(m.UserID)
+        v.Members = append(v.Members, TeamMemberView{TeamMember: m, User: u})
+        if u != nil && m.Status == domain.TeamMemberActive { active = append(active, u) }
+    }
+    v.Skills = combinedSkills(active)
+    return v
+}
+
+// IsMember reports whether the user is an active member or has a pending invitation.
+func (t *TeamService) IsMember(teamID, userID int64) bool { return t.teams.GetMember(teamID, userID) != nil }
+
+func (t *TeamService) Get(id int64) (*TeamView, error) {
+    team := t.teams.GetTeam(id)
+    if team == nil { return nil, errors.New("团队不存在") }
+    return t.view(team), nil
+}
+
+// Mine lists the teams the user belongs to or is invited to.
+func (t *TeamService) Mine(userID int64) []*TeamView {
+    var out []*TeamView
+    for _, m := range t.teams.ListMemberships(userID) {
+        if team := t.teams.GetTeam(m.TeamID); team != nil { out = append(out, t.view(team)) }
+    }
+    return out
+}
+
+func (t *TeamService) Create(leader *domain.User, name string) (*TeamView, error) {
+    name = strings.TrimSpace(name)
+    if name == "" { return nil, errors.New("缺少团队名称") }
+    team, err := t.teams.AddTeam(&domain.Team{Name: name, LeaderID: leader.ID})
+    if err != nil { return nil, err }
+    return t.view(team), nil
+}
+
+// leading loads a team the user leads.
+func (t *TeamService) leading(userID, teamID int64) (*domain.Team, error) {
+    team := t.teams.GetTeam(teamID)
+    if team == nil { return nil, errors.New("团队不存在") }
+    if team.LeaderID != userID { return nil, errors.New("只有队长可以执行该操作") }
+    return team, nil
+}
+
+// locked reports whether the team has an application in progress; its membership is
+// then frozen so the application keeps describing the same people.
+func (t *TeamService) locked(teamID int64) bool {
+    for _, a := range t.teams.ListTeamApplications(teamID) { if openStatuses[a.Status] { return true } }
+    return false
+}
+
+var errTeamLocked = errors.New("团队有进行中的申请，请先撤回后再调整成员")
+
+func (t *TeamService) Invite(leader *domain.User, teamID int64, email string) error {
+    team, err := t.leading(leader.ID, teamID)
+    if err != nil { return err }
+    if t.locked(teamID) { return errTeamLocked }
+    u := t.svc.repo.GetUserByEmail(strings.TrimSpace(email))
+    if u == nil || u.Role != domain.RoleStudent { return errors.New("学生不存在") }
+    if t.teams.GetMember(teamID, u.ID) != nil { return errors.New("该学生已在团队中或已被邀请") }
+    if len(t.teams.ListMembers(teamID)) >= maxTeamSize { return fmt.Errorf("团队最多%d人", maxTeamSize) }
+    if err := t.teams.AddMember(&domain.TeamMember{TeamID: teamID, UserID: u.ID, Status: domain.TeamMemberInvited, InvitedBy: leader.ID}); err != nil { return err }
+    sendAsync(t.mailer, u.Email, "团队邀请："+team.Name, fmt.Sprintf("%s，您好：\n\n%s 邀请您加入团队「%s」，请登录系统接受或拒绝邀请。\n", u.Name, leader.Name, team.Name))
+    return nil
+}
+
+func (t *TeamService) Accept(userID, teamID int64) error {
+    m := t.teams.GetMember(teamID, userID)
+    if m == nil || m.Status != domain.TeamMemberInvited { return errors.New("没有待处理的邀请") }
+    if t.locked(teamID) { return errTeamLocked }
+    m.Status = domain.TeamMemberActive
+    return t.teams.UpdateMember(m)
+}
+
+// Leave takes the user out of the team, or declines a pending invitation. The leader
+// disbands the team instead.
+func (t *TeamService) Leave(userID, teamID int64) error {
+    m := t.teams.GetMember(teamID, userID)
+    if m == nil { return errors.New("不是该团队成员") }
+    if team := t.teams.GetTeam(teamID); team != nil && team.LeaderID == userID { return errors.New("队长不能退出团队，请先移交队长或解散团队") }
+    if m.Status == domain.TeamMemberActive && t.locked(teamID) { return errTeamLocked }
+    return t.teams.RemoveMember(teamID, userID)
+}
+
+func (t *TeamService) Remove(leader *domain.User, teamID, userID int64) error {
+    if _, err := t.leading(leader.ID, teamID); err != nil { return err }
+    if userID == leader.ID { return errors.New("不能移除队长") }
+    m := t.teams.GetMember(teamID, userID)
+    if m == nil { return errors.New("不是该团队成员") }
+    if m.Status == domain.TeamMemberActive && t.locked(teamID) { return errTeamLocked }
+    return t.teams.RemoveMember(teamID, userID)
+}
+
+// Handover makes another active member the leader. Applications name their leader as
+// the applicant, so the team must not have one in progress.
+func (t *TeamService) Handover(leader *domain.User, teamID, userID int64) error {
+    if _, err := t.leading(leader.ID, teamID); err != nil { return err }
+    if userID == leader.ID { return errors.New("已是队长") }
+    m := t.teams.GetMember(teamID, userID)
+    if m == nil || m.Status != domain.TeamMemberActive { return errors.New("只能移交给已加入的成员") }
+    if t.locked(teamID) { return errTeamLocked }
+    return t.teams.SetLeader(teamID, userID)
+}
+
+func (t *TeamService) Disband(leader *domain.User, teamID int64) error {
+    if _, err := t.leading(leader.ID, teamID); err != nil { return err }
+    if t.locked(teamID) { return errTeamLocked }
+    return t.teams.DeleteTeam(teamID)
+}
+
+// Match scores projects for the active members as a team application of theirs would
+// be scored.
+func (t *TeamService) Match(teamID int64, m domain.Matcher, topK int) ([]domain.MatchResult, error) {
+    team := t.teams.GetTeam(teamID)
+    if team == nil { return nil, errors.New("团队不存在") }
+    lead := t.svc.repo.GetUser(team.LeaderID)
+    if lead == nil { return nil, errors.New("队长不存在") }
+    var others []*domain.User
+    for _, mb := range t.teams.ListMembers(teamID) {
+        if mb.Status != domain.TeamMemberActive || mb.UserID == lead.ID { continue }
+        if u := t.svc.repo.GetUser(mb.UserID); u != nil { others = append(others, u) }
+    }
+    return t.svc.matchProjects(teamProfile(lead, others), m, topK), nil
+}
+
+func combinedSkills(users []*domain.User) []string {
+    seen := map[string]bool{}
+    var out []string
+    for _, u := range users {
+        for _, sk := range normalize(u.Skills) { if !seen[sk] { seen[sk] = true; out = append(out, sk) } }
+    }
+    return out
+}
+
+// applicant is who the matcher scores for an application: the student, or for a team
+// application the members' combined skills under the leader's identity.
+func (s *Service) applicant(a *domain.Application, lead *domain.User) *domain.User {
+    if len(a.Members) <= 1 { return lead }
+    var others []*domain.User
+    for _, id := range a.Members {
+        if id == lead.ID { continue }
+        if u := s.repo.GetUser(id); u != nil { others = append(others, u) }
+    }
+    return teamProfile(lead, others)
+}
+
+// teamProfile is the leader with the combined skills of the team, which is as
+// proficient as its best member.
+func teamProfile(lead *domain.User, others []*domain.User) *domain.User {
+    users := append([]*domain.User{lead}, others...)
+    cp := *lead
+    cp.Skills = combinedSkills(users)
+    cp.Proficiency = map[string]int{}
+    for _, u := range users {
+        for sk, lvl := range u.Proficiency { if lvl > cp.Proficiency[sk] { cp.Proficiency[sk] = lvl } }
+    }
+    return &cp
+}
+
+// studentApplications adds the team applications the student is a member of.
+func (s *Service) studentApplications(studentID int64, status string) []*domain.Application {
+    apps := s.repo.ListApplicationsByStudent(studentID, status)
+    if s.teams != nil { apps = append(apps, s.teams.ListMemberApplications(studentID, status)...) }
+    return apps
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+    "slices"
+    "testing"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+// placed mirrors the repository's check that an approval puts nobody on two projects.
+func (m *memLifecycle) placed(a *domain.Application) int64 {
+    for _, id := range roundStudents(a) {
+        for _, o := range m.repo.apps {
+            if o.ID == a.ID || (o.Status != domain.StatusApproved && o.Status != domain.StatusCompleted) || (a.TeamID == 0 && o.TeamID == 0) { continue }
+            if o.StudentID == id || containsID(o.Members, id) { return id }
+        }
+    }
+    return 0
+}
+
+func (m *memLifecycle) NextWaitlisted(projectID int64) *domain.Application {
+    m.repo.mu.Lock(); defer m.repo.mu.Unlock()
+    var next *domain.Application
+    for _, a := range m.repo.apps {
+        if a.ProjectID == projectID && a.Status == domain.StatusWaitlisted && (next == nil || a.ID < next.ID) { next = a }
+    }
+    return next
+}
+
+func (m *memLifecycle) CountTaken(projectID int64) int {
+    m.repo.mu.Lock(); defer m.repo.mu.Unlock()
+    return m.taken(projectID)
+}
+
+// memTeams is an in-memory repository.TeamRepo whose applications live in repo.
+type memTeams struct {
+    repository.TeamRepo
+    repo    *memRepo
+    teams   map[int64]*domain.Team
+    members []*domain.TeamMember
+}
+
+func (m *memTeams) AddTeam(t *domain.Team) (*domain.Team, error) {
+    t.ID = m.repo.id()
+    m.teams[t.ID] = t
+    m.members = append(m.members, &domain.TeamMember{TeamID: t.ID, UserID: t.LeaderID, Status: domain.TeamMemberActive, InvitedBy: t.LeaderID})
+    return t, nil
+}
+
+func (m *memTeams) GetTeam(id int64) *domain.Team { return m.teams[id] }
+
+func (m *memTeams) ListMembers(teamID int64) []*domain.TeamMember {
+    var out []*domain.TeamMember
+    for _, mb := range m.members { if mb.TeamID == teamID { out = append(out, mb) } }
+    return out
+}
+
+func (m *memTeams) GetMember(teamID, userID int64) *domain.TeamMember {
+    for _, mb := range m.members { if mb.TeamID == teamID && mb.UserID == userID { return mb } }
+    return nil
+}
+
+func (m *memTeams) AddMember(mb *domain.TeamMember) error { m.members = append(m.members, mb); return nil }
+
+func (m *memTeams) UpdateMember(mb *domain.TeamMember) error { return nil }
+
+func (m *memTeams) RemoveMember(teamID, userID int64) error {
+    m.members = slices.DeleteFunc(m.members, func(mb *domain.TeamMember) bool { return mb.TeamID == teamID && mb.UserID == userID })
+    return nil
+}
+
+func (m *memTeams) ListMemberships(userID int64) []*domain.TeamMember {
+    var out []*domain.TeamMember
+    for _, mb := range m.members { if mb.UserID == userID { out = append(out, mb) } }
+    return out
+}
+
+func (m *memTeams) ListTeamApplications(teamID int64) []*domain.Application {
+    var out []*domain.Application
+    for _, a := range m.repo.ListApplications() { if a.TeamID == teamID { out = append(out, a) } }
+    return out
+}
+
+func (m *memTeams) ListMemberApplications(userID int64, status string) []*domain.Application {
+    var out []*domain.Application
+    for _, a := range m.repo.ListApplications() {
+        if a.TeamID != 0 && a.StudentID != userID && containsID(a.Members, userID) && (status == "" || a.Status == status) { out = append(out, a) }
+    }
+    return out
+}
+
+func (m *memTeams) SetLeader(teamID, userID int64) error { m.teams[teamID].LeaderID = userID; return nil }
+
+type teamTest struct {
+    t    *TeamService
+    repo *memRepo
+    lead *domain.User
+    mate *domain.User
+    team *TeamView
+}
+
+// newTeamTest creates a team led by lead with mate invited but not yet joined.
+func newTeamTest(t *testing.T) *teamTest {
+    repo := newMemRepo()
+    lead, _ := repo.AddUser(&domain.User{Name: "lead", Email: "lead@example.edu", Role: domain.RoleStudent, Skills: []string{"Go"}, Proficiency: map[string]int{"go": 2}})
+    mate, _ := repo.AddUser(&domain.User{Name: "mate", Email: "mate@example.edu", Role: domain.RoleStudent, Skills: []string{"go", "React"}, Proficiency: map[string]int{"go": 4, "react": 3}})
+    s := NewTeamService(&Service{repo: repo}, &memTeams{repo: repo, teams: map[int64]*domain.Team{}}, nil)
+    team, err := s.Create(lead, "队")
+    if err != nil { t.Fatal(err) }
+    if err := s.Invite(lead, team.ID, mate.Email); err != nil { t.Fatal(err) }
+    return &teamTest{t: s, repo: repo, lead: lead, mate: mate, team: team}
+}
+
+func TestInviteAndAccept(t *testing.T) {
+    tt := newTeamTest(t)
+    if err := tt.t.Invite(tt.lead, tt.team.ID, tt.mate.Email); err == nil { t.Error("invited the same student twice") }
+    if err := tt.t.Invite(tt.mate, tt.team.ID, tt.lead.Email); err == nil { t.Error("a member who does not lead invited someone") }
+    if !tt.t.IsMember(tt.team.ID, tt.mate.ID) { t.Error("invited student is not listed") }
+    if err := tt.t.Accept(tt.mate.ID, tt.team.ID); err != nil { t.Fatal(err) }
+    if err := tt.t.Accept(tt.mate.ID, tt.team.ID); err == nil { t.Error("accepted the invitation twice") }
+    if len(tt.t.Mine(tt.mate.ID)) != 1 { t.Error("team missing from the member's teams") }
+}
+
+func TestCombinedSkillsOnlyCountActiveMembers(t *testing.T) {
+    tt := newTeamTest(t)
+    v, _ := tt.t.Get(tt.team.ID)
+    if !slices.Equal(v.Skills, []string{"go"}) { t.Errorf("before accepting: %v", v.Skills) }
+    tt.t.Accept(tt.mate.ID, tt.team.ID)
+    v, _ = tt.t.Get(tt.team.ID)
+    if !slices.Equal(v.Skills, []string{"go", "react"}) { t.Errorf("after accepting: %v", v.Skills) }
+}
+
+func TestLeave(t *testing.T) {
+    tt := newTeamTest(t)
+    if err := tt.t.Leave(tt.lead.ID, tt.team.ID); err == nil { t.Error("the leader left the team") }
+    tt.t.Accept(tt.mate.ID, tt.team.ID)
+    app := &domain.Application{ID: tt.repo.id(), StudentID: tt.lead.ID, TeamID: tt.team.ID, Members: []int64{tt.lead.ID, tt.mate.ID}, Status: domain.StatusSubmitted}
+    tt.repo.apps[app.ID] = app
+    if err := tt.t.Leave(tt.mate.ID, tt.team.ID); err != errTeamLocked { t.Errorf("left during an application: %v", err) }
+    app.Status = domain.StatusWithdrawn
+    if err := tt.t.Leave(tt.mate.ID, tt.team.ID); err != nil { t.Fatal(err) }
+    if tt.t.IsMember(tt.team.ID, tt.mate.ID) { t.Error("still a member after leaving") }
+}
+
+func TestHandover(t *testing.T) {
+    tt := newTeamTest(t)
+    if err := tt.t.Handover(tt.lead, tt.team.ID, tt.mate.ID); err == nil { t.Error("handed over to a student who has not joined") }
+    tt.t.Accept(tt.mate.ID, tt.team.ID)
+    if err := tt.t.Handover(tt.mate, tt.team.ID, tt.mate.ID); err == nil { t.Error("a member took over the team") }
+    if err := tt.t.Handover(tt.lead, tt.team.ID, tt.mate.ID); err != nil { t.Fatal(err) }
+    v, _ := tt.t.Get(tt.team.ID)
+    if v.LeaderID != tt.mate.ID { t.Fatalf("leader = %d", v.LeaderID) }
+    if err := tt.t.Leave(tt.lead.ID, tt.team.ID); err != nil { t.Errorf("former leader cannot leave: %v", err) }
+}
+
+func TestTeamMatchScoresLikeATeamApplication(t *testing.T) {
+    tt := newTeamTest(t)
+    tt.t.Accept(tt.mate.ID, tt.team.ID)
+    p, _ := tt.repo.AddProject(&domain.Project{Title: "p", Requirements: []string{"go"}, RequirementDetails: []domain.Requirement{{Skill: "go", Mandatory: true, MinLevel: 4}}})
+    m := NewSkillMatcher(nil)
+    res, err := tt.t.Match(tt.team.ID, m, 0)
+    if err != nil { t.Fatal(err) }
+    app := &domain.Application{StudentID: tt.lead.ID, ProjectID: p.ID, Members: []int64{tt.lead.ID, tt.mate.ID}}
+    want := m.Match(tt.t.svc.applicant(app, tt.lead), []*domain.Project{p})[0].Score
+    if len(res) != 1 || res[0].Score != want || want != 1 { t.Errorf("team scores %+v, its application %v", res, want) }
+}
+
+func TestMemberSeesTeamApplications(t *testing.T) {
+    tt := newTeamTest(t)
+    tt.t.Accept(tt.mate.ID, tt.team.ID)
+    app := &domain.Application{ID: tt.repo.id(), StudentID: tt.lead.ID, TeamID: tt.team.ID, Members: []int64{tt.lead.ID, tt.mate.ID}, Status: domain.StatusSubmitted}
+    tt.repo.apps[app.ID] = app
+    if got := tt.t.svc.studentApplications(tt.mate.ID, ""); len(got) != 1 || got[0] != app { t.Errorf("member sees %v", got) }
+    if got := tt.t.svc.studentApplications(tt.mate.ID, domain.StatusApproved); len(got) != 0 { t.Errorf("status filter ignored: %v", got) }
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+    "math"
+    "sort"
+    "strings"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/textsearch"
+)
+
+// bioWeight is how much the free-text profile and an application's submission count
+// relative to the skills.
+const bioWeight = 0.5
+
+// TextMatcher ranks projects by BM25 relevance of their title, description,
+// requirements and tags to the student's skills and bio. It works offline and keeps
+// its index current through ProjectChanged and ProjectRemoved.
+type TextMatcher struct {
+    index *textsearch.Index
+    tax   *Taxonomy
+}
+
+func (m *TextMatcher) WeighsRequirements() bool { return true }
+
+func NewTextMatcher(tax *Taxonomy, projects []*domain.Project) *TextMatcher {
+    m := &TextMatcher{index: textsearch.NewIndex(), tax: tax}
+    for _, p := range projects { m.ProjectChanged(p) }
+    return m
+}
+
+// projectText is the indexed text. The title and tags are repeated so they weigh more
+// than the description, each requirement by its weight, mandatory ones once more.
+func projectText(p *domain.Project) string {
+    short := p.Title + "\n" + strings.Join(p.Tags, " ")
+    var reqs []string
+    for _, r := range projectRequirements(p) {
+        n := int(math.Max(1, math.Round(2*weightOf(r))))
+        if r.Mandatory { n++ }
+        for i := 0; i < n && i < 6; i++ { reqs = append(reqs, r.Skill) }
+    }
+    return short + "\n" + short + "\n" + strings.Join(reqs, " ") + "\n" + p.Description
+}
+
+func (m *TextMatcher) ProjectChanged(p *domain.Project) { m.index.Put(p.ID, projectText(p)) }
+
+func (m *TextMatcher) ProjectRemoved(id int64) { m.index.Remove(id) }
+
+func (m *TextMatcher) Match(student *domain.User, projects []*domain.Project) []domain.MatchResult {
+    q := textsearch.Query{}
+    // aliases count like the skill itself, the broader skill a little less
+    for _, sk := range student.Skills {
+        for term, w := range m.tax.Expand(sk) { q.Add(term, w) }
+    }
+    q.Add(student.Bio, bioWeight)
+    q.Add(student.Submission, bioWeight)
+    hits := m.index.Search(q)
+    out := make([]domain.MatchResult, 0, len(projects))
+    for _, p := range projects {
+        text := projectText(p)
+        h := hits[p.ID]
+        // a project that is not indexed as given, e.g. an edit not yet seen by the
+        // index, is scored on the spot
+        if !m.index.Current(p.ID, text) { h = m.index.ScoreText(q, text) }
+        if h == nil { h = &textsearch.Hit{} }
+        ev := evaluate(m.tax, student, projectRequirements(p))
+        b := newBreakdown(textMatcherVersion, ev, component("text", 1, h.Score))
+        score, reason := round2(h.Score), textReason(m.tax, student.Skills, h)
+        if len(p.RequirementDetails) > 0 { score, reason = ev.penalize(score, reason, b) }
+        out = append(out, domain.MatchResult{Project: p, Score: score, Reason: reason, Breakdown: b})
+    }
+    sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+    return out
+}
+
+// textReason names the skills found in the project text under their name or an alias.
+func textReason(tax *Taxonomy, skills []string, h *textsearch.Hit) string {
+    var found []string
+    for _, sk := range skills {
+        if mentioned(tax, sk, h) { found = append(found, sk) }
+    }
+    switch {
+    case len(found) > 0:
+        return "项目描述中提到了：" + strings.Join(found, "、")
+    case h.Score > 0:
+        return "个人简介与项目描述有相关内容"
+    default:
+        return "项目描述中没有找到相关技能"
+    }
+}
+
+func mentioned(tax *Taxonomy, skill string, h *textsearch.Hit) bool {
+    for term, w := range tax.Expand(skill) {
+        if w < aliasWeight { continue }
+        for _, t := range textsearch.Tokenize(term) { if h.Terms[t] { return true } }
+    }
+    return false
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/auth"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+const refreshTokenTTL = 14 * 24 * time.Hour
+
+type TokenPair struct {
+    Token        string `json:"token"`
+    RefreshToken string `json:"refresh_token"`
+    ExpiresIn    int64  `json:"expires_in"`
+}
+
+// TokenService issues access/refresh token pairs and handles revocation.
+type TokenService struct {
+    svc    *Service
+    tokens repository.TokenRepo
+}
+
+func NewTokenService(s *Service, tokens repository.TokenRepo) *TokenService {
+    return &TokenService{svc: s, tokens: tokens}
+}
+
+func hashToken(raw string) string {
+    sum := sha256.Sum256([]byte(raw))
+    return hex.EncodeToString(sum[:])
+}
+
+func (t *TokenService) Issue(u *domain.User) (*TokenPair, error) {
+    return t.issue(u, auth.NewTokenID())
+}
+
+func (t *TokenService) issue(u *domain.User, family string) (*TokenPair, error) {
+    access, claims, err := auth.IssueAccessToken(u)
+    if err != nil { return nil, err }
+    raw := auth.NewTokenID() + auth.NewTokenID()
+    rt := &domain.RefreshToken{UserID: u.ID, FamilyID: family, TokenHash: hashToken(raw), ExpiresAt: time.Now().Add(refreshTokenTTL)}
+    if _, err := t.tokens.AddRefreshToken(rt); err != nil { return nil, err }
+    return &TokenPair{Token: access, RefreshToken: raw, ExpiresIn: int64(time.Until(claims.ExpiresAt.Time).Seconds())}, nil
+}
+
+// redeem spends a refresh token for rotation and returns it with its user. Presenting an
+// already rotated token is treated as theft and revokes every token of that login.
+func (t *TokenService) redeem(raw string) (*domain.RefreshToken, *domain.User, error) {
+    if raw == "" { return nil, nil, errors.New("缺少refresh_token") }
+    rt := t.tokens.GetRefreshTokenByHash(hashToken(raw))
+    if rt == nil { return nil, nil, errors.New("refresh_token无效") }
+    if rt.RevokedAt != nil {
+        _ = t.tokens.RevokeRefreshFamily(rt.FamilyID)
+        return nil, nil, errors.New("refresh_token已失效")
+    }
+    if time.Now().After(rt.ExpiresAt) { return nil, nil, errors.New("refresh_token已过期") }
+    ok, err := t.tokens.RevokeRefreshToken(rt.ID)
+    if err != nil { return nil, nil, err }
+    if !ok {
+        _ = t.tokens.RevokeRefreshFamily(rt.FamilyID)
+        return nil, nil, errors.New("refresh_token已失效")
+    }
+    u := t.svc.repo.GetUser(rt.UserID)
+    if u == nil { return nil, nil, errors.New("用户不存在") }
+    return rt, u, nil
+}
+
+// Logout revokes the current access token and, if given, the refresh token chain it
+// belongs to.
+func (t *TokenService) Logout(claims *auth.Claims, refresh string) error {
+    if claims == nil { return errors.New("未认证") }
+    if err := t.RevokeClaims(claims); err != nil { return err }
+    if refresh == "" { return nil }
+    rt := t.tokens.GetRefreshTokenByHash(hashToken(refresh))
+    if rt == nil || rt.UserID != claims.UserID { return nil }
+    return t.tokens.RevokeRefreshFamily(rt.FamilyID)
+}
+
+// RevokeClaims blacklists a single token by its jti until it expires.
+func (t *TokenService) RevokeClaims(claims *auth.Claims) error {
+    return t.tokens.RevokeAccessToken(claims.ID, claims.ExpiresAt.Time)
+}
+
+// RevokeUser invalidates every access and refresh token the user currently holds.
+// iat has whole seconds, so access tokens issued later in the same second are
+// rejected as well; the client simply logs in again.
+func (t *TokenService) RevokeUser(userID int64) error {
+    if err := t.tokens.SetUserTokenCutoff(userID, time.Now().Truncate(time.Millisecond)); err != nil { return err }
+    return t.tokens.RevokeUserRefreshTokens(userID)
+}
+
+// UpdateUserRole changes the role and forces the user to log in again so the new
+// role is reflected in fresh tokens.
+func (t *TokenService) UpdateUserRole(userID int64, role domain.Role) error {
+    if err := t.svc.UpdateUserRole(userID, role); err != nil { return err }
+    return t.RevokeUser(userID)
+}
+
+// PurgeLoop periodically drops expired refresh tokens and revocation entries until ctx
+// is done.
+func (t *TokenService) PurgeLoop(ctx context.Context, interval time.Duration) {
+    tick := time.NewTicker(interval)
+    defer tick.Stop()
+    for {
+        select {
+        case <-ctx.Done(): return
+        case <-tick.C: _ = t.tokens.PurgeExpiredTokens(time.Now())
+        }
+    }
+}
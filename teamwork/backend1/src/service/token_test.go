@@ -0,0 +1,204 @@
+package service
+
+import (
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/auth"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+// memTokens is an in-memory repository.TokenRepo.
+type memTokens struct {
+    mu      sync.Mutex
+    refresh []*domain.RefreshToken
+    revoked map[string]time.Time
+    cutoff  map[int64]time.Time
+}
+
+func newMemTokens() *memTokens { return &memTokens{revoked: map[string]time.Time{}, cutoff: map[int64]time.Time{}} }
+
+func (m *memTokens) AddRefreshToken(t *domain.RefreshToken) (*domain.RefreshToken, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    t.ID = int64(len(m.refresh) + 1)
+    m.refresh = append(m.refresh, t)
+    return t, nil
+}
+
+func (m *memTokens) GetRefreshTokenByHash(hash string) *domain.RefreshToken {
+    m.mu.Lock(); defer m.mu.Unlock()
+    for _, t := range m.refresh {
+        if t.TokenHash == hash { cp := *t; return &cp }
+    }
+    return nil
+}
+
+func (m *memTokens) revokeWhere(match func(*domain.RefreshToken) bool) int {
+    n, now := 0, time.Now()
+    for _, t := range m.refresh {
+        if t.RevokedAt == nil && match(t) { t.RevokedAt = &now; n++ }
+    }
+    return n
+}
+
+func (m *memTokens) RevokeRefreshToken(id int64) (bool, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    return m.revokeWhere(func(t *domain.RefreshToken) bool { return t.ID == id }) == 1, nil
+}
+
+func (m *memTokens) RevokeRefreshFamily(familyID string) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    m.revokeWhere(func(t *domain.RefreshToken) bool { return t.FamilyID == familyID })
+    return nil
+}
+
+func (m *memTokens) RevokeUserRefreshTokens(userID int64) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    m.revokeWhere(func(t *domain.RefreshToken) bool { return t.UserID == userID })
+    return nil
+}
+
+func (m *memTokens) RevokeAccessToken(jti string, expiresAt time.Time) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    m.revoked[jti] = expiresAt
+    return nil
+}
+
+func (m *memTokens) IsAccessTokenRevoked(jti string) bool {
+    m.mu.Lock(); defer m.mu.Unlock()
+    _, ok := m.revoked[jti]
+    return ok
+}
+
+func (m *memTokens) SetUserTokenCutoff(userID int64, at time.Time) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    m.cutoff[userID] = at
+    return nil
+}
+
+func (m *memTokens) GetUserTokenCutoff(userID int64) time.Time {
+    m.mu.Lock(); defer m.mu.Unlock()
+    return m.cutoff[userID]
+}
+
+func (m *memTokens) PurgeExpiredTokens(now time.Time) error { return nil }
+
+// memKeys keeps JWT signing keys in memory.
+type memKeys struct{ keys []*domain.SigningKey }
+
+func (m *memKeys) ListSigningKeys() []*domain.SigningKey { return m.keys }
+
+func (m *memKeys) RotateSigningKey(old string, k *domain.SigningKey, at time.Time) (bool, error) {
+    for _, c := range m.keys {
+        if c.KID == old { c.Status = domain.KeyStatusPrevious }
+    }
+    m.keys = append(m.keys, k)
+    return true, nil
+}
+
+func (m *memKeys) UpdateSigningKeyStatus(kid, status string, at time.Time) error {
+    for _, k := range m.keys {
+        if k.KID == kid { k.Status = status }
+    }
+    return nil
+}
+
+func useTestKeys(t *testing.T) {
+    t.Helper()
+    ks, err := auth.NewKeySet(&memKeys{}, auth.AlgEdDSA, 0, 0)
+    if err != nil { t.Fatal(err) }
+    auth.UseKeySet(ks)
+}
+
+// memTwoFactor keeps enrollments and policies; recovery codes are not modelled.
+type memTwoFactor struct {
+    repository.TwoFactorRepo
+    enrolled map[int64]*domain.TwoFactor
+    policies []*domain.TwoFactorPolicy
+}
+
+func newMemTwoFactor() *memTwoFactor { return &memTwoFactor{enrolled: map[int64]*domain.TwoFactor{}} }
+
+func (m *memTwoFactor) GetTwoFactor(userID int64) *domain.TwoFactor { return m.enrolled[userID] }
+
+func (m *memTwoFactor) ListTwoFactorPolicies() []*domain.TwoFactorPolicy { return m.policies }
+
+func (m *memTwoFactor) SetTwoFactorPolicy(p *domain.TwoFactorPolicy) error {
+    for _, cur := range m.policies { if cur.Role == p.Role { cur.Required = p.Required; return nil } }
+    m.policies = append(m.policies, p)
+    return nil
+}
+
+type tokenTest struct {
+    ts     *TokenService
+    mfa    *TwoFactorService
+    tf     *memTwoFactor
+    tokens *memTokens
+    user   *domain.User
+}
+
+func newTokenTest(t *testing.T) *tokenTest {
+    useTestKeys(t)
+    repo := newMemRepo()
+    u, _ := repo.AddUser(&domain.User{Name: "stu", Role: domain.RoleStudent})
+    svc := &Service{repo: repo}
+    tokens, tf := newMemTokens(), newMemTwoFactor()
+    ts := NewTokenService(svc, tokens)
+    return &tokenTest{ts: ts, mfa: NewTwoFactorService(svc, tf, ts, nil), tf: tf, tokens: tokens, user: u}
+}
+
+func (tt *tokenTest) refresh(raw string) (*TokenPair, error) {
+    res, _, err := tt.mfa.Refresh(raw)
+    if err != nil { return nil, err }
+    return res.TokenPair, nil
+}
+
+func TestRefreshRotates(t *testing.T) {
+    tt := newTokenTest(t)
+    first, err := tt.ts.Issue(tt.user)
+    if err != nil { t.Fatal(err) }
+    second, err := tt.refresh(first.RefreshToken)
+    if err != nil { t.Fatal(err) }
+    if second.RefreshToken == first.RefreshToken { t.Fatal("refresh token was not rotated") }
+    if _, err := tt.refresh(second.RefreshToken); err != nil { t.Fatalf("rotated token rejected: %v", err) }
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+    tt := newTokenTest(t)
+    first, _ := tt.ts.Issue(tt.user)
+    second, err := tt.refresh(first.RefreshToken)
+    if err != nil { t.Fatal(err) }
+    // replaying the rotated token looks like theft: the whole login is revoked
+    if _, err := tt.refresh(first.RefreshToken); err == nil { t.Fatal("reused refresh token accepted") }
+    if _, err := tt.refresh(second.RefreshToken); err == nil { t.Error("family survived a reused refresh token") }
+}
+
+func TestRevokeUser(t *testing.T) {
+    tt := newTokenTest(t)
+    pair, _ := tt.ts.Issue(tt.user)
+    if err := tt.ts.RevokeUser(tt.user.ID); err != nil { t.Fatal(err) }
+    if tt.tokens.GetUserTokenCutoff(tt.user.ID).IsZero() { t.Error("no cutoff recorded") }
+    if _, err := tt.refresh(pair.RefreshToken); err == nil { t.Error("refresh token survived RevokeUser") }
+}
+
+func TestRefreshStepsUpWhenPolicyRequires2FA(t *testing.T) {
+    tt := newTokenTest(t)
+    pair, _ := tt.ts.Issue(tt.user)
+    if err := tt.mfa.SetPolicy(domain.RoleStudent, true); err != nil { t.Fatal(err) }
+    res, _, err := tt.mfa.Refresh(pair.RefreshToken)
+    if err != nil { t.Fatal(err) }
+    if res.TokenPair != nil || res.MFA != "enroll" || res.ChallengeToken == "" { t.Fatalf("refresh without 2FA not stepped up: %+v", res) }
+    // the session does not come back by refreshing again
+    if _, err := tt.refresh(pair.RefreshToken); err == nil { t.Error("spent refresh token accepted after step-up") }
+}
+
+func TestRefreshKeepsSessionWith2FAEnabled(t *testing.T) {
+    tt := newTokenTest(t)
+    pair, _ := tt.ts.Issue(tt.user)
+    tt.tf.enrolled[tt.user.ID] = &domain.TwoFactor{UserID: tt.user.ID, Enabled: true}
+    if err := tt.mfa.SetPolicy(domain.RoleStudent, true); err != nil { t.Fatal(err) }
+    next, err := tt.refresh(pair.RefreshToken)
+    if err != nil || next == nil { t.Fatalf("enrolled user lost the session: %v", err) }
+}
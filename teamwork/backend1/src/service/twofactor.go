@@ -0,0 +1,219 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/auth"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+)
+
+const (
+    totpIssuer        = "科研实习管理系统"
+    recoveryCodeCount = 10
+)
+
+// LoginResult is either a token pair or a challenge for the second login step.
+type LoginResult struct {
+    *TokenPair
+    ChallengeToken string `json:"challenge_token,omitempty"`
+    // MFA is "totp" when a code is needed and "enroll" when 2FA must be set up first.
+    MFA string `json:"mfa,omitempty"`
+}
+
+type TwoFactorStatus struct {
+    Enabled           bool `json:"enabled"`
+    Required          bool `json:"required"`
+    RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TwoFactorService handles TOTP enrollment, recovery codes and the two-step login.
+type TwoFactorService struct {
+    svc    *Service
+    mfa    repository.TwoFactorRepo
+    tokens *TokenService
+    locks  repository.LockoutRepo
+}
+
+func NewTwoFactorService(s *Service, mfa repository.TwoFactorRepo, ts *TokenService, locks repository.LockoutRepo) *TwoFactorService {
+    return &TwoFactorService{svc: s, mfa: mfa, tokens: ts, locks: locks}
+}
+
+func (t *TwoFactorService) Required(role domain.Role) bool {
+    for _, p := range t.mfa.ListTwoFactorPolicies() { if p.Role == role { return p.Required } }
+    return false
+}
+
+func (t *TwoFactorService) enabled(userID int64) *domain.TwoFactor {
+    tf := t.mfa.GetTwoFactor(userID)
+    if tf == nil || !tf.Enabled { return nil }
+    return tf
+}
+
+// BeginLogin runs after the password was accepted.
+func (t *TwoFactorService) BeginLogin(u *domain.User) (*LoginResult, error) {
+    purpose, mfa := "", ""
+    if t.enabled(u.ID) != nil {
+        purpose, mfa = auth.PurposeMFA, "totp"
+    } else if t.Required(u.Role) {
+        purpose, mfa = auth.PurposeMFAEnroll, "enroll"
+    }
+    if purpose == "" {
+        pair, err := t.tokens.Issue(u)
+        if err != nil { return nil, err }
+        return &LoginResult{TokenPair: pair}, nil
+    }
+    ch, _, err := auth.IssueChallengeToken(u, purpose)
+    if err != nil { return nil, err }
+    return &LoginResult{ChallengeToken: ch, MFA: mfa}, nil
+}
+
+// Refresh rotates a refresh token. A user whose role has come to require 2FA without
+// them having set it up gets an enrollment challenge instead of a new session, so that
+// logins from before the policy cannot go on without it.
+func (t *TwoFactorService) Refresh(raw string) (*LoginResult, *domain.User, error) {
+    rt, u, err := t.tokens.redeem(raw)
+    if err != nil { return nil, nil, err }
+    if t.enabled(u.ID) == nil && t.Required(u.Role) {
+        // the spent token was the last of its login, so the old session ends here
+        ch, _, err := auth.IssueChallengeToken(u, auth.PurposeMFAEnroll)
+        if err != nil { return nil, nil, err }
+        return &LoginResult{ChallengeToken: ch, MFA: "enroll"}, u, nil
+    }
+    pair, err := t.tokens.issue(u, rt.FamilyID)
+    if err != nil { return nil, nil, err }
+    return &LoginResult{TokenPair: pair}, u, nil
+}
+
+// VerifyLogin completes a "totp" challenge with an authenticator or recovery code.
+func (t *TwoFactorService) VerifyLogin(cl *auth.Claims, code string) (*TokenPair, *domain.User, error) {
+    if cl == nil || cl.Purpose != auth.PurposeMFA { return nil, nil, errors.New("缺少或无效的验证令牌") }
+    u := t.svc.repo.GetUser(cl.UserID)
+    tf := t.enabled(cl.UserID)
+    if u == nil || tf == nil { return nil, nil, errors.New("缺少或无效的验证令牌") }
+    if err := t.check(tf, code, true); err != nil { return nil, nil, err }
+    // a challenge completes exactly one login
+    if err := t.tokens.RevokeClaims(cl); err != nil { return nil, nil, err }
+    pair, err := t.tokens.Issue(u)
+    if err != nil { return nil, nil, err }
+    return pair, u, nil
+}
+
+// StartEnroll creates a pending secret; it replaces any earlier unconfirmed one.
+func (t *TwoFactorService) StartEnroll(u *domain.User) (secret, otpauthURL string, err error) {
+    if t.enabled(u.ID) != nil { return "", "", errors.New("已启用两步验证") }
+    secret = auth.NewTOTPSecret()
+    if err := t.mfa.SaveTwoFactor(&domain.TwoFactor{UserID: u.ID, Secret: secret}); err != nil { return "", "", err }
+    return secret, auth.TOTPURL(totpIssuer, u.Email, secret), nil
+}
+
+// ConfirmEnroll enables 2FA once the first code matches and returns fresh recovery codes.
+func (t *TwoFactorService) ConfirmEnroll(u *domain.User, code string) ([]string, error) {
+    tf := t.mfa.GetTwoFactor(u.ID)
+    if tf == nil || tf.Enabled { return nil, errors.New("请先开始绑定") }
+    if err := t.check(tf, code, false); err != nil { return nil, err }
+    now := time.Now()
+    tf.Enabled, tf.EnabledAt = true, &now
+    step, _ := auth.ValidateTOTP(tf.Secret, code, now)
+    tf.LastStep = step
+    if err := t.mfa.SaveTwoFactor(tf); err != nil { return nil, err }
+    return t.newRecoveryCodes(u.ID)
+}
+
+// ConfirmEnrollChallenge finishes an "enroll" login challenge and issues the session.
+func (t *TwoFactorService) ConfirmEnrollChallenge(cl *auth.Claims, code string) ([]string, *TokenPair, *domain.User, error) {
+    if cl == nil || cl.Purpose != auth.PurposeMFAEnroll { return nil, nil, nil, errors.New("缺少或无效的验证令牌") }
+    u := t.svc.repo.GetUser(cl.UserID)
+    if u == nil { return nil, nil, nil, errors.New("用户不存在") }
+    codes, err := t.ConfirmEnroll(u, code)
+    if err != nil { return nil, nil, nil, err }
+    if err := t.tokens.RevokeClaims(cl); err != nil { return nil, nil, nil, err }
+    pair, err := t.tokens.Issue(u)
+    if err != nil { return nil, nil, nil, err }
+    return codes, pair, u, nil
+}
+
+func (t *TwoFactorService) Disable(u *domain.User, code string) error {
+    if t.Required(u.Role) { return errors.New("当前角色必须启用两步验证") }
+    tf := t.enabled(u.ID)
+    if tf == nil { return errors.New("未启用两步验证") }
+    if err := t.check(tf, code, true); err != nil { return err }
+    return t.mfa.DeleteTwoFactor(u.ID)
+}
+
+func (t *TwoFactorService) RegenerateRecoveryCodes(u *domain.User, code string) ([]string, error) {
+    tf := t.enabled(u.ID)
+    if tf == nil { return nil, errors.New("未启用两步验证") }
+    if err := t.check(tf, code, false); err != nil { return nil, err }
+    return t.newRecoveryCodes(u.ID)
+}
+
+func (t *TwoFactorService) Status(u *domain.User) TwoFactorStatus {
+    st := TwoFactorStatus{Required: t.Required(u.Role)}
+    if t.enabled(u.ID) != nil {
+        st.Enabled = true
+        st.RecoveryCodesLeft = t.mfa.CountRecoveryCodes(u.ID)
+    }
+    return st
+}
+
+// Reset removes a user's 2FA, e.g. after a lost phone; a required role must enroll again at next login.
+func (t *TwoFactorService) Reset(userID int64) error {
+    if t.svc.repo.GetUser(userID) == nil { return errors.New("用户不存在") }
+    return t.mfa.DeleteTwoFactor(userID)
+}
+
+func (t *TwoFactorService) Policies() []*domain.TwoFactorPolicy { return t.mfa.ListTwoFactorPolicies() }
+
+func (t *TwoFactorService) SetPolicy(role domain.Role, required bool) error {
+    if role != domain.RoleStudent && role != domain.RoleTeacher && role != domain.RoleAdmin { return errors.New("未知角色") }
+    return t.mfa.SetTwoFactorPolicy(&domain.TwoFactorPolicy{Role: role, Required: required})
+}
+
+// check verifies a TOTP code (or, if allowed, a recovery code) under the same
+// exponential lockout as password logins.
+func (t *TwoFactorService) check(tf *domain.TwoFactor, code string, allowRecovery bool) error {
+    key := "mfa:" + strconv.FormatInt(tf.UserID, 10)
+    now := time.Now()
+    if th := t.locks.GetLoginThrottle(key); th != nil && th.LockedUntil != nil && th.LockedUntil.After(now) {
+        return &LockedError{Until: *th.LockedUntil}
+    }
+    if t.matches(tf, code, allowRecovery, now) {
+        _ = t.locks.DeleteLoginThrottle(key)
+        return nil
+    }
+    if th, err := t.locks.RecordLoginFailure(key, now, now.Add(-failureWindow)); err == nil {
+        if d := accountPolicy.lockFor(th.Failures); d > 0 { _ = t.locks.SetLoginLockedUntil(key, now.Add(d)) }
+    }
+    return errors.New("验证码错误")
+}
+
+func (t *TwoFactorService) matches(tf *domain.TwoFactor, code string, allowRecovery bool, now time.Time) bool {
+    if step, ok := auth.ValidateTOTP(tf.Secret, code, now); ok {
+        if !tf.Enabled { return true }
+        used, err := t.mfa.AdvanceTOTPStep(tf.UserID, step)
+        return err == nil && used
+    }
+    if !allowRecovery || !tf.Enabled { return false }
+    ok, err := t.mfa.ConsumeRecoveryCode(tf.UserID, hashToken(normalizeRecoveryCode(code)))
+    return err == nil && ok
+}
+
+func (t *TwoFactorService) newRecoveryCodes(userID int64) ([]string, error) {
+    codes := make([]string, 0, recoveryCodeCount)
+    hashes := make([]string, 0, recoveryCodeCount)
+    for i := 0; i < recoveryCodeCount; i++ {
+        raw := auth.NewTokenID()[:10]
+        codes = append(codes, raw[:5]+"-"+raw[5:])
+        hashes = append(hashes, hashToken(raw))
+    }
+    if err := t.mfa.ReplaceRecoveryCodes(userID, hashes); err != nil { return nil, err }
+    return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+    return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
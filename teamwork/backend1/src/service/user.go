@@ -2,6 +2,9 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
 	"golang.org/x/crypto/bcrypt"
@@ -9,7 +12,11 @@ import (
 
 func (s *Service) CreateUser(u *domain.User) (*domain.User, error) {
     if u.Name == "" || u.Email == "" || u.Role == "" { return nil, errors.New("缺少必填字段") }
-    u.Skills = normalize(u.Skills)
+    u.Skills = s.canonicalSkills(u.Skills)
+    if err := checkBio(&u.Bio); err != nil { return nil, err }
+    prof, err := cleanProficiency(s.taxonomy, u.Skills, u.Proficiency)
+    if err != nil { return nil, err }
+    u.Proficiency = prof
     return s.repo.AddUser(u)
 }
 
@@ -18,14 +25,24 @@ func (s *Service) Register(name, email, password string, role domain.Role, skill
     if existing := s.repo.GetUserByEmail(email); existing != nil { return nil, errors.New("邮箱已存在") }
     hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
     if err != nil { return nil, err }
-    u := &domain.User{Name: name, Email: email, Role: role, Skills: normalize(skills), PasswordHash: string(hash)}
+    u := &domain.User{Name: name, Email: email, Role: role, Skills: s.canonicalSkills(skills), PasswordHash: string(hash)}
     return s.repo.AddUser(u)
 }
 
+// ErrBadCredentials is returned for both unknown emails and wrong passwords so the
+// response does not reveal which accounts exist.
+var ErrBadCredentials = errors.New("邮箱或密码错误")
+
+// dummyHash keeps the unknown-email path as slow as a real password check.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
 func (s *Service) Login(email, password string) (*domain.User, error) {
     u := s.repo.GetUserByEmail(email)
-    if u == nil { return nil, errors.New("用户不存在") }
-    if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil { return nil, errors.New("密码错误") }
+    if u == nil {
+        _ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+        return nil, ErrBadCredentials
+    }
+    if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil { return nil, ErrBadCredentials }
     return u, nil
 }
 
@@ -42,8 +59,21 @@ func (s *Service) UpdateUserRole(userID int64, role domain.Role) error {
     return s.repo.UpdateUserRole(userID, role)
 }
 
-func (s *Service) UpdateMe(userID int64, name, email string, skills []string) (*domain.User, error) {
+const maxBioLen = 2000
+
+func checkBio(bio *string) error {
+    *bio = strings.TrimSpace(*bio)
+    if utf8.RuneCountInString(*bio) > maxBioLen { return fmt.Errorf("个人简介不能超过%d字", maxBioLen) }
+    return nil
+}
+
+// UpdateMe updates the caller's profile; a nil bio or proficiency keeps the current
+// one, less the ratings of skills no longer listed.
+func (s *Service) UpdateMe(userID int64, name, email string, skills []string, bio *string, proficiency map[string]int) (*domain.User, error) {
     if name == "" || email == "" { return nil, errors.New("缺少必填字段") }
+    if bio != nil {
+        if err := checkBio(bio); err != nil { return nil, err }
+    }
     u := s.repo.GetUser(userID)
     if u == nil { return nil, errors.New("用户不存在") }
     if u.Email != email {
@@ -51,6 +81,11 @@ func (s *Service) UpdateMe(userID int64, name, email string, skills []string) (*
     }
     u.Name = name
     u.Email = email
-    u.Skills = normalize(skills)
+    u.Skills = s.canonicalSkills(skills)
+    if bio != nil { u.Bio = *bio }
+    if proficiency == nil { proficiency = u.Proficiency }
+    prof, err := cleanProficiency(s.taxonomy, u.Skills, proficiency)
+    if err != nil { return nil, err }
+    u.Proficiency = prof
     return s.repo.UpdateUser(u)
 }
@@ -0,0 +1,169 @@
+package textsearch
+
+import (
+    "hash/fnv"
+    "math"
+    "sync"
+)
+
+// BM25 parameters.
+const (
+    K1 = 1.2
+    B  = 0.75
+)
+
+type doc struct {
+    tf     map[string]int
+    length int
+    sum    uint64 // hash of the indexed text
+}
+
+// Index is an inverted index over documents identified by int64. It is safe for
+// concurrent use.
+type Index struct {
+    mu       sync.RWMutex
+    docs     map[int64]*doc
+    postings map[string]map[int64]int // term -> document -> term frequency
+    totalLen int
+}
+
+func NewIndex() *Index {
+    return &Index{docs: map[int64]*doc{}, postings: map[string]map[int64]int{}}
+}
+
+func checksum(text string) uint64 {
+    h := fnv.New64a()
+    h.Write([]byte(text))
+    return h.Sum64()
+}
+
+func newDoc(text string) *doc {
+    d := &doc{tf: map[string]int{}, sum: checksum(text)}
+    for _, t := range Tokenize(text) { d.tf[t]++; d.length++ }
+    return d
+}
+
+// Put indexes text under id, replacing what was there. Unchanged text is a no-op.
+func (x *Index) Put(id int64, text string) {
+    x.mu.Lock()
+    defer x.mu.Unlock()
+    if old := x.docs[id]; old != nil && old.sum == checksum(text) { return }
+    x.remove(id)
+    d := newDoc(text)
+    x.docs[id] = d
+    x.totalLen += d.length
+    for t, n := range d.tf {
+        if x.postings[t] == nil { x.postings[t] = map[int64]int{} }
+        x.postings[t][id] = n
+    }
+}
+
+func (x *Index) Remove(id int64) {
+    x.mu.Lock()
+    defer x.mu.Unlock()
+    x.remove(id)
+}
+
+func (x *Index) remove(id int64) {
+    d := x.docs[id]
+    if d == nil { return }
+    for t := range d.tf {
+        delete(x.postings[t], id)
+        if len(x.postings[t]) == 0 { delete(x.postings, t) }
+    }
+    x.totalLen -= d.length
+    delete(x.docs, id)
+}
+
+func (x *Index) Len() int {
+    x.mu.RLock()
+    defer x.mu.RUnlock()
+    return len(x.docs)
+}
+
+// idf is the BM25 inverse document frequency; it stays positive for common terms.
+func (x *Index) idf(term string) float64 {
+    n, df := float64(len(x.docs)), float64(len(x.postings[term]))
+    return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+func (x *Index) avgLen() float64 {
+    if len(x.docs) == 0 { return 1 }
+    return float64(x.totalLen) / float64(len(x.docs))
+}
+
+func termScore(tf, length int, avg float64) float64 {
+    f := float64(tf)
+    return f * (K1 + 1) / (f + K1*(1-B+B*float64(length)/avg))
+}
+
+// Query is a set of weighted terms.
+type Query map[string]float64
+
+// Add tokenizes text into q with weight w, keeping the highest weight of a term.
+func (q Query) Add(text string, w float64) {
+    for _, t := range Tokenize(text) { if w > q[t] { q[t] = w } }
+}
+
+// Hit is the score of one document together with the query terms it contains.
+type Hit struct {
+    Score float64
+    Terms map[string]bool
+}
+
+// Search scores every indexed document containing a query term. Scores are divided by
+// what a document of average length mentioning each top-weight query term once would
+// get, and capped at 1.
+func (x *Index) Search(q Query) map[int64]*Hit {
+    x.mu.RLock()
+    defer x.mu.RUnlock()
+    out := map[int64]*Hit{}
+    avg, norm := x.avgLen(), x.norm(q)
+    if norm == 0 { return out }
+    for t, w := range q {
+        idf := x.idf(t)
+        for id, tf := range x.postings[t] {
+            h := out[id]
+            if h == nil { h = &Hit{Terms: map[string]bool{}}; out[id] = h }
+            h.Score += w * idf * termScore(tf, x.docs[id].length, avg) / norm
+            h.Terms[t] = true
+        }
+    }
+    for _, h := range out { h.Score = math.Min(h.Score, 1) }
+    return out
+}
+
+// Current reports whether id is indexed with exactly this text.
+func (x *Index) Current(id int64, text string) bool {
+    x.mu.RLock()
+    defer x.mu.RUnlock()
+    d := x.docs[id]
+    return d != nil && d.sum == checksum(text)
+}
+
+// ScoreText scores a document that is not in the index, such as a project with an
+// applicant's answers appended, against the statistics of the indexed collection.
+func (x *Index) ScoreText(q Query, text string) *Hit {
+    x.mu.RLock()
+    defer x.mu.RUnlock()
+    h := &Hit{Terms: map[string]bool{}}
+    norm := x.norm(q)
+    if norm == 0 { return h }
+    d, avg := newDoc(text), x.avgLen()
+    for t, w := range q {
+        if d.tf[t] == 0 { continue }
+        h.Score += w * x.idf(t) * termScore(d.tf[t], d.length, avg) / norm
+        h.Terms[t] = true
+    }
+    h.Score = math.Min(h.Score, 1)
+    return h
+}
+
+// norm is the score of the ideal document for the terms of the highest weight; terms
+// of lower weight only add to a score.
+func (x *Index) norm(q Query) float64 {
+    top, n := 0.0, 0.0
+    for _, w := range q { top = math.Max(top, w) }
+    for t, w := range q { if w == top { n += w * x.idf(t) } }
+    return n
+}
@@ -0,0 +1,55 @@
+package textsearch
+
+import "testing"
+
+func newTestIndex() *Index {
+    x := NewIndex()
+    x.Put(1, "Go backend service with PostgreSQL")
+    x.Put(2, "React frontend for a Go API")
+    x.Put(3, "机器学习 image classification with PyTorch")
+    return x
+}
+
+func TestSearchRanksByTermRarity(t *testing.T) {
+    x := newTestIndex()
+    q := Query{}
+    q.Add("go postgresql", 1)
+    hits := x.Search(q)
+    if len(hits) != 2 || hits[3] != nil { t.Fatalf("hits = %v", hits) }
+    if hits[1].Score <= hits[2].Score { t.Errorf("doc with the rare term scored %v, the other %v", hits[1].Score, hits[2].Score) }
+    if !hits[1].Terms["postgresql"] || hits[2].Terms["postgresql"] { t.Errorf("terms: %v, %v", hits[1].Terms, hits[2].Terms) }
+    for id, h := range hits { if h.Score <= 0 || h.Score > 1 { t.Errorf("doc %d scored %v", id, h.Score) } }
+}
+
+func TestLowerWeightTermsOnlyAdd(t *testing.T) {
+    x := newTestIndex()
+    q := Query{}
+    q.Add("react", 1)
+    q.Add("go", 0.2)
+    hits := x.Search(q)
+    if hits[2].Score <= hits[1].Score { t.Errorf("top-weight match %v not above low-weight match %v", hits[2].Score, hits[1].Score) }
+    if hits[2].Score != 1 { t.Errorf("doc with every term scored %v, want the cap of 1", hits[2].Score) }
+}
+
+func TestPutReplacesAndRemoveDrops(t *testing.T) {
+    x := newTestIndex()
+    q := Query{}
+    q.Add("pytorch", 1)
+    if hits := x.Search(q); hits[3] == nil { t.Fatal("doc 3 not found") }
+    x.Put(3, "Rust embedded firmware")
+    if hits := x.Search(q); len(hits) != 0 { t.Errorf("old text still matches: %v", hits) }
+    if !x.Current(3, "Rust embedded firmware") || x.Current(3, "机器学习") { t.Error("Current does not follow Put") }
+    x.Remove(3)
+    q.Add("rust", 1)
+    if hits := x.Search(q); len(hits) != 0 || x.Len() != 2 { t.Errorf("removed doc still indexed: %v, len %d", hits, x.Len()) }
+    if x.totalLen != len(Tokenize("Go backend service with PostgreSQL"))+len(Tokenize("React frontend for a Go API")) { t.Errorf("totalLen = %d", x.totalLen) }
+}
+
+func TestScoreTextUsesCollectionStatistics(t *testing.T) {
+    x := newTestIndex()
+    q := Query{}
+    q.Add("go postgresql", 1)
+    h := x.ScoreText(q, "Go backend service with PostgreSQL")
+    if got := x.Search(q)[1]; h.Score != got.Score { t.Errorf("ScoreText %v, indexed doc %v", h.Score, got.Score) }
+    if h := x.ScoreText(Query{}, "go"); h.Score != 0 { t.Errorf("empty query scored %v", h.Score) }
+}
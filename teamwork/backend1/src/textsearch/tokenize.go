@@ -0,0 +1,56 @@
+// Package textsearch is a small in-memory BM25 index for ranking short documents such
+// as project descriptions. It needs no dictionary: Chinese text is split into
+// overlapping character pairs, everything else into words.
+package textsearch
+
+import (
+    "strings"
+    "unicode"
+)
+
+var stopwords = map[string]bool{
+    "a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+    "for": true, "from": true, "in": true, "is": true, "it": true, "of": true, "on": true, "or": true,
+    "the": true, "to": true, "with": true,
+}
+
+// Tokenize lowercases s and splits it into terms. Runs of Han characters become
+// bigrams (a lone character stays a unigram); other words keep inner '.', '-', '_'
+// and trailing '+' or '#' so that "node.js", "c++" and "c#" survive intact.
+func Tokenize(s string) []string {
+    var out []string
+    rs := []rune(strings.ToLower(s))
+    for i := 0; i < len(rs); {
+        r := rs[i]
+        switch {
+        case unicode.Is(unicode.Han, r):
+            j := i
+            for j < len(rs) && unicode.Is(unicode.Han, rs[j]) { j++ }
+            if j-i == 1 { out = append(out, string(rs[i])) }
+            for k := i; k+1 < j; k++ { out = append(out, string(rs[k:k+2])) }
+            i = j
+        case isWord(r):
+            j := i + 1
+            for j < len(rs) {
+                c := rs[j]
+                if isWord(c) { j++; continue }
+                if (c == '.' || c == '-' || c == '_') && j+1 < len(rs) && isWord(rs[j+1]) { j++; continue }
+                if c == '+' || c == '#' {
+                    k := j
+                    for k < len(rs) && (rs[k] == '+' || rs[k] == '#') { k++ }
+                    if k == len(rs) || !isWord(rs[k]) { j = k }
+                }
+                break
+            }
+            if w := string(rs[i:j]); !stopwords[w] { out = append(out, w) }
+            i = j
+        default:
+            i++
+        }
+    }
+    return out
+}
+
+func isWord(r rune) bool {
+    return (unicode.IsLetter(r) || unicode.IsDigit(r)) && !unicode.Is(unicode.Han, r)
+}
@@ -0,0 +1,26 @@
+package textsearch
+
+import (
+    "slices"
+    "testing"
+)
+
+func TestTokenize(t *testing.T) {
+    for _, c := range []struct {
+        in   string
+        want []string
+    }{
+        {"机器学习", []string{"机器", "器学", "学习"}},
+        {"用 Go 写", []string{"用", "go", "写"}},
+        {"Node.js and React", []string{"node.js", "react"}},
+        {"end.", []string{"end"}},
+        {"C++, C# 与 F#", []string{"c++", "c#", "与", "f#"}},
+        {"g+h c#d", []string{"g", "h", "c", "d"}},
+        {"x++y", []string{"x", "y"}},
+        {"real-time data_set", []string{"real-time", "data_set"}},
+        {"The state of the art", []string{"state", "art"}},
+        {"深度学习with PyTorch", []string{"深度", "度学", "学习", "pytorch"}},
+    } {
+        if got := Tokenize(c.in); !slices.Equal(got, c.want) { t.Errorf("Tokenize(%q) = %q, want %q", c.in, got, c.want) }
+    }
+}
@@ -7,8 +7,28 @@ import (
 )
 
 type AppConfig struct {
-    Database string `yaml:"database"`
-    OpenAI   OpenAIConfig `yaml:"openai_api"`
+    Database     string             `yaml:"database"`
+    OpenAI       OpenAIConfig       `yaml:"openai_api"`
+    JWT          JWTConfig          `yaml:"jwt"`
+    Mail         MailConfig         `yaml:"mail"`
+    OIDC         OIDCConfig         `yaml:"oidc"`
+    Auth         AuthConfig         `yaml:"auth"`
+    Policy       PolicyConfig       `yaml:"policy"`
+    Applications ApplicationsConfig `yaml:"applications"`
+    Matching     MatchingConfig     `yaml:"matching"`
+    Server       ServerConfig       `yaml:"server"`
+}
+
+type ServerConfig struct {
+    // TrustedProxies lists the reverse proxies (addresses or CIDRs) whose X-Forwarded-For
+    // header gives the client address; requests from anywhere else use the peer address.
+    TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+type JWTConfig struct {
+    Algorithm   string `yaml:"algorithm"`    // RS256 (default) or EdDSA
+    RotateHours int    `yaml:"rotate_hours"` // lifetime of the active signing key
+    RetireHours int    `yaml:"retire_hours"` // how long a rotated key keeps verifying
 }
 
 type OpenAIConfig struct {
@@ -17,6 +37,84 @@ type OpenAIConfig struct {
     Model          string `yaml:"model"`
 }
 
+type MailConfig struct {
+    Driver   string `yaml:"driver"` // smtp or log (default)
+    Host     string `yaml:"host"`
+    Port     int    `yaml:"port"`
+    Username string `yaml:"username"`
+    Password string `yaml:"password"`
+    From     string `yaml:"from"`
+    LogPath  string `yaml:"log_path"`
+    BaseURL  string `yaml:"base_url"` // frontend address used in mail links
+}
+
+type OIDCConfig struct {
+    Enabled      bool              `yaml:"enabled"`
+    Issuer       string            `yaml:"issuer"`
+    ClientID     string            `yaml:"client_id"`
+    ClientSecret string            `yaml:"client_secret"`
+    RedirectURL  string            `yaml:"redirect_url"` // this backend's /api/auth/oidc/callback
+    FrontendURL  string            `yaml:"frontend_url"` // where the browser lands after login
+    Scopes       []string          `yaml:"scopes"`
+    GroupsClaim  string            `yaml:"groups_claim"` // default "groups"
+    RoleMapping  map[string]string `yaml:"role_mapping"` // IdP group -> student/teacher/admin
+    DefaultRole  string            `yaml:"default_role"` // default student
+}
+
+type AuthConfig struct {
+    Providers []string   `yaml:"providers"` // tried in order: local, ldap; default [local]
+    LDAP      LDAPConfig `yaml:"ldap"`
+}
+
+type LDAPConfig struct {
+    URL                string            `yaml:"url"` // ldap://host:389 or ldaps://host:636
+    BindDN             string            `yaml:"bind_dn"`
+    BindPassword       string            `yaml:"bind_password"`
+    BaseDN             string            `yaml:"base_dn"`
+    StartTLS           bool              `yaml:"start_tls"`
+    CAFile             string            `yaml:"ca_file"` // PEM bundle for a directory with a private CA
+    UserFilter         string            `yaml:"user_filter"` // %s is the escaped email, default (mail=%s)
+    NameAttr           string            `yaml:"name_attr"`
+    EmailAttr          string            `yaml:"email_attr"`
+    GroupAttr          string            `yaml:"group_attr"`
+    RoleMapping        map[string]string `yaml:"role_mapping"` // group DN -> student/teacher/admin
+    DefaultRole        string            `yaml:"default_role"`
+}
+
+// PolicyConfig adds authorization rules to the built-in ones, or replaces them.
+type PolicyConfig struct {
+    ReplaceDefaults bool         `yaml:"replace_defaults"`
+    Rules           []PolicyRule `yaml:"rules"`
+}
+
+type PolicyRule struct {
+    Role     string `yaml:"role"`     // student, teacher or admin; other roles are rejected
+    Action   string `yaml:"action"`   // read, create, update, delete, archive, decide, track, feedback
+    Resource string `yaml:"resource"` // project or application
+    Relation string `yaml:"relation"` // any, owner, co_supervisor, applicant
+    Status   string `yaml:"status"`   // optional application status the grant is limited to
+}
+
+type ApplicationsConfig struct {
+    Reapply ReapplyConfig `yaml:"reapply"`
+}
+
+// ReapplyConfig decides when a student may apply again to the same project. By default
+// neither a withdrawn nor a rejected application can be resubmitted.
+type ReapplyConfig struct {
+    AfterWithdrawn bool `yaml:"after_withdrawn"`
+    AfterRejected  bool `yaml:"after_rejected"`
+    CooldownHours  int  `yaml:"cooldown_hours"`  // since the withdrawal or rejection
+    MaxSubmissions int  `yaml:"max_submissions"` // per student and project, 0 = unlimited
+}
+
+// MatchingConfig names the matcher used when a request does not pick one, and the
+// matchers tried in order when the chosen one fails. Known names: llm, tfidf, skills, simple.
+type MatchingConfig struct {
+    Default  string   `yaml:"default"`  // default llm
+    Fallback []string `yaml:"fallback"` // e.g. [llm, tfidf, simple]
+}
+
 func Load() (*AppConfig, error) {
     p := filepath.Join("config", "config.yaml")
     b, err := os.ReadFile(p)
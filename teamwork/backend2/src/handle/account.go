@@ -0,0 +1,77 @@
+package handle
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+type AccountHandlers struct {
+    svc *service.Service
+    acc *service.AccountService
+}
+
+func NewAccountHandlers(s *service.Service, acc *service.AccountService) *AccountHandlers {
+    return &AccountHandlers{svc: s, acc: acc}
+}
+
+func (h *AccountHandlers) Register(c *gin.Context) {
+    var b struct {
+        Name     string   `json:"name"`
+        Email    string   `json:"email"`
+        Password string   `json:"password"`
+        Role     string   `json:"role"`
+        Skills   []string `json:"skills"`
+    }
+    if !parseJSON(c, &b) { return }
+    u, err := h.acc.Register(b.Name, b.Email, b.Password, domain.Role(b.Role), b.Skills)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(201, u)
+}
+
+// CreateUser is the admin's way to add an account; the user sets a password by mail.
+func (h *AccountHandlers) CreateUser(c *gin.Context) {
+    var u domain.User
+    if !parseJSON(c, &u) { return }
+    created, err := h.acc.CreateUser(&u)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(201, created)
+}
+
+func (h *AccountHandlers) VerifyEmail(c *gin.Context) {
+    var b struct{ Token string `json:"token"` }
+    if !parseJSON(c, &b) { return }
+    if err := h.acc.VerifyEmail(b.Token); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+func (h *AccountHandlers) ResendVerification(c *gin.Context) {
+    u := currentUser(c)
+    if u == nil { c.JSON(401, gin.H{"error":"未认证"}); return }
+    if err := h.acc.SendVerification(u); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+func (h *AccountHandlers) ForgotPassword(c *gin.Context) {
+    var b struct{ Email string `json:"email"` }
+    if !parseJSON(c, &b) { return }
+    if err := h.acc.RequestPasswordReset(b.Email); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+func (h *AccountHandlers) ResetPassword(c *gin.Context) {
+    var b struct{ Token string `json:"token"`; Password string `json:"password"` }
+    if !parseJSON(c, &b) { return }
+    if err := h.acc.ResetPassword(b.Token, b.Password); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+func (h *AccountHandlers) UpdateMe(c *gin.Context) {
+    u := currentUser(c)
+    if u == nil { c.JSON(401, gin.H{"error":"未认证"}); return }
+    var b struct { Name string `json:"name"`; Email string `json:"email"`; Skills []string `json:"skills"`; Bio *string `json:"bio"`; Proficiency map[string]int `json:"proficiency"` }
+    if !parseJSON(c, &b) { return }
+    updated, err := h.acc.UpdateMe(u.ID, b.Name, b.Email, b.Skills, b.Bio, b.Proficiency)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, updated)
+}
@@ -6,9 +6,15 @@ import (
     "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
 )
 
-type AdminHandlers struct { svc *service.Service }
+type AdminHandlers struct {
+    svc    *service.Service
+    tokens *service.TokenService
+    locks  *service.LockoutService
+}
 
-func NewAdminHandlers(s *service.Service) *AdminHandlers { return &AdminHandlers{svc: s} }
+func NewAdminHandlers(s *service.Service, ts *service.TokenService, ls *service.LockoutService) *AdminHandlers {
+    return &AdminHandlers{svc: s, tokens: ts, locks: ls}
+}
 
 func (h *AdminHandlers) Stats(c *gin.Context) {
     c.JSON(200, h.svc.Stats())
@@ -18,6 +24,18 @@ func (h *AdminHandlers) UpdateUserRole(c *gin.Context) {
     var b struct{ UserID int64 `json:"user_id"`; Role string `json:"role"` }
     if err := c.ShouldBindJSON(&b); err != nil { c.JSON(400, gin.H{"error":"invalid json"}); return }
     if b.UserID == 0 || b.Role == "" { c.JSON(400, gin.H{"error":"缺少必填字段"}); return }
-    if err := h.svc.UpdateUserRole(b.UserID, domain.Role(b.Role)); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    if err := h.tokens.UpdateUserRole(b.UserID, domain.Role(b.Role)); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+func (h *AdminHandlers) ListLockouts(c *gin.Context) {
+    c.JSON(200, h.locks.ListLocked())
+}
+
+func (h *AdminHandlers) ClearLockout(c *gin.Context) {
+    var b struct{ Key string `json:"key"` }
+    if err := c.ShouldBindJSON(&b); err != nil { c.JSON(400, gin.H{"error":"invalid json"}); return }
+    if b.Key == "" { c.JSON(400, gin.H{"error":"缺少key"}); return }
+    if err := h.locks.Clear(b.Key); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
     c.JSON(200, gin.H{"ok": true})
 }
@@ -0,0 +1,91 @@
+package handle
+
+import (
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/policy"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+type AllocationHandlers struct {
+    authz    *service.Authorizer
+    alloc    *service.AllocationService
+    matchers *service.MatcherRegistry
+}
+
+func NewAllocationHandlers(az *service.Authorizer, a *service.AllocationService, reg *service.MatcherRegistry) *AllocationHandlers {
+    return &AllocationHandlers{authz: az, alloc: a, matchers: reg}
+}
+
+// Preferences takes the student's applications best first.
+func (h *AllocationHandlers) Preferences(c *gin.Context) {
+    var b struct{ ApplicationIDs []int64 `json:"application_ids"` }
+    if !parseJSON(c, &b) { return }
+    if err := h.alloc.RankProjects(currentUser(c).ID, b.ApplicationIDs); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+// MyPreferences returns the student's own preference order.
+func (h *AllocationHandlers) MyPreferences(c *gin.Context) {
+    c.JSON(200, h.alloc.StudentRanks(currentUser(c).ID))
+}
+
+// ProjectRanking returns a project's order over its applicants to whoever may rank them.
+func (h *AllocationHandlers) ProjectRanking(c *gin.Context) {
+    pid, err := strconv.ParseInt(c.Query("project_id"), 10, 64)
+    if err != nil { c.JSON(400, gin.H{"error":"缺少项目ID"}); return }
+    cu := currentUser(c)
+    if _, err := h.authz.ProjectByID(cu, policy.ActRead, pid); deny(c, err) { return }
+    if deny(c, h.authz.Application(cu, policy.ActDecide, &domain.Application{ProjectID: pid})) { return }
+    c.JSON(200, h.alloc.ApplicantRanks(pid))
+}
+
+// Ranking takes a project's applicants best first.
+func (h *AllocationHandlers) Ranking(c *gin.Context) {
+    var b struct { ProjectID int64 `json:"project_id"`; ApplicationIDs []int64 `json:"application_ids"` }
+    if !parseJSON(c, &b) { return }
+    if b.ProjectID == 0 { c.JSON(400, gin.H{"error":"缺少项目ID"}); return }
+    cu := currentUser(c)
+    if _, err := h.authz.ProjectByID(cu, policy.ActRead, b.ProjectID); deny(c, err) { return }
+    if deny(c, h.authz.Application(cu, policy.ActDecide, &domain.Application{ProjectID: b.ProjectID})) { return }
+    if err := h.alloc.RankApplicants(b.ProjectID, b.ApplicationIDs); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+func (h *AllocationHandlers) List(c *gin.Context) { c.JSON(200, h.alloc.Runs()) }
+
+// Run computes a new draft; ?matcher= picks the matcher that ranks unranked applicants.
+func (h *AllocationHandlers) Run(c *gin.Context) {
+    m, ok := pickMatcher(c, h.matchers)
+    if !ok { return }
+    var b struct{ RoundID int64 `json:"round_id"` }
+    if !parseJSON(c, &b) { return }
+    run, err := h.alloc.Run(currentUser(c), b.RoundID, m)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(201, run)
+}
+
+func (h *AllocationHandlers) Proposals(c *gin.Context) {
+    id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+    if err != nil { c.JSON(400, gin.H{"error":"id格式错误"}); return }
+    props, err := h.alloc.Proposals(id)
+    if err != nil { c.JSON(404, gin.H{"error": err.Error()}); return }
+    c.JSON(200, props)
+}
+
+func (h *AllocationHandlers) Publish(c *gin.Context) {
+    var b struct{ ID int64 `json:"id"` }
+    if !parseJSON(c, &b) { return }
+    skipped, err := h.alloc.Publish(currentUser(c), b.ID)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true, "skipped": skipped})
+}
+
+func (h *AllocationHandlers) Discard(c *gin.Context) {
+    var b struct{ ID int64 `json:"id"` }
+    if !parseJSON(c, &b) { return }
+    if err := h.alloc.Discard(b.ID); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
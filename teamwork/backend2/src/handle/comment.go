@@ -0,0 +1,84 @@
+package handle
+
+import (
+    "errors"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/auth"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/policy"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+type CommentHandlers struct {
+    authz    *service.Authorizer
+    comments *service.CommentService
+}
+
+func NewCommentHandlers(az *service.Authorizer, cs *service.CommentService) *CommentHandlers {
+    return &CommentHandlers{authz: az, comments: cs}
+}
+
+func (h *CommentHandlers) Thread(c *gin.Context) {
+    id, err := strconv.ParseInt(c.Query("application_id"), 10, 64)
+    if err != nil { c.JSON(400, gin.H{"error":"application_id格式错误"}); return }
+    cu := currentUser(c)
+    if _, err := h.authz.ApplicationByID(cu, policy.ActComment, id); deny(c, err) { return }
+    page, _ := strconv.Atoi(c.Query("page"))
+    size, _ := strconv.Atoi(c.Query("page_size"))
+    out, err := h.comments.Thread(cu, id, page, size, auth.CurrentImpersonator(c) == nil)
+    if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+    c.JSON(200, out)
+}
+
+func (h *CommentHandlers) Post(c *gin.Context) {
+    var b struct { ApplicationID int64 `json:"application_id"`; Body string `json:"body"` }
+    if !parseJSON(c, &b) { return }
+    cu := currentUser(c)
+    if _, err := h.authz.ApplicationByID(cu, policy.ActComment, b.ApplicationID); deny(c, err) { return }
+    cm, err := h.comments.Post(cu, b.ApplicationID, b.Body)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(201, cm)
+}
+
+// comment loads :id and checks that the user still takes part in its thread.
+func (h *CommentHandlers) comment(c *gin.Context) (int64, bool) {
+    id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+    if err != nil { c.JSON(400, gin.H{"error":"id格式错误"}); return 0, false }
+    cm := h.comments.Comment(id)
+    if cm == nil { c.JSON(404, gin.H{"error":"留言不存在"}); return 0, false }
+    if _, err := h.authz.ApplicationByID(currentUser(c), policy.ActComment, cm.ApplicationID); deny(c, err) { return 0, false }
+    return id, true
+}
+
+func (h *CommentHandlers) Edit(c *gin.Context) {
+    id, ok := h.comment(c)
+    if !ok { return }
+    var b struct{ Body string `json:"body"` }
+    if !parseJSON(c, &b) { return }
+    cm, err := h.comments.Edit(currentUser(c), id, b.Body)
+    if err != nil { c.JSON(commentStatus(err), gin.H{"error": err.Error()}); return }
+    c.JSON(200, cm)
+}
+
+func (h *CommentHandlers) Delete(c *gin.Context) {
+    id, ok := h.comment(c)
+    if !ok { return }
+    if err := h.comments.Delete(currentUser(c), id); err != nil { c.JSON(commentStatus(err), gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+func (h *CommentHandlers) MarkRead(c *gin.Context) {
+    var b struct{ ApplicationID int64 `json:"application_id"` }
+    if !parseJSON(c, &b) { return }
+    cu := currentUser(c)
+    if _, err := h.authz.ApplicationByID(cu, policy.ActComment, b.ApplicationID); deny(c, err) { return }
+    if err := h.comments.MarkRead(cu.ID, b.ApplicationID); err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+// commentStatus answers 403 when someone touches another user's message.
+func commentStatus(err error) int {
+    if errors.Is(err, service.ErrForbidden) { return 403 }
+    return 400
+}
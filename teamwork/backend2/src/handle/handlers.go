@@ -1,19 +1,92 @@
 package handle
 
 import (
+	"errors"
 	"strconv"
+	"sync"
 
 	"github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/policy"
 	"github.com/bugoutianzhen123/SoftwareConstructionExp/service"
 	"github.com/gin-gonic/gin"
 )
 
-type Handlers struct { svc *service.Service }
+type Handlers struct {
+    svc      *service.Service
+    authz    *service.Authorizer
+    comments *service.CommentService // fills unread counts when set
+    mu       sync.Mutex
+    matchers *service.MatcherRegistry // built on first use unless set with UseMatchers
+}
 
-func NewHandlers(s *service.Service) *Handlers { return &Handlers{svc: s} }
+func NewHandlers(s *service.Service) *Handlers {
+    return &Handlers{svc: s, authz: service.NewAuthorizer(s, nil)}
+}
 
 func (h *Handlers) Service() *service.Service { return h.svc }
 
+// UsePolicy replaces the default authorization policy.
+func (h *Handlers) UsePolicy(p *policy.Policy) { h.authz = service.NewAuthorizer(h.svc, p) }
+
+func (h *Handlers) Authorizer() *service.Authorizer { return h.authz }
+
+// UseComments lets application lists show how many comments the viewer has not read.
+func (h *Handlers) UseComments(cs *service.CommentService) { h.comments = cs }
+
+// UseMatchers sets the matcher registry, for the handlers and the service.
+func (h *Handlers) UseMatchers(reg *service.MatcherRegistry) {
+    h.mu.Lock(); defer h.mu.Unlock()
+    h.matchers = reg
+    h.svc.UseMatchers(reg)
+}
+
+// registry returns the registry set with UseMatchers, or else builds the default one.
+func (h *Handlers) registry() (*service.MatcherRegistry, error) {
+    h.mu.Lock(); defer h.mu.Unlock()
+    if h.matchers != nil { return h.matchers, nil }
+    reg, err := service.NewMatcherRegistry(h.svc, service.MatcherOptions{})
+    if err != nil { return nil, err }
+    h.matchers = reg
+    h.svc.UseMatchers(reg)
+    return reg, nil
+}
+
+// pickMatcher resolves ?matcher=<name>; the older fast=1 still means the keyword matcher.
+func pickMatcher(c *gin.Context, reg *service.MatcherRegistry) (domain.Matcher, bool) {
+    name := c.Query("matcher")
+    if v := c.Query("fast"); name == "" && (v == "1" || v == "true") { name = service.MatcherSimple }
+    m, err := reg.Get(name)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return nil, false }
+    return m, true
+}
+
+func (h *Handlers) pickMatcher(c *gin.Context) (domain.Matcher, bool) {
+    reg, err := h.registry()
+    if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return nil, false }
+    return pickMatcher(c, reg)
+}
+
+// ListMatchers shows the available matchers, their health and fallback order; only
+// admins see why a matcher is unhealthy.
+func (h *Handlers) ListMatchers(c *gin.Context) {
+    reg, err := h.registry()
+    if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+    c.JSON(200, reg.List(currentUser(c).Role == domain.RoleAdmin))
+}
+
+func (h *Handlers) fillUnread(u *domain.User, views []domain.ApplicationView) {
+    if h.comments == nil { return }
+    h.comments.FillUnread(u.ID, views, func(a *domain.Application) bool { return h.authz.Application(u, policy.ActComment, a) == nil })
+}
+
+// deny answers an authorization error and reports whether it did.
+func deny(c *gin.Context, err error) bool {
+    if err == nil { return false }
+    if errors.Is(err, service.ErrNotFound) { c.JSON(404, gin.H{"error": err.Error()}); return true }
+    c.JSON(403, gin.H{"error": err.Error()})
+    return true
+}
+
 func parseJSON[T any](c *gin.Context, v *T) bool {
     if err := c.ShouldBindJSON(v); err != nil {
         c.JSON(400, gin.H{"error": "invalid json"})
@@ -27,14 +100,6 @@ func currentUser(c *gin.Context) *domain.User {
     u, _ := v.(*domain.User); return u
 }
 
-func (h *Handlers) CreateUser(c *gin.Context) {
-    var u domain.User
-    if !parseJSON(c, &u) { return }
-    created, err := h.svc.CreateUser(&u)
-    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
-    c.JSON(201, created)
-}
-
 func (h *Handlers) ListUsers(c *gin.Context) {
     role := c.Query("role")
     page := 1; size := 50
@@ -59,15 +124,15 @@ func (h *Handlers) GetUser(c *gin.Context) {
 func (h *Handlers) Me(c *gin.Context) {
     u := currentUser(c)
     if u == nil { c.JSON(401, gin.H{"error":"未认证"}); return }
-    c.JSON(200, u)
+    c.JSON(200, newMeView(c, u))
 }
 
 func (h *Handlers) UpdateMe(c *gin.Context) {
     u := currentUser(c)
     if u == nil { c.JSON(401, gin.H{"error":"未认证"}); return }
-    var b struct { Name string `json:"name"`; Email string `json:"email"`; Skills []string `json:"skills"` }
+    var b struct { Name string `json:"name"`; Email string `json:"email"`; Skills []string `json:"skills"`; Bio *string `json:"bio"`; Proficiency map[string]int `json:"proficiency"` }
     if err := c.ShouldBindJSON(&b); err != nil { c.JSON(400, gin.H{"error":"invalid json"}); return }
-    updated, err := h.svc.UpdateMe(u.ID, b.Name, b.Email, b.Skills)
+    updated, err := h.svc.UpdateMe(u.ID, b.Name, b.Email, b.Skills, b.Bio, b.Proficiency)
     if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
     c.JSON(200, updated)
 }
@@ -75,26 +140,41 @@ func (h *Handlers) UpdateMe(c *gin.Context) {
 func (h *Handlers) CreateProject(c *gin.Context) {
     var p domain.Project
     if !parseJSON(c, &p) { return }
-    if cu := currentUser(c); cu != nil && cu.Role == domain.RoleTeacher {
-        if p.TeacherID != cu.ID { c.JSON(403, gin.H{"error":"只能为本人发布项目"}); return }
-    }
+    if deny(c, h.authz.Project(currentUser(c), policy.ActCreate, &p)) { return }
     created, err := h.svc.CreateProject(&p)
     if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
     c.JSON(201, created)
 }
 
+// projectUpdate tells omitted settings from ones set to their zero value, which keep
+// their current value on update.
+type projectUpdate struct {
+    domain.Project
+    Capacity           *int  `json:"capacity"`
+    AutoWaitlist       *bool `json:"auto_waitlist"`
+    RequireCoverLetter *bool `json:"require_cover_letter"`
+    CountTeamMembers   *bool `json:"count_team_members"`
+}
+
+func keep[T any](v *T, cur T) T {
+    if v == nil { return cur }
+    return *v
+}
+
 func (h *Handlers) UpdateProject(c *gin.Context) {
-    var p domain.Project
-    if !parseJSON(c, &p) { return }
+    var b projectUpdate
+    if !parseJSON(c, &b) { return }
+    p := b.Project
     if p.ID == 0 { c.JSON(400, gin.H{"error":"缺少项目ID"}); return }
-    // Only teacher(owner) or admin can update; admin allowed by router
-    if cu := currentUser(c); cu != nil && cu.Role == domain.RoleTeacher {
-        cur := h.svc.Repo().GetProject(p.ID)
-        if cur == nil { c.JSON(404, gin.H{"error":"项目不存在"}); return }
-        if cur.TeacherID != cu.ID { c.JSON(403, gin.H{"error":"无权修改该项目"}); return }
-        // ensure teacher_id not changed
-        p.TeacherID = cur.TeacherID
-    }
+    cur, err := h.authz.ProjectByID(currentUser(c), policy.ActUpdate, p.ID)
+    if deny(c, err) { return }
+    p.Capacity, p.AutoWaitlist = keep(b.Capacity, cur.Capacity), keep(b.AutoWaitlist, cur.AutoWaitlist)
+    p.RequireCoverLetter, p.CountTeamMembers = keep(b.RequireCoverLetter, cur.RequireCoverLetter), keep(b.CountTeamMembers, cur.CountTeamMembers)
+    // only an admin may hand the project to another teacher
+    if cu := currentUser(c); cu.Role != domain.RoleAdmin || p.TeacherID == 0 { p.TeacherID = cur.TeacherID }
+    if p.CoSupervisors == nil { p.CoSupervisors = cur.CoSupervisors }
+    if p.Questions == nil { p.Questions = cur.Questions }
+    if p.RequirementDetails == nil { p.RequirementDetails = h.svc.KeepRequirementDetails(cur.RequirementDetails, p.Requirements) }
     out, err := h.svc.UpdateProject(&p)
     if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
     c.JSON(200, out)
@@ -105,11 +185,7 @@ func (h *Handlers) DeleteProject(c *gin.Context) {
     if idStr == "" { c.JSON(400, gin.H{"error":"缺少项目ID"}); return }
     id, err := strconv.ParseInt(idStr, 10, 64)
     if err != nil { c.JSON(400, gin.H{"error":"项目ID格式错误"}); return }
-    if cu := currentUser(c); cu != nil && cu.Role == domain.RoleTeacher {
-        cur := h.svc.Repo().GetProject(id)
-        if cur == nil { c.JSON(404, gin.H{"error":"项目不存在"}); return }
-        if cur.TeacherID != cu.ID { c.JSON(403, gin.H{"error":"无权删除该项目"}); return }
-    }
+    if _, err := h.authz.ProjectByID(currentUser(c), policy.ActDelete, id); deny(c, err) { return }
     if err := h.svc.DeleteProject(id); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
     c.JSON(200, gin.H{"ok": true})
 }
@@ -118,11 +194,7 @@ func (h *Handlers) ArchiveProject(c *gin.Context) {
     var b struct { ID int64 `json:"id"`; Archived bool `json:"archived"` }
     if !parseJSON(c, &b) { return }
     if b.ID == 0 { c.JSON(400, gin.H{"error":"缺少项目ID"}); return }
-    if cu := currentUser(c); cu != nil && cu.Role == domain.RoleTeacher {
-        cur := h.svc.Repo().GetProject(b.ID)
-        if cur == nil { c.JSON(404, gin.H{"error":"项目不存在"}); return }
-        if cur.TeacherID != cu.ID { c.JSON(403, gin.H{"error":"无权归档该项目"}); return }
-    }
+    if _, err := h.authz.ProjectByID(currentUser(c), policy.ActArchive, b.ID); deny(c, err) { return }
     if err := h.svc.SetProjectArchived(b.ID, b.Archived); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
     c.JSON(200, gin.H{"ok": true})
 }
@@ -147,33 +219,12 @@ func (h *Handlers) ListProjects(c *gin.Context) {
     c.JSON(200, list)
 }
 
-func (h *Handlers) Apply(c *gin.Context) {
-    var a domain.Application
-    if !parseJSON(c, &a) { return }
-    if cu := currentUser(c); cu != nil && cu.Role == domain.RoleStudent {
-        if a.StudentID != cu.ID { c.JSON(403, gin.H{"error":"只能为本人提交申请"}); return }
-    }
-    a.Status = "submitted"
-    created, err := h.svc.Apply(&a)
-    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
-    c.JSON(201, created)
-}
-
 func (h *Handlers) Tracking(c *gin.Context) {
     var t domain.Tracking
     if !parseJSON(c, &t) { return }
     cu := currentUser(c)
     if cu == nil { c.JSON(401, gin.H{"error":"未认证"}); return }
-    app := h.svc.Repo().GetApplication(t.ApplicationID)
-    if app == nil { c.JSON(404, gin.H{"error":"申请不存在"}); return }
-    if cu.Role == domain.RoleTeacher {
-        proj := h.svc.Repo().GetProject(app.ProjectID)
-        if proj == nil || proj.TeacherID != cu.ID { c.JSON(403, gin.H{"error":"无权更新该申请"}); return }
-    }
-    if cu.Role == domain.RoleStudent {
-        if app.StudentID != cu.ID { c.JSON(403, gin.H{"error":"无权更新该申请"}); return }
-        if app.Status != "approved" { c.JSON(403, gin.H{"error":"仅已通过的申请可记录进度"}); return }
-    }
+    if _, err := h.authz.ApplicationByID(cu, policy.ActTrack, t.ApplicationID); deny(c, err) { return }
     created, err := h.svc.AddTracking(&t)
     if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
     c.JSON(201, created)
@@ -182,12 +233,7 @@ func (h *Handlers) Tracking(c *gin.Context) {
 func (h *Handlers) Feedback(c *gin.Context) {
     var f domain.Feedback
     if !parseJSON(c, &f) { return }
-    if cu := currentUser(c); cu != nil && cu.Role == domain.RoleTeacher {
-        app := h.svc.Repo().GetApplication(f.ApplicationID)
-        if app == nil { c.JSON(404, gin.H{"error":"申请不存在"}); return }
-        proj := h.svc.Repo().GetProject(app.ProjectID)
-        if proj == nil || proj.TeacherID != cu.ID { c.JSON(403, gin.H{"error":"无权评价该申请"}); return }
-    }
+    if _, err := h.authz.ApplicationByID(currentUser(c), policy.ActFeedback, f.ApplicationID); deny(c, err) { return }
     created, err := h.svc.AddFeedback(&f)
     if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
     c.JSON(201, created)
@@ -199,16 +245,11 @@ func (h *Handlers) Matches(c *gin.Context) {
     sid, err := strconv.ParseInt(sidStr, 10, 64)
     if err != nil { c.JSON(400, gin.H{"error": "student_id格式错误"}); return }
     if cu := currentUser(c); cu != nil && cu.Role == domain.RoleStudent && cu.ID != sid { c.JSON(403, gin.H{"error":"只能查看本人匹配"}); return }
-    fast := false
-    if v := c.Query("fast"); v == "1" || v == "true" { fast = true }
+    m, ok := h.pickMatcher(c)
+    if !ok { return }
     topK := 0
     if v := c.Query("top_k"); v != "" { if n, e := strconv.Atoi(v); e==nil { topK = n } }
-    var res []domain.MatchResult
-    if fast || topK > 0 {
-        res, err = h.svc.MatchForStudentOpt(sid, fast, topK)
-    } else {
-        res, err = h.svc.MatchForStudent(sid)
-    }
+    res, err := h.svc.MatchForStudentOpt(sid, m, topK)
     if err != nil { c.JSON(404, gin.H{"error": err.Error()}); return }
     c.JSON(200, res)
 }
@@ -220,73 +261,79 @@ func (h *Handlers) AnalyzeApplications(c *gin.Context) {
     if err != nil { c.JSON(400, gin.H{"error":"teacher_id格式错误"}); return }
     cu := currentUser(c)
     if cu != nil && cu.Role == domain.RoleTeacher && cu.ID != tid { c.JSON(403, gin.H{"error":"只能分析本人项目的申请"}); return }
-    fast := false
-    if v := c.Query("fast"); v == "1" || v == "true" { fast = true }
-    views, err := h.svc.AnalyzeApplicationsForTeacher(tid, c.Query("project_id"), fast)
+    m, ok := h.pickMatcher(c)
+    if !ok { return }
+    views, err := h.svc.AnalyzeApplicationsForTeacher(tid, c.Query("project_id"), m)
     if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
     c.JSON(200, views)
 }
 
+// statusFilter rejects unknown status filters instead of silently matching nothing.
+func statusFilter(c *gin.Context) (string, bool) {
+    st := c.Query("status")
+    if st != "" && !domain.ValidApplicationStatus(st) { c.JSON(400, gin.H{"error":"无效状态: " + st}); return "", false }
+    return st, true
+}
+
 func (h *Handlers) ListApplications(c *gin.Context) {
+    status, ok := statusFilter(c)
+    if !ok { return }
     page := 1; size := 50
     if v := c.Query("page"); v != "" { if n, err := strconv.Atoi(v); err==nil && n>0 { page=n } }
     if v := c.Query("page_size"); v != "" { if n, err := strconv.Atoi(v); err==nil && n>0 { size=n } }
-    fast := false
-    if v := c.Query("fast"); v == "1" || v == "true" { fast = true }
-    views, err := h.svc.ListApplicationsWithScoresOpt(c.Query("project_id"), c.Query("status"), page, size, fast)
+    m, ok := h.pickMatcher(c)
+    if !ok { return }
+    views, err := h.svc.ListApplicationsWithScoresOpt(c.Query("project_id"), status, page, size, m)
     if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    h.hideSubmissions(currentUser(c), views)
+    h.fillUnread(currentUser(c), views)
     c.JSON(200, views)
 }
 
+// hideSubmissions blanks the cover letters and answers the viewer may not read.
+func (h *Handlers) hideSubmissions(u *domain.User, views []domain.ApplicationView) {
+    for i := range views {
+        a := views[i].Application
+        if a == nil || h.authz.SeesSubmission(u, a) { continue }
+        cp := *a
+        cp.CoverLetter, cp.Answers = "", nil
+        views[i].Application = &cp
+    }
+}
+
 func (h *Handlers) ListMyApplications(c *gin.Context) {
     cu := currentUser(c)
     if cu == nil || cu.Role != domain.RoleStudent { c.JSON(403, gin.H{"error":"无权限"}); return }
+    status, ok := statusFilter(c)
+    if !ok { return }
     page := 1; size := 50
     if v := c.Query("page"); v != "" { if n, err := strconv.Atoi(v); err==nil && n>0 { page=n } }
     if v := c.Query("page_size"); v != "" { if n, err := strconv.Atoi(v); err==nil && n>0 { size=n } }
-    fast := false
-    if v := c.Query("fast"); v == "1" || v == "true" { fast = true }
     computeScores := true
     if v := c.Query("scores"); v == "0" || v == "false" { computeScores = false }
     var views []domain.ApplicationView
     var err error
     if !computeScores {
-        views, err = h.svc.ListStudentApplicationsPlain(cu.ID, c.Query("status"))
-    } else if fast {
-        views, err = h.svc.ListStudentApplicationsWithScoresOpt(cu.ID, c.Query("status"), true)
+        views, err = h.svc.ListStudentApplicationsPlain(cu.ID, status)
     } else {
-        views, err = h.svc.ListStudentApplicationsWithScores(cu.ID, c.Query("status"))
+        m, ok := h.pickMatcher(c)
+        if !ok { return }
+        views, err = h.svc.ListStudentApplicationsWithScoresOpt(cu.ID, status, m)
     }
     if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
     start := (page-1)*size; if start < 0 { start = 0 }
     end := start+size; if end > len(views) { end = len(views) }
     if start > len(views) { views = []domain.ApplicationView{} } else { views = views[start:end] }
+    h.fillUnread(cu, views)
     c.JSON(200, views)
 }
 
-func (h *Handlers) UpdateApplicationStatus(c *gin.Context) {
-    var b struct { ApplicationID int64 `json:"application_id"`; Status string `json:"status"` }
-    if !parseJSON(c, &b) { return }
-    if cu := currentUser(c); cu != nil && cu.Role == domain.RoleTeacher {
-        app := h.svc.Repo().GetApplication(b.ApplicationID)
-        if app == nil { c.JSON(404, gin.H{"error":"申请不存在"}); return }
-        proj := h.svc.Repo().GetProject(app.ProjectID)
-        if proj == nil || proj.TeacherID != cu.ID { c.JSON(403, gin.H{"error":"无权修改该申请"}); return }
-    }
-    if err := h.svc.UpdateApplicationStatus(b.ApplicationID, b.Status); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
-    c.JSON(200, gin.H{"ok": true})
-}
-
 func (h *Handlers) ListTrackings(c *gin.Context) {
     appIDStr := c.Query("application_id")
     if appIDStr == "" { c.JSON(400, gin.H{"error":"缺少application_id"}); return }
     appID, err := strconv.ParseInt(appIDStr, 10, 64)
     if err != nil { c.JSON(400, gin.H{"error":"application_id格式错误"}); return }
-    if cu := currentUser(c); cu != nil && cu.Role == domain.RoleStudent {
-        app := h.svc.Repo().GetApplication(appID)
-        if app == nil { c.JSON(404, gin.H{"error":"申请不存在"}); return }
-        if app.StudentID != cu.ID { c.JSON(403, gin.H{"error":"无权查看该申请进度"}); return }
-    }
+    if _, err := h.authz.ApplicationByID(currentUser(c), policy.ActRead, appID); deny(c, err) { return }
     ts := h.svc.ListTrackingsByApplication(appID)
     c.JSON(200, ts)
 }
@@ -0,0 +1,60 @@
+package handle
+
+import (
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/auth"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+type ImpersonationHandlers struct {
+    imp *service.ImpersonationService
+}
+
+func NewImpersonationHandlers(i *service.ImpersonationService) *ImpersonationHandlers {
+    return &ImpersonationHandlers{imp: i}
+}
+
+// Start needs an admin session; personal access tokens cannot impersonate.
+func (h *ImpersonationHandlers) Start(c *gin.Context) {
+    if auth.CurrentToken(c) != nil { c.JSON(403, gin.H{"error":"访问令牌不能用于模拟用户"}); return }
+    var b struct{ UserID int64 `json:"user_id"`; Reason string `json:"reason"` }
+    if !parseJSON(c, &b) { return }
+    token, cl, u, err := h.imp.Start(currentUser(c), b.UserID, b.Reason, c.ClientIP())
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"token": token, "expires_in": int(time.Until(cl.ExpiresAt.Time).Seconds()), "user": u, "impersonation": true})
+}
+
+func (h *ImpersonationHandlers) Audit(c *gin.Context) {
+    var f repository.AuditFilter
+    if v := c.Query("actor_id"); v != "" { f.ActorID, _ = strconv.ParseInt(v, 10, 64) }
+    if v := c.Query("subject_id"); v != "" { f.SubjectID, _ = strconv.ParseInt(v, 10, 64) }
+    if v := c.Query("limit"); v != "" { f.Limit, _ = strconv.Atoi(v) }
+    f.TokenID = c.Query("token_id")
+    c.JSON(200, h.imp.Audit(f))
+}
+
+type impersonationBanner struct {
+    AdminID   int64     `json:"admin_id"`
+    AdminName string    `json:"admin_name"`
+    ExpiresAt time.Time `json:"expires_at"`
+    ReadOnly  bool      `json:"read_only"`
+}
+
+// meView is /api/me: the user plus, while impersonating, who is really looking.
+type meView struct {
+    *domain.User
+    Impersonation *impersonationBanner `json:"impersonation,omitempty"`
+}
+
+func newMeView(c *gin.Context, u *domain.User) meView {
+    v := meView{User: u}
+    if a := auth.CurrentImpersonator(c); a != nil {
+        v.Impersonation = &impersonationBanner{AdminID: a.ID, AdminName: a.Name, ExpiresAt: auth.CurrentClaims(c).ExpiresAt.Time, ReadOnly: true}
+    }
+    return v
+}
@@ -0,0 +1,101 @@
+package handle
+
+import (
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/ical"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/policy"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+type InterviewHandlers struct {
+    authz      *service.Authorizer
+    interviews *service.InterviewService
+}
+
+func NewInterviewHandlers(az *service.Authorizer, i *service.InterviewService) *InterviewHandlers {
+    return &InterviewHandlers{authz: az, interviews: i}
+}
+
+// supervises checks that the user may run interviews for the project's applicants.
+func (h *InterviewHandlers) supervises(c *gin.Context, projectID int64) bool {
+    return !deny(c, h.authz.Application(currentUser(c), policy.ActDecide, &domain.Application{ProjectID: projectID}))
+}
+
+// Slots shows supervisors every slot with its booking and students only what they may book.
+func (h *InterviewHandlers) Slots(c *gin.Context) {
+    pid, err := strconv.ParseInt(c.Query("project_id"), 10, 64)
+    if err != nil { c.JSON(400, gin.H{"error":"project_id格式错误"}); return }
+    cu := currentUser(c)
+    if cu.Role == domain.RoleStudent { c.JSON(200, h.interviews.OpenSlots(cu.ID, pid)); return }
+    if !h.supervises(c, pid) { return }
+    c.JSON(200, h.interviews.ProjectSlots(pid))
+}
+
+func (h *InterviewHandlers) AddSlots(c *gin.Context) {
+    var b struct { ProjectID int64 `json:"project_id"`; Slots []*domain.InterviewSlot `json:"slots"` }
+    if !parseJSON(c, &b) { return }
+    if !h.supervises(c, b.ProjectID) { return }
+    out, err := h.interviews.AddSlots(currentUser(c), b.ProjectID, b.Slots)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(201, out)
+}
+
+func (h *InterviewHandlers) DeleteSlot(c *gin.Context) {
+    id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+    if err != nil { c.JSON(400, gin.H{"error":"id格式错误"}); return }
+    sl := h.interviews.Slot(id)
+    if sl == nil { c.JSON(404, gin.H{"error":"面试时段不存在"}); return }
+    if !h.supervises(c, sl.ProjectID) { return }
+    if err := h.interviews.DeleteSlot(id); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+func (h *InterviewHandlers) Invite(c *gin.Context) {
+    var b struct { ApplicationID int64 `json:"application_id"`; Message string `json:"message"` }
+    if !parseJSON(c, &b) { return }
+    cu := currentUser(c)
+    if _, err := h.authz.ApplicationByID(cu, policy.ActDecide, b.ApplicationID); deny(c, err) { return }
+    app, err := h.interviews.Invite(cu, b.ApplicationID, b.Message)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true, "application": app})
+}
+
+// Book also reschedules: booking another slot releases the previous one.
+func (h *InterviewHandlers) Book(c *gin.Context) {
+    var b struct { ApplicationID int64 `json:"application_id"`; SlotID int64 `json:"slot_id"` }
+    if !parseJSON(c, &b) { return }
+    sl, err := h.interviews.Book(currentUser(c).ID, b.ApplicationID, b.SlotID)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, sl)
+}
+
+func (h *InterviewHandlers) Calendar(c *gin.Context) { h.writeCalendar(c, currentUser(c)) }
+
+// Feed serves the calendar to subscribing clients, which authenticate by the token in the URL.
+func (h *InterviewHandlers) Feed(c *gin.Context) {
+    u, err := h.interviews.FeedUser(c.Param("token"))
+    if err != nil { c.JSON(404, gin.H{"error": err.Error()}); return }
+    h.writeCalendar(c, u)
+}
+
+func (h *InterviewHandlers) writeCalendar(c *gin.Context, u *domain.User) {
+    c.Header("Content-Type", "text/calendar; charset=utf-8")
+    c.Header("Content-Disposition", `attachment; filename="interviews.ics"`)
+    c.Status(200)
+    _ = ical.Write(c.Writer, "面试安排", h.interviews.Calendar(u))
+}
+
+// NewFeed issues a calendar feed URL and revokes the previous one.
+func (h *InterviewHandlers) NewFeed(c *gin.Context) {
+    raw, err := h.interviews.NewFeedToken(currentUser(c).ID)
+    if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+    c.JSON(201, gin.H{"url": "/api/interviews/feed/" + raw + "/calendar.ics"})
+}
+
+func (h *InterviewHandlers) RevokeFeed(c *gin.Context) {
+    if err := h.interviews.RevokeFeedToken(currentUser(c).ID); err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
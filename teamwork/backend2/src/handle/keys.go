@@ -0,0 +1,30 @@
+package handle
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/auth"
+)
+
+type KeyHandlers struct { keys *auth.KeySet }
+
+func NewKeyHandlers(ks *auth.KeySet) *KeyHandlers { return &KeyHandlers{keys: ks} }
+
+func (h *KeyHandlers) JWKS(c *gin.Context) {
+    c.Header("Cache-Control", "public, max-age=300")
+    c.JSON(200, h.keys.JWKS())
+}
+
+func (h *KeyHandlers) List(c *gin.Context) { c.JSON(200, h.keys.Keys()) }
+
+func (h *KeyHandlers) Rotate(c *gin.Context) {
+    if err := h.keys.Rotate(); err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+func (h *KeyHandlers) Retire(c *gin.Context) {
+    var b struct{ KID string `json:"kid"` }
+    if !parseJSON(c, &b) { return }
+    if b.KID == "" { c.JSON(400, gin.H{"error":"缺少kid"}); return }
+    if err := h.keys.Retire(b.KID); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
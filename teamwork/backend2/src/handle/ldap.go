@@ -0,0 +1,28 @@
+package handle
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+type LDAPHandlers struct {
+    ldap  *service.LDAPAuthenticator
+    locks *service.LockoutService
+}
+
+func NewLDAPHandlers(ldap *service.LDAPAuthenticator, ls *service.LockoutService) *LDAPHandlers {
+    return &LDAPHandlers{ldap: ldap, locks: ls}
+}
+
+// Link lets a logged-in local account log in through LDAP from now on. The directory
+// password is checked under the same lockout as logins.
+func (h *LDAPHandlers) Link(c *gin.Context) {
+    u := currentUser(c)
+    if u == nil { c.JSON(401, gin.H{"error":"未认证"}); return }
+    var b struct{ Password string `json:"password"` }
+    if !parseJSON(c, &b) { return }
+    err := h.locks.Guard(u.Email, c.ClientIP(), func() error { return h.ldap.Link(u, b.Password) })
+    if abortLocked(c, err) { return }
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"linked": true})
+}
@@ -0,0 +1,69 @@
+package handle
+
+import (
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/policy"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+type LifecycleHandlers struct {
+    authz *service.Authorizer
+    life  *service.LifecycleService
+}
+
+func NewLifecycleHandlers(az *service.Authorizer, l *service.LifecycleService) *LifecycleHandlers {
+    return &LifecycleHandlers{authz: az, life: l}
+}
+
+func (h *LifecycleHandlers) Apply(c *gin.Context) {
+    var a domain.Application
+    if !parseJSON(c, &a) { return }
+    cu := currentUser(c)
+    if deny(c, h.authz.Application(cu, policy.ActCreate, &a)) { return }
+    created, err := h.life.Submit(cu, &a)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(201, created)
+}
+
+func (h *LifecycleHandlers) UpdateStatus(c *gin.Context) {
+    var b struct { ApplicationID int64 `json:"application_id"`; Status string `json:"status"`; Reason string `json:"reason"` }
+    if !parseJSON(c, &b) { return }
+    cu := currentUser(c)
+    if _, err := h.authz.ApplicationByID(cu, policy.ActDecide, b.ApplicationID); deny(c, err) { return }
+    app, err := h.life.Transition(cu, b.ApplicationID, b.Status, b.Reason)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true, "application": app, "next": service.AllowedTransitions(app.Status, cu.Role)})
+}
+
+// Bulk decides many applications at once and answers with one result per application.
+func (h *LifecycleHandlers) Bulk(c *gin.Context) {
+    var d service.BulkDecision
+    if !parseJSON(c, &d) { return }
+    cu := currentUser(c)
+    if d.ProjectID != 0 && deny(c, h.authz.Application(cu, policy.ActDecide, &domain.Application{ProjectID: d.ProjectID})) { return }
+    results, err := h.life.Bulk(cu, d, func(app *domain.Application) error { return h.authz.Application(cu, policy.ActDecide, app) })
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    ok := 0
+    for _, r := range results { if r.OK { ok++ } }
+    c.JSON(200, gin.H{"results": results, "succeeded": ok, "failed": len(results) - ok})
+}
+
+func (h *LifecycleHandlers) History(c *gin.Context) {
+    id, err := strconv.ParseInt(c.Query("application_id"), 10, 64)
+    if err != nil { c.JSON(400, gin.H{"error":"application_id格式错误"}); return }
+    if _, err := h.authz.ApplicationByID(currentUser(c), policy.ActRead, id); deny(c, err) { return }
+    c.JSON(200, h.life.History(id))
+}
+
+func (h *LifecycleHandlers) Withdraw(c *gin.Context) {
+    var b struct { ApplicationID int64 `json:"application_id"`; Reason string `json:"reason"` }
+    if !parseJSON(c, &b) { return }
+    cu := currentUser(c)
+    if _, err := h.authz.ApplicationByID(cu, policy.ActWithdraw, b.ApplicationID); deny(c, err) { return }
+    app, err := h.life.Withdraw(cu, b.ApplicationID, b.Reason)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true, "application": app})
+}
@@ -0,0 +1,88 @@
+package handle
+
+import (
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+type PersonalTokenHandlers struct {
+    pats *service.PersonalTokenService
+}
+
+func NewPersonalTokenHandlers(p *service.PersonalTokenService) *PersonalTokenHandlers {
+    return &PersonalTokenHandlers{pats: p}
+}
+
+type tokenRequest struct {
+    Name          string   `json:"name"`
+    Scopes        []string `json:"scopes"`
+    ExpiresInDays int      `json:"expires_in_days"`
+}
+
+func (h *PersonalTokenHandlers) List(c *gin.Context) {
+    u := currentUser(c)
+    if u == nil { c.JSON(401, gin.H{"error":"未认证"}); return }
+    c.JSON(200, h.pats.List(u.ID))
+}
+
+// Create answers the plain token once; only its prefix is shown afterwards.
+func (h *PersonalTokenHandlers) Create(c *gin.Context) {
+    u := currentUser(c)
+    if u == nil { c.JSON(401, gin.H{"error":"未认证"}); return }
+    var b tokenRequest
+    if !parseJSON(c, &b) { return }
+    tok, t, err := h.pats.Create(u.ID, b.Name, b.Scopes, b.ExpiresInDays)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(201, gin.H{"token": tok, "info": t})
+}
+
+func (h *PersonalTokenHandlers) Revoke(c *gin.Context) {
+    u := currentUser(c)
+    if u == nil { c.JSON(401, gin.H{"error":"未认证"}); return }
+    id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+    if err != nil { c.JSON(400, gin.H{"error":"id格式错误"}); return }
+    if err := h.pats.Revoke(u.ID, id); err != nil { c.JSON(404, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+func (h *PersonalTokenHandlers) ListServiceAccounts(c *gin.Context) {
+    c.JSON(200, h.pats.ServiceAccounts())
+}
+
+func (h *PersonalTokenHandlers) CreateServiceAccount(c *gin.Context) {
+    var b struct{ Name string `json:"name"`; Role string `json:"role"` }
+    if !parseJSON(c, &b) { return }
+    u, err := h.pats.CreateServiceAccount(b.Name, domain.Role(b.Role))
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(201, u)
+}
+
+func (h *PersonalTokenHandlers) ListServiceTokens(c *gin.Context) {
+    id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+    if err != nil { c.JSON(400, gin.H{"error":"id格式错误"}); return }
+    ts, err := h.pats.ServiceTokens(id)
+    if err != nil { c.JSON(404, gin.H{"error": err.Error()}); return }
+    c.JSON(200, ts)
+}
+
+func (h *PersonalTokenHandlers) CreateServiceToken(c *gin.Context) {
+    id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+    if err != nil { c.JSON(400, gin.H{"error":"id格式错误"}); return }
+    var b tokenRequest
+    if !parseJSON(c, &b) { return }
+    tok, t, err := h.pats.CreateServiceToken(id, b.Name, b.Scopes, b.ExpiresInDays)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(201, gin.H{"token": tok, "info": t})
+}
+
+func (h *PersonalTokenHandlers) RevokeServiceToken(c *gin.Context) {
+    id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+    if err != nil { c.JSON(400, gin.H{"error":"id格式错误"}); return }
+    tid, err := strconv.ParseInt(c.Param("tid"), 10, 64)
+    if err != nil { c.JSON(400, gin.H{"error":"id格式错误"}); return }
+    if err := h.pats.RevokeServiceToken(id, tid); err != nil { c.JSON(404, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
@@ -0,0 +1,9 @@
+package handle
+
+import "github.com/gin-gonic/gin"
+
+// PolicyMatrix lists the active rules and the resulting permission matrix.
+func (h *Handlers) PolicyMatrix(c *gin.Context) {
+    p := h.authz.Policy()
+    c.JSON(200, gin.H{"rules": p.Rules(), "matrix": p.Matrix()})
+}
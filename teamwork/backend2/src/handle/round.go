@@ -0,0 +1,42 @@
+package handle
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+type RoundHandlers struct {
+    rounds *service.RoundService
+}
+
+func NewRoundHandlers(r *service.RoundService) *RoundHandlers { return &RoundHandlers{rounds: r} }
+
+func (h *RoundHandlers) List(c *gin.Context) { c.JSON(200, h.rounds.List()) }
+
+// Current answers null when no round is open.
+func (h *RoundHandlers) Current(c *gin.Context) { c.JSON(200, h.rounds.Current()) }
+
+func (h *RoundHandlers) Create(c *gin.Context) {
+    var rd domain.Round
+    if !parseJSON(c, &rd) { return }
+    out, err := h.rounds.Create(&rd)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(201, out)
+}
+
+func (h *RoundHandlers) Update(c *gin.Context) {
+    var rd domain.Round
+    if !parseJSON(c, &rd) { return }
+    if rd.ID == 0 { c.JSON(400, gin.H{"error":"缺少轮次ID"}); return }
+    out, err := h.rounds.Update(&rd)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, out)
+}
+
+func (h *RoundHandlers) Close(c *gin.Context) {
+    var b struct{ ID int64 `json:"id"` }
+    if !parseJSON(c, &b) { return }
+    if err := h.rounds.Close(b.ID); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
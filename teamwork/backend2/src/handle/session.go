@@ -0,0 +1,76 @@
+package handle
+
+import (
+    "errors"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/auth"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+type SessionHandlers struct {
+    svc    *service.Service
+    tokens *service.TokenService
+    locks  *service.LockoutService
+    mfa    *service.TwoFactorService
+}
+
+func NewSessionHandlers(s *service.Service, ts *service.TokenService, ls *service.LockoutService, mfa *service.TwoFactorService) *SessionHandlers {
+    return &SessionHandlers{svc: s, tokens: ts, locks: ls, mfa: mfa}
+}
+
+// abortLocked answers 429 with Retry-After when err is a lockout.
+func abortLocked(c *gin.Context, err error) bool {
+    var locked *service.LockedError
+    if !errors.As(err, &locked) { return false }
+    c.Header("Retry-After", strconv.Itoa(int(time.Until(locked.Until).Seconds())+1))
+    c.JSON(429, gin.H{"error": err.Error()})
+    return true
+}
+
+func (h *SessionHandlers) Login(c *gin.Context) {
+    var b struct{ Email string `json:"email"`; Password string `json:"password"` }
+    if !parseJSON(c, &b) { return }
+    u, err := h.locks.Login(b.Email, b.Password, c.ClientIP())
+    if abortLocked(c, err) { return }
+    if errors.Is(err, service.ErrAuthUnavailable) { c.JSON(503, gin.H{"error": err.Error()}); return }
+    if err != nil { c.JSON(401, gin.H{"error": err.Error()}); return }
+    res, err := h.mfa.BeginLogin(u)
+    if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+    if res.TokenPair == nil {
+        // second step: POST /api/auth/2fa/verify (or /enroll/*) with the challenge as bearer token
+        c.JSON(200, gin.H{"mfa": res.MFA, "challenge_token": res.ChallengeToken})
+        return
+    }
+    c.JSON(200, gin.H{"token": res.Token, "refresh_token": res.RefreshToken, "expires_in": res.ExpiresIn, "user": u})
+}
+
+func (h *SessionHandlers) Refresh(c *gin.Context) {
+    var b struct{ RefreshToken string `json:"refresh_token"` }
+    if !parseJSON(c, &b) { return }
+    res, u, err := h.mfa.Refresh(b.RefreshToken)
+    if err != nil { c.JSON(401, gin.H{"error": err.Error()}); return }
+    if res.TokenPair == nil {
+        // 2FA became mandatory for the role: the client continues like a login challenge
+        c.JSON(200, gin.H{"mfa": res.MFA, "challenge_token": res.ChallengeToken})
+        return
+    }
+    c.JSON(200, gin.H{"token": res.Token, "refresh_token": res.RefreshToken, "expires_in": res.ExpiresIn, "user": u})
+}
+
+func (h *SessionHandlers) Logout(c *gin.Context) {
+    var b struct{ RefreshToken string `json:"refresh_token"` }
+    _ = c.ShouldBindJSON(&b)
+    if err := h.tokens.Logout(auth.CurrentClaims(c), b.RefreshToken); err != nil { c.JSON(401, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+// LogoutAll revokes every session of the current user.
+func (h *SessionHandlers) LogoutAll(c *gin.Context) {
+    u := currentUser(c)
+    if u == nil { c.JSON(401, gin.H{"error":"未认证"}); return }
+    if err := h.tokens.RevokeUser(u.ID); err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
@@ -0,0 +1,47 @@
+package handle
+
+import (
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+type SkillHandlers struct {
+    skills *service.SkillService
+}
+
+func NewSkillHandlers(s *service.SkillService) *SkillHandlers { return &SkillHandlers{skills: s} }
+
+// Suggest autocompletes skill names and aliases: ?q=<text>&limit=<n>.
+func (h *SkillHandlers) Suggest(c *gin.Context) {
+    limit, _ := strconv.Atoi(c.Query("limit"))
+    c.JSON(200, h.skills.Suggest(c.Query("q"), limit))
+}
+
+func (h *SkillHandlers) List(c *gin.Context) { c.JSON(200, h.skills.List()) }
+
+func (h *SkillHandlers) Create(c *gin.Context) {
+    var sk domain.Skill
+    if !parseJSON(c, &sk) { return }
+    out, err := h.skills.Create(&sk)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(201, out)
+}
+
+func (h *SkillHandlers) Update(c *gin.Context) {
+    var sk domain.Skill
+    if !parseJSON(c, &sk) { return }
+    if sk.ID == 0 { c.JSON(400, gin.H{"error":"缺少技能ID"}); return }
+    out, err := h.skills.Update(&sk)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, out)
+}
+
+func (h *SkillHandlers) Delete(c *gin.Context) {
+    id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+    if err != nil { c.JSON(400, gin.H{"error":"id格式错误"}); return }
+    if err := h.skills.Delete(id); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
@@ -0,0 +1,62 @@
+package handle
+
+import (
+    "errors"
+    "log"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+// ssoStateCookie binds a started OIDC login to the browser that started it.
+const (
+    ssoStateCookie = "sso_state"
+    ssoCookiePath  = "/api/auth/oidc"
+)
+
+type SSOHandlers struct { sso *service.SSOService }
+
+func NewSSOHandlers(sso *service.SSOService) *SSOHandlers { return &SSOHandlers{sso: sso} }
+
+func (h *SSOHandlers) Status(c *gin.Context) { c.JSON(200, gin.H{"enabled": h.sso.Enabled()}) }
+
+func (h *SSOHandlers) Login(c *gin.Context) {
+    u, state, err := h.sso.Begin(c.Request.Context())
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    // Lax, so that the cookie comes along on the top-level redirect back from the IdP
+    c.SetSameSite(http.SameSiteLaxMode)
+    c.SetCookie(ssoStateCookie, state, int(h.sso.StateTTL().Seconds()), ssoCookiePath, "", c.Request.TLS != nil, true)
+    c.Redirect(302, u)
+}
+
+// Callback hands the tokens (or the 2FA challenge) to the frontend in the URL fragment,
+// which browsers never send to servers. Errors are reported as fixed codes only:
+// invalid_state, provider_error or login_failed.
+func (h *SSOHandlers) Callback(c *gin.Context) {
+    browserState, _ := c.Cookie(ssoStateCookie)
+    c.SetCookie(ssoStateCookie, "", -1, ssoCookiePath, "", c.Request.TLS != nil, true)
+    v := url.Values{}
+    if e := c.Query("error"); e != "" {
+        log.Printf("oidc callback: provider error %q", e)
+        v.Set("error", "provider_error")
+    } else if res, _, err := h.sso.Callback(c.Request.Context(), c.Query("state"), browserState, c.Query("code")); err != nil {
+        if errors.Is(err, service.ErrSSOState) {
+            v.Set("error", "invalid_state")
+        } else {
+            log.Printf("oidc callback: %v", err)
+            v.Set("error", "login_failed")
+        }
+    } else if res.TokenPair == nil {
+        v.Set("mfa", res.MFA)
+        v.Set("challenge_token", res.ChallengeToken)
+    } else {
+        v.Set("token", res.Token)
+        v.Set("refresh_token", res.RefreshToken)
+        v.Set("expires_in", strconv.FormatInt(res.ExpiresIn, 10))
+    }
+    c.Redirect(302, strings.TrimRight(h.sso.FrontendURL(), "/")+"/sso/callback#"+v.Encode())
+}
@@ -0,0 +1,107 @@
+package handle
+
+import (
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+type TeamHandlers struct {
+    teams    *service.TeamService
+    matchers *service.MatcherRegistry
+}
+
+func NewTeamHandlers(t *service.TeamService, reg *service.MatcherRegistry) *TeamHandlers {
+    return &TeamHandlers{teams: t, matchers: reg}
+}
+
+// teamID parses :id and, for students, checks that they belong to the team.
+func (h *TeamHandlers) teamID(c *gin.Context) (int64, bool) {
+    id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+    if err != nil { c.JSON(400, gin.H{"error":"id格式错误"}); return 0, false }
+    if cu := currentUser(c); cu.Role == domain.RoleStudent && !h.teams.IsMember(id, cu.ID) { c.JSON(404, gin.H{"error":"团队不存在"}); return 0, false }
+    return id, true
+}
+
+func (h *TeamHandlers) Mine(c *gin.Context) { c.JSON(200, h.teams.Mine(currentUser(c).ID)) }
+
+func (h *TeamHandlers) Get(c *gin.Context) {
+    id, ok := h.teamID(c)
+    if !ok { return }
+    v, err := h.teams.Get(id)
+    if err != nil { c.JSON(404, gin.H{"error": err.Error()}); return }
+    c.JSON(200, v)
+}
+
+func (h *TeamHandlers) Create(c *gin.Context) {
+    var b struct{ Name string `json:"name"` }
+    if !parseJSON(c, &b) { return }
+    v, err := h.teams.Create(currentUser(c), b.Name)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(201, v)
+}
+
+func (h *TeamHandlers) Invite(c *gin.Context) {
+    id, ok := h.teamID(c)
+    if !ok { return }
+    var b struct{ Email string `json:"email"` }
+    if !parseJSON(c, &b) { return }
+    if err := h.teams.Invite(currentUser(c), id, b.Email); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+func (h *TeamHandlers) Accept(c *gin.Context) {
+    id, ok := h.teamID(c)
+    if !ok { return }
+    if err := h.teams.Accept(currentUser(c).ID, id); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+// Leave also declines a pending invitation.
+func (h *TeamHandlers) Leave(c *gin.Context) {
+    id, ok := h.teamID(c)
+    if !ok { return }
+    if err := h.teams.Leave(currentUser(c).ID, id); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+func (h *TeamHandlers) Remove(c *gin.Context) {
+    id, ok := h.teamID(c)
+    if !ok { return }
+    var b struct{ UserID int64 `json:"user_id"` }
+    if !parseJSON(c, &b) { return }
+    if err := h.teams.Remove(currentUser(c), id, b.UserID); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+func (h *TeamHandlers) Handover(c *gin.Context) {
+    id, ok := h.teamID(c)
+    if !ok { return }
+    var b struct{ UserID int64 `json:"user_id"` }
+    if !parseJSON(c, &b) { return }
+    if err := h.teams.Handover(currentUser(c), id, b.UserID); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+func (h *TeamHandlers) Disband(c *gin.Context) {
+    id, ok := h.teamID(c)
+    if !ok { return }
+    if err := h.teams.Disband(currentUser(c), id); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+// Matches scores projects against the team's combined skills with ?matcher=; only the
+// top_k (default 5) keyword matches are passed on to it.
+func (h *TeamHandlers) Matches(c *gin.Context) {
+    id, ok := h.teamID(c)
+    if !ok { return }
+    m, ok := pickMatcher(c, h.matchers)
+    if !ok { return }
+    topK := 5
+    if v := c.Query("top_k"); v != "" { if n, e := strconv.Atoi(v); e==nil { topK = n } }
+    res, err := h.teams.Match(id, m, topK)
+    if err != nil { c.JSON(404, gin.H{"error": err.Error()}); return }
+    c.JSON(200, res)
+}
@@ -0,0 +1,96 @@
+package handle
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/auth"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
+    "github.com/bugoutianzhen123/SoftwareConstructionExp/service"
+)
+
+type TwoFactorHandlers struct {
+    svc *service.Service
+    mfa *service.TwoFactorService
+}
+
+func NewTwoFactorHandlers(s *service.Service, mfa *service.TwoFactorService) *TwoFactorHandlers {
+    return &TwoFactorHandlers{svc: s, mfa: mfa}
+}
+
+type codeBody struct{ Code string `json:"code"` }
+
+// Verify completes a login whose challenge token is sent as the bearer token.
+func (h *TwoFactorHandlers) Verify(c *gin.Context) {
+    var b codeBody
+    if !parseJSON(c, &b) { return }
+    pair, u, err := h.mfa.VerifyLogin(auth.CurrentChallenge(c), b.Code)
+    if abortLocked(c, err) { return }
+    if err != nil { c.JSON(401, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"token": pair.Token, "refresh_token": pair.RefreshToken, "expires_in": pair.ExpiresIn, "user": u})
+}
+
+// EnrollStart works for a logged-in user and for an "enroll" login challenge.
+func (h *TwoFactorHandlers) EnrollStart(c *gin.Context) {
+    u := currentUser(c)
+    if cl := auth.CurrentChallenge(c); u == nil && cl != nil && cl.Purpose == auth.PurposeMFAEnroll {
+        u = h.svc.Repo().GetUser(cl.UserID)
+    }
+    if u == nil { c.JSON(401, gin.H{"error":"未认证"}); return }
+    secret, url, err := h.mfa.StartEnroll(u)
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"secret": secret, "otpauth_url": url})
+}
+
+func (h *TwoFactorHandlers) EnrollConfirm(c *gin.Context) {
+    var b codeBody
+    if !parseJSON(c, &b) { return }
+    if u := currentUser(c); u != nil {
+        codes, err := h.mfa.ConfirmEnroll(u, b.Code)
+        if abortLocked(c, err) { return }
+        if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+        c.JSON(200, gin.H{"recovery_codes": codes})
+        return
+    }
+    codes, pair, u, err := h.mfa.ConfirmEnrollChallenge(auth.CurrentChallenge(c), b.Code)
+    if abortLocked(c, err) { return }
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"recovery_codes": codes, "token": pair.Token, "refresh_token": pair.RefreshToken, "expires_in": pair.ExpiresIn, "user": u})
+}
+
+func (h *TwoFactorHandlers) Disable(c *gin.Context) {
+    var b codeBody
+    if !parseJSON(c, &b) { return }
+    err := h.mfa.Disable(currentUser(c), b.Code)
+    if abortLocked(c, err) { return }
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+func (h *TwoFactorHandlers) RecoveryCodes(c *gin.Context) {
+    var b codeBody
+    if !parseJSON(c, &b) { return }
+    codes, err := h.mfa.RegenerateRecoveryCodes(currentUser(c), b.Code)
+    if abortLocked(c, err) { return }
+    if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"recovery_codes": codes})
+}
+
+func (h *TwoFactorHandlers) Status(c *gin.Context) {
+    c.JSON(200, h.mfa.Status(currentUser(c)))
+}
+
+func (h *TwoFactorHandlers) AdminReset(c *gin.Context) {
+    var b struct{ UserID int64 `json:"user_id"` }
+    if !parseJSON(c, &b) { return }
+    if b.UserID == 0 { c.JSON(400, gin.H{"error":"缺少user_id"}); return }
+    if err := h.mfa.Reset(b.UserID); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
+
+func (h *TwoFactorHandlers) Policies(c *gin.Context) { c.JSON(200, h.mfa.Policies()) }
+
+func (h *TwoFactorHandlers) SetPolicy(c *gin.Context) {
+    var b struct{ Role string `json:"role"`; Required bool `json:"required"` }
+    if !parseJSON(c, &b) { return }
+    if err := h.mfa.SetPolicy(domain.Role(b.Role), b.Required); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+    c.JSON(200, gin.H{"ok": true})
+}
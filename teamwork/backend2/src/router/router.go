@@ -4,12 +4,17 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/bugoutianzhen123/SoftwareConstructionExp/auth"
 	"github.com/bugoutianzhen123/SoftwareConstructionExp/domain"
 	"github.com/bugoutianzhen123/SoftwareConstructionExp/handle"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/ldapdir"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/mailer"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/policy"
 	"github.com/bugoutianzhen123/SoftwareConstructionExp/repository"
+	"github.com/bugoutianzhen123/SoftwareConstructionExp/service"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
@@ -33,9 +38,94 @@ func TimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
     }
 }
 
-func NewRouter(h *handle.Handlers, ah *handle.AuthHandlers, repo repository.Repo) *gin.Engine {
+// Deps bundles the stores and services used by the routes beyond the core repository.
+type Deps struct {
+    Tokens      repository.TokenRepo
+    Keys        *auth.KeySet
+    Accounts    repository.AccountRepo
+    Lockouts    repository.LockoutRepo
+    TwoFactor   repository.TwoFactorRepo
+    SSO         repository.SSORepo
+    OIDC        *auth.OIDCProvider // nil when single sign-on is disabled
+    SSOOptions  service.SSOOptions
+    // AuthProviders selects the login chain ("local", "ldap"); LDAP is nil unless used.
+    AuthProviders []string
+    LDAP          ldapdir.Directory
+    LDAPOptions   service.LDAPOptions
+    PATs          repository.PATRepo
+    Policy        *policy.Policy // nil keeps policy.Default
+    Audit         repository.AuditRepo
+    Lifecycle     repository.LifecycleRepo
+    Reapply       service.ReapplyRules
+    Rounds        repository.RoundRepo
+    Allocations   repository.AllocationRepo
+    Interviews    repository.InterviewRepo
+    Teams         repository.TeamRepo
+    Comments      repository.CommentRepo
+    Matching      service.MatcherOptions
+    Skills        repository.SkillRepo
+    Mailer      mailer.Mailer
+    MailBaseURL string
+    // TrustedProxies decides whose X-Forwarded-For is believed, e.g. for login lockouts
+    // by client address; none by default.
+    TrustedProxies []string
+}
+
+// RunJobs runs the periodic background jobs until ctx is done. main starts it next to
+// the server, after NewRouter, and cancels ctx on shutdown.
+func RunJobs(ctx context.Context, h *handle.Handlers, d Deps) {
+    var wg sync.WaitGroup
+    jobs := []func(){
+        func() { d.Keys.RotateLoop(ctx, time.Minute) },
+        func() { service.NewTokenService(h.Service(), d.Tokens).PurgeLoop(ctx, time.Hour) },
+        func() { service.NewSkillService(h.Service(), d.Skills).ReloadLoop(ctx, time.Minute) },
+        func() {
+            life := service.NewLifecycleService(h.Service(), d.Lifecycle, d.Rounds, d.Teams, d.Mailer, d.Reapply)
+            service.NewRoundService(d.Rounds, life).CloseLoop(ctx, time.Minute)
+        },
+    }
+    for _, job := range jobs {
+        wg.Add(1)
+        go func() { defer wg.Done(); job() }()
+    }
+    wg.Wait()
+}
+
+func NewRouter(h *handle.Handlers, ah *handle.AuthHandlers, repo repository.Repo, d Deps) *gin.Engine {
+    auth.UseKeySet(d.Keys)
+    if d.Policy != nil { h.UsePolicy(d.Policy) }
+    skills := service.NewSkillService(h.Service(), d.Skills)
+    skh := handle.NewSkillHandlers(skills)
+    mreg, err := service.NewMatcherRegistry(h.Service(), d.Matching)
+    if err != nil { panic(err) }
+    h.UseMatchers(mreg)
+    kh := handle.NewKeyHandlers(d.Keys)
+    ts := service.NewTokenService(h.Service(), d.Tokens)
+    var ldapAuth *service.LDAPAuthenticator
+    if d.LDAP != nil { ldapAuth = service.NewLDAPAuthenticator(h.Service(), d.LDAP, d.LDAPOptions, d.SSO, d.Accounts, ts) }
+    authn, err := service.NewAuthChain(h.Service(), d.AuthProviders, ldapAuth)
+    if err != nil { panic(err) }
+    ls := service.NewLockoutService(authn, d.Lockouts)
+    mfa := service.NewTwoFactorService(h.Service(), d.TwoFactor, ts, d.Lockouts)
+    th := handle.NewTwoFactorHandlers(h.Service(), mfa)
+    sh := handle.NewSessionHandlers(h.Service(), ts, ls, mfa)
+    adm := handle.NewAdminHandlers(h.Service(), ts, ls)
+    ssoh := handle.NewSSOHandlers(service.NewSSOService(h.Service(), d.OIDC, d.SSO, d.Accounts, ts, mfa, d.SSOOptions))
+    pt := handle.NewPersonalTokenHandlers(service.NewPersonalTokenService(h.Service(), d.PATs))
+    imp := handle.NewImpersonationHandlers(service.NewImpersonationService(h.Service(), d.Audit))
+    lifecycle := service.NewLifecycleService(h.Service(), d.Lifecycle, d.Rounds, d.Teams, d.Mailer, d.Reapply)
+    life := handle.NewLifecycleHandlers(h.Authorizer(), lifecycle)
+    rounds := service.NewRoundService(d.Rounds, lifecycle)
+    rh := handle.NewRoundHandlers(rounds)
+    comments := service.NewCommentService(h.Service(), d.Comments, d.Mailer)
+    h.UseComments(comments)
+    cm := handle.NewCommentHandlers(h.Authorizer(), comments)
+    tm := handle.NewTeamHandlers(service.NewTeamService(h.Service(), d.Teams, d.Mailer), mreg)
+    ih := handle.NewInterviewHandlers(h.Authorizer(), service.NewInterviewService(h.Service(), d.Interviews, lifecycle, d.Accounts, d.Mailer))
+    alh := handle.NewAllocationHandlers(h.Authorizer(), service.NewAllocationService(h.Service(), d.Allocations, lifecycle), mreg)
+    acc := handle.NewAccountHandlers(h.Service(), service.NewAccountService(h.Service(), d.Accounts, ts, d.Mailer, d.MailBaseURL))
     r := gin.New()
-    r.SetTrustedProxies(nil)
+    if err := r.SetTrustedProxies(d.TrustedProxies); err != nil { panic(err) }
     r.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
         return fmt.Sprintf("%s | %3d | %13v | %15s | %-7s %s | %s\n",
             param.TimeStamp.Format(time.RFC3339),
@@ -57,57 +147,153 @@ func NewRouter(h *handle.Handlers, ah *handle.AuthHandlers, repo repository.Repo
         MaxAge:          12 * time.Hour,
     }))
     r.Use(TimeoutMiddleware(5 * time.Second))
+    r.GET("/.well-known/jwks.json", kh.JWKS)
     pub := r.Group("/api")
-    pub.POST("/auth/register", ah.Register)
-    pub.POST("/auth/login", ah.Login)
-    r.Use(auth.Middleware(repo))
+    pub.POST("/auth/register", acc.Register)
+    pub.POST("/auth/login", sh.Login)
+    pub.POST("/auth/refresh", sh.Refresh)
+    pub.POST("/auth/verify-email", acc.VerifyEmail)
+    pub.POST("/auth/password/forgot", acc.ForgotPassword)
+    pub.POST("/auth/password/reset", acc.ResetPassword)
+    pub.GET("/auth/oidc", ssoh.Status)
+    pub.GET("/auth/oidc/login", ssoh.Login)
+    pub.GET("/auth/oidc/callback", ssoh.Callback)
+    // calendar clients subscribe without a session; the revocable token in the path identifies the user
+    pub.GET("/interviews/feed/:token/calendar.ics", ih.Feed)
+    r.Use(auth.Middleware(repo, d.Tokens, d.PATs))
+    r.Use(auth.ImpersonationGuard(d.Audit))
     api := r.Group("/api")
-    users := api.Group("/users")
+    api.POST("/auth/logout", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), sh.Logout)
+    api.POST("/auth/logout/all", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), sh.LogoutAll)
+    api.POST("/auth/verify-email/resend", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), acc.ResendVerification)
+    // these three also accept a login challenge token instead of a session
+    api.POST("/auth/2fa/verify", th.Verify)
+    api.POST("/auth/2fa/enroll/start", th.EnrollStart)
+    api.POST("/auth/2fa/enroll/confirm", th.EnrollConfirm)
+    api.GET("/auth/2fa/status", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), th.Status)
+    api.POST("/auth/2fa/disable", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), th.Disable)
+    api.POST("/auth/2fa/recovery-codes", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), th.RecoveryCodes)
+    // personal access tokens; creating one needs a real session, not another token
+    api.GET("/tokens", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), pt.List)
+    api.POST("/tokens", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), pt.Create)
+    api.DELETE("/tokens/:id", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), pt.Revoke)
+    users := api.Group("/users", auth.RequireScope("users"))
     users.GET("", h.ListUsers)
-    users.POST("", auth.RequireRole(domain.RoleAdmin), h.CreateUser)
+    users.POST("", auth.RequireRole(domain.RoleAdmin), acc.CreateUser)
     users.GET("/:id", h.GetUser)
 
-    api.GET("/me", h.Me)
+    // the profile is its own token scope; without it a token of any scope could read and change it
+    api.GET("/me", auth.RequireScope("profile"), h.Me)
+    if ldapAuth != nil {
+        api.POST("/me/ldap-link", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), handle.NewLDAPHandlers(ldapAuth, ls).Link)
+    }
+    api.GET("/skills", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), skh.Suggest)
 
-    projects := api.Group("/projects")
+    projects := api.Group("/projects", auth.RequireScope("projects"))
     projects.GET("", h.ListProjects)
     projects.POST("", auth.RequireRole(domain.RoleTeacher, domain.RoleAdmin), h.CreateProject)
     projects.PATCH("", auth.RequireRole(domain.RoleTeacher, domain.RoleAdmin), h.UpdateProject)
     projects.DELETE("", auth.RequireRole(domain.RoleTeacher, domain.RoleAdmin), h.DeleteProject)
     projects.POST("/archive", auth.RequireRole(domain.RoleTeacher, domain.RoleAdmin), h.ArchiveProject)
 
-    matches := api.Group("/matches")
+    matches := api.Group("/matches", auth.RequireScope("matches"))
     matches.GET("", auth.RequireRole(domain.RoleStudent, domain.RoleAdmin), h.Matches)
     matches.GET("/analyze", auth.RequireRole(domain.RoleTeacher, domain.RoleAdmin), h.AnalyzeApplications)
+    matches.GET("/matchers", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), h.ListMatchers)
     
-    applications := api.Group("/applications")
+    applications := api.Group("/applications", auth.RequireScope("applications"))
     applications.GET("", auth.RequireRole(domain.RoleTeacher, domain.RoleAdmin), h.ListApplications)
     applications.GET("/mine", auth.RequireRole(domain.RoleStudent), h.ListMyApplications)
+    applications.POST("/withdraw", auth.RequireRole(domain.RoleStudent, domain.RoleAdmin), life.Withdraw)
+    applications.GET("/history", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), life.History)
+    applications.GET("/preferences", auth.RequireRole(domain.RoleStudent), alh.MyPreferences)
+    applications.POST("/preferences", auth.RequireRole(domain.RoleStudent), alh.Preferences)
+    applications.GET("/ranking", auth.RequireRole(domain.RoleTeacher, domain.RoleAdmin), alh.ProjectRanking)
+    applications.POST("/ranking", auth.RequireRole(domain.RoleTeacher, domain.RoleAdmin), alh.Ranking)
+    applications.GET("/comments", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), cm.Thread)
+    applications.POST("/comments", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), cm.Post)
+    applications.PATCH("/comments/:id", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), cm.Edit)
+    applications.DELETE("/comments/:id", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), cm.Delete)
+    applications.POST("/comments/read", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), cm.MarkRead)
 
-    application := api.Group("/application")
-    application.POST("/status", auth.RequireRole(domain.RoleTeacher, domain.RoleAdmin), h.UpdateApplicationStatus)
+    application := api.Group("/application", auth.RequireScope("applications"))
+    application.POST("/status", auth.RequireRole(domain.RoleTeacher, domain.RoleAdmin), life.UpdateStatus)
+    application.POST("/status/bulk", auth.RequireRole(domain.RoleTeacher, domain.RoleAdmin), life.Bulk)
 
     
-    tracking := api.Group("/tracking")
+    tracking := api.Group("/tracking", auth.RequireScope("tracking"))
     tracking.POST("", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), h.Tracking)
     tracking.GET("", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), h.ListTrackings)
 
-    feedback := api.Group("/feedback")
+    feedback := api.Group("/feedback", auth.RequireScope("feedback"))
     feedback.POST("", auth.RequireRole(domain.RoleTeacher, domain.RoleAdmin), h.Feedback)
 
-    apply := api.Group("/apply")
-    apply.POST("", auth.RequireRole(domain.RoleStudent), h.Apply)
+    teams := api.Group("/teams", auth.RequireScope("teams"))
+    teams.GET("", auth.RequireRole(domain.RoleStudent), tm.Mine)
+    teams.POST("", auth.RequireRole(domain.RoleStudent), tm.Create)
+    teams.GET("/:id", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), tm.Get)
+    teams.DELETE("/:id", auth.RequireRole(domain.RoleStudent), tm.Disband)
+    teams.POST("/:id/invite", auth.RequireRole(domain.RoleStudent), tm.Invite)
+    teams.POST("/:id/accept", auth.RequireRole(domain.RoleStudent), tm.Accept)
+    teams.POST("/:id/leave", auth.RequireRole(domain.RoleStudent), tm.Leave)
+    teams.POST("/:id/remove", auth.RequireRole(domain.RoleStudent), tm.Remove)
+    teams.POST("/:id/handover", auth.RequireRole(domain.RoleStudent), tm.Handover)
+    teams.GET("/:id/matches", auth.RequireRole(domain.RoleStudent, domain.RoleAdmin), tm.Matches)
+
+    interviews := api.Group("/interviews", auth.RequireScope("interviews"))
+    interviews.GET("/slots", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), ih.Slots)
+    interviews.POST("/slots", auth.RequireRole(domain.RoleTeacher, domain.RoleAdmin), ih.AddSlots)
+    interviews.DELETE("/slots/:id", auth.RequireRole(domain.RoleTeacher, domain.RoleAdmin), ih.DeleteSlot)
+    interviews.POST("/invite", auth.RequireRole(domain.RoleTeacher, domain.RoleAdmin), ih.Invite)
+    interviews.POST("/book", auth.RequireRole(domain.RoleStudent), ih.Book)
+    interviews.GET("/calendar.ics", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), ih.Calendar)
+    interviews.POST("/feed", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), ih.NewFeed)
+    interviews.DELETE("/feed", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), ih.RevokeFeed)
+
+    api.GET("/rounds", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), rh.List)
+    api.GET("/rounds/current", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), rh.Current)
+
+    apply := api.Group("/apply", auth.RequireScope("applications"))
+    apply.POST("", auth.RequireRole(domain.RoleStudent), life.Apply)
 
-    upload := api.Group("/upload")
+    upload := api.Group("/upload", auth.RequireScope("documents"))
     upload.POST("", auth.RequireRole(domain.RoleStudent), handle.NewUploadHandlers(h.Service()).Upload)
 
-    documents := api.Group("/documents")
+    documents := api.Group("/documents", auth.RequireScope("documents"))
     documents.GET("", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), handle.NewUploadHandlers(h.Service()).List)
     documents.GET("/download", auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), handle.NewUploadHandlers(h.Service()).Download)
 
-    admin := api.Group("/admin").Use(auth.RequireRole(domain.RoleAdmin))
-    admin.GET("/stats", handle.NewAdminHandlers(h.Service()).Stats)
-    admin.POST("/user/role", handle.NewAdminHandlers(h.Service()).UpdateUserRole)
-    api.PUT("/me", h.UpdateMe)
+    admin := api.Group("/admin", auth.RequireScope("admin")).Use(auth.RequireRole(domain.RoleAdmin))
+    admin.GET("/stats", adm.Stats)
+    admin.POST("/user/role", adm.UpdateUserRole)
+    admin.GET("/lockouts", adm.ListLockouts)
+    admin.POST("/lockouts/clear", adm.ClearLockout)
+    admin.POST("/2fa/reset", th.AdminReset)
+    admin.GET("/2fa/policy", th.Policies)
+    admin.POST("/2fa/policy", th.SetPolicy)
+    admin.GET("/keys", kh.List)
+    admin.POST("/keys/rotate", kh.Rotate)
+    admin.POST("/keys/retire", kh.Retire)
+    admin.GET("/policy", h.PolicyMatrix)
+    admin.POST("/rounds", rh.Create)
+    admin.POST("/rounds/update", rh.Update)
+    admin.POST("/rounds/close", rh.Close)
+    admin.GET("/skills", skh.List)
+    admin.POST("/skills", skh.Create)
+    admin.POST("/skills/update", skh.Update)
+    admin.DELETE("/skills/:id", skh.Delete)
+    admin.GET("/allocations", alh.List)
+    admin.POST("/allocations", alh.Run)
+    admin.GET("/allocations/:id", alh.Proposals)
+    admin.POST("/allocations/publish", alh.Publish)
+    admin.POST("/allocations/discard", alh.Discard)
+    admin.POST("/impersonate", imp.Start)
+    admin.GET("/audit", imp.Audit)
+    admin.GET("/service-accounts", pt.ListServiceAccounts)
+    admin.POST("/service-accounts", pt.CreateServiceAccount)
+    admin.GET("/service-accounts/:id/tokens", pt.ListServiceTokens)
+    admin.POST("/service-accounts/:id/tokens", pt.CreateServiceToken)
+    admin.DELETE("/service-accounts/:id/tokens/:tid", pt.RevokeServiceToken)
+    api.PUT("/me", auth.RequireScope("profile"), auth.RequireRole(domain.RoleStudent, domain.RoleTeacher, domain.RoleAdmin), acc.UpdateMe)
     return r
 }
@@ -11,27 +11,36 @@ func (s *Service) MatchForStudent(studentID int64) ([]domain.MatchResult, error)
     stu := s.repo.GetUser(studentID)
     if stu == nil || stu.Role != domain.RoleStudent { return nil, errors.New("学生不存在") }
     ps := s.repo.ListProjects()
-    return s.matcher.Match(stu, ps), nil
+    return s.matcherOr(nil).Match(stu, ps), nil
 }
 
-func (s *Service) MatchForStudentOpt(studentID int64, fast bool, topK int) ([]domain.MatchResult, error) {
+// MatchForStudentOpt scores projects with m (nil = the configured matcher); a positive
+// topK first narrows the projects down with the keyword matcher.
+func (s *Service) MatchForStudentOpt(studentID int64, m domain.Matcher, topK int) ([]domain.MatchResult, error) {
     stu := s.repo.GetUser(studentID)
     if stu == nil || stu.Role != domain.RoleStudent { return nil, errors.New("学生不存在") }
+    return s.matchProjects(stu, m, topK), nil
+}
+
+// matchProjects scores every project for stu, which may also be a team's combined profile.
+func (s *Service) matchProjects(stu *domain.User, m domain.Matcher, topK int) []domain.MatchResult {
+    matcher := s.matcherOr(m)
     projects := s.repo.ListProjects()
-    if fast {
-        return SimpleMatcher{}.Match(stu, projects), nil
+    if topK <= 0 || matcherName(matcher) == MatcherSimple {
+        res := matcher.Match(stu, projects)
+        sort.Slice(res, func(i, j int) bool { return res[i].Score > res[j].Score })
+        return res
     }
-    if topK <= 0 { topK = 5 }
     // prefilter with simple matcher to get topK
-    simple := SimpleMatcher{}.Match(stu, projects)
+    simple := s.prefilter().Match(stu, projects)
     sort.Slice(simple, func(i, j int) bool { return simple[i].Score > simple[j].Score })
     // pick topK projects
     var subset []*domain.Project
     for i := 0; i < len(simple) && i < topK; i++ { subset = append(subset, simple[i].Project) }
-    if len(subset) == 0 { return []domain.MatchResult{}, nil }
-    // run configured matcher on subset (LLM if enabled)
-    detailed := s.matcher.Match(stu, subset)
+    if len(subset) == 0 { return []domain.MatchResult{} }
+    // run the chosen matcher on subset
+    detailed := matcher.Match(stu, subset)
     // ensure results sorted by score desc
     sort.Slice(detailed, func(i, j int) bool { return detailed[i].Score > detailed[j].Score })
-    return detailed, nil
+    return detailed
 }